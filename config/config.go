@@ -1,47 +1,187 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"math/big"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type WalletConfig struct {
-	PrivateKey   string
-	BuyAmountBNB *big.Float
+	PrivateKey         string
+	BuyAmountBNB       *big.Float
+	GasStrategyBuy     string
+	GasStrategySell    string
+	GasStrategyApprove string
+}
+
+// TokenTypeRule configures one allowed TokenManager creator type. Empty or
+// zero fields fall back to the wallet buy amount and the global exit settings.
+type TokenTypeRule struct {
+	TokenType             int64
+	BuyAmountsBNB         []*big.Float
+	StopLossPercent       int
+	TakeProfitPriceUSDT   float64
+	TakeProfitSellPercent int
 }
 
 type Config struct {
-	BSCRPCURL       string
-	BSCRPCHttp      string
-	Wallets         []WalletConfig
-	ContractAddress string
-	Slippage        int
-	GasLimit        uint64
-	GasPriceGwei    int64
-	StopLossPercent int
-	EnableStopLoss  bool
+	BSCRPCURLs              []string
+	WSMaxLag                time.Duration
+	PollInterval            time.Duration
+	MempoolWSURL            string
+	EventWorkers            int
+	EventQueueSize          int
+	EventDeadline           time.Duration
+	BSCRPCHttp              string
+	Wallets                 []WalletConfig
+	ContractAddress         string
+	TradingMode             string
+	FlapPortalAddress       string
+	ListenEvents            []string
+	BackfillFreshBlocks     uint64
+	BackfillMaxBlocks       uint64
+	ConfirmDepth            uint64
+	SeenLogsFile            string
+	TokenTypeRules          []TokenTypeRule
+	QuoteBuyAmounts         map[string]*big.Float
+	RouterAddress           string
+	FactoryAddress          string
+	V3RouterAddress         string
+	V3QuoterAddress         string
+	DexVenues               []string
+	RouteFinder             bool
+	RouteBaseTokens         []string
+	RouteRepricePercent     float64
+	Slippage                int
+	DefaultTaxPercent       float64
+	GasLimit                uint64
+	GasLimitMultiplier      float64
+	GasLimitFloor           uint64
+	GasLimitCeiling         uint64
+	FastBuyGasLimit         bool
+	GasPriceGwei            int64
+	GasSuggestMultiplier    float64
+	GasFeeHistoryBlocks     uint64
+	GasFeeHistoryPercentile float64
+	GasTipGwei              float64
+	GasBaseFeeMultiplier    float64
+	GasBumpPercents         []int
+	MaxGasPriceGwei         int64
+	TxConfirmations         uint64
+	TxDropTimeout           time.Duration
+	HoneypotCheck           bool
+	HoneypotSimAmountBNB    *big.Float
+	HoneypotMaxLossPercent  float64
+	Filters                 []string
+	FilterMinFunds          *big.Float
+	FilterMaxFunds          *big.Float
+	FilterMinOffers         *big.Float
+	FilterMaxOffers         *big.Float
+	FilterQuoteTokens       []string
+	FilterCreatorBlacklist  []string
+	FilterMaxBuyTaxPercent  float64
+	FilterMaxSellTaxPercent float64
+	DecisionJournalFile     string
+	StopLossPercent         int
+	ApproveAfterBuy         bool
+	EnableStopLoss          bool
+	SellEscalateBlocks      uint64
 }
 
-func Load() *Config {
+// Load reads the configuration from the environment and .env. Only settings
+// the bot cannot start without are errors; the rest fall back to defaults.
+func Load() (*Config, error) {
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Warning: .env file not found, using environment variables")
 	}
 
 	slippage, _ := strconv.Atoi(getEnv("SLIPPAGE", "10"))
+	defaultTaxPercent, _ := strconv.ParseFloat(getEnv("DEFAULT_TAX_PERCENT", "0"), 64)
 	gasLimit, _ := strconv.ParseUint(getEnv("GAS_LIMIT", "300000"), 10, 64)
+	gasLimitMultiplier, _ := strconv.ParseFloat(getEnv("GAS_LIMIT_MULTIPLIER", "1.3"), 64)
+	gasLimitFloor, _ := strconv.ParseUint(getEnv("GAS_LIMIT_FLOOR", "50000"), 10, 64)
+	gasLimitCeiling, _ := strconv.ParseUint(getEnv("GAS_LIMIT_CEILING", "1000000"), 10, 64)
+	fastBuyGasLimit := getEnv("FAST_BUY_GAS_LIMIT", "false") == "true"
 	gasPriceGwei, _ := strconv.ParseInt(getEnv("GAS_PRICE_GWEI", "5"), 10, 64)
 
+	gasSuggestMultiplier, _ := strconv.ParseFloat(getEnv("GAS_SUGGEST_MULTIPLIER", "1.2"), 64)
+	gasFeeHistoryBlocks, _ := strconv.ParseUint(getEnv("GAS_FEE_HISTORY_BLOCKS", "20"), 10, 64)
+	gasFeeHistoryPercentile, _ := strconv.ParseFloat(getEnv("GAS_FEE_HISTORY_PERCENTILE", "75"), 64)
+	gasTipGwei, _ := strconv.ParseFloat(getEnv("GAS_TIP_GWEI", "0"), 64)
+	gasBaseFeeMultiplier, _ := strconv.ParseFloat(getEnv("GAS_BASE_FEE_MULTIPLIER", "2"), 64)
+	maxGasPriceGwei, _ := strconv.ParseInt(getEnv("MAX_GAS_PRICE_GWEI", "20"), 10, 64)
+	var gasBumpPercents []int
+	for _, part := range strings.Split(getEnv("GAS_BUMP_PERCENTS", "12,25,50"), ",") {
+		if pct, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && pct > 0 {
+			gasBumpPercents = append(gasBumpPercents, pct)
+		}
+	}
+
+	var dexVenues []string
+	for _, venue := range strings.Split(getEnv("DEX_VENUES", "v2"), ",") {
+		if venue = strings.ToLower(strings.TrimSpace(venue)); venue != "" {
+			dexVenues = append(dexVenues, venue)
+		}
+	}
+
+	var listenEvents []string
+	for _, event := range strings.Split(getEnv("LISTEN_EVENTS", ""), ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			listenEvents = append(listenEvents, event)
+		}
+	}
+
+	backfillFreshBlocks, _ := strconv.ParseUint(getEnv("BACKFILL_FRESH_BLOCKS", "3"), 10, 64)
+	backfillMaxBlocks, _ := strconv.ParseUint(getEnv("BACKFILL_MAX_BLOCKS", "2000"), 10, 64)
+
+	wsMaxLagMs, _ := strconv.Atoi(getEnv("WS_MAX_LAG_MS", "1000"))
+	pollIntervalMs, _ := strconv.Atoi(getEnv("POLL_INTERVAL_MS", "1000"))
+	bscRPCURLs := getEnvList("BSC_RPC_URL", "wss://bsc-ws-node.nariox.org:443")
+	if len(bscRPCURLs) == 0 {
+		return nil, fmt.Errorf("BSC_RPC_URL lists no endpoints")
+	}
+	var mempoolWSURL string
+	if getEnv("MEMPOOL_WATCH", "false") == "true" {
+		mempoolWSURL = getEnv("MEMPOOL_WS_URL", bscRPCURLs[0])
+	}
+
+	eventWorkers, _ := strconv.Atoi(getEnv("EVENT_WORKERS", "4"))
+	eventQueueSize, _ := strconv.Atoi(getEnv("EVENT_QUEUE_SIZE", "256"))
+	eventDeadlineMs, _ := strconv.Atoi(getEnv("EVENT_DEADLINE_MS", "10000"))
+
+	confirmDepth, _ := strconv.ParseUint(getEnv("CONFIRM_DEPTH", "0"), 10, 64)
+
+	var tokenTypeRules []TokenTypeRule
+	for _, part := range strings.Split(getEnv("ALLOWED_TOKEN_TYPES", "5"), ",") {
+		part = strings.TrimSpace(part)
+		tokenType, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			if part != "" {
+				log.Printf("Warning: ignoring invalid token type %q in ALLOWED_TOKEN_TYPES", part)
+			}
+			continue
+		}
+		tokenTypeRules = append(tokenTypeRules, loadTokenTypeRule(tokenType))
+	}
+
+	txConfirmations, _ := strconv.ParseUint(getEnv("TX_CONFIRMATIONS", "1"), 10, 64)
+	txDropTimeoutSec, _ := strconv.Atoi(getEnv("TX_DROP_TIMEOUT_SECONDS", "120"))
+
 	privateKeysStr := getEnv("PRIVATE_KEYS", "")
 	buyAmountsStr := getEnv("BUY_AMOUNTS_BNB", "")
 
 	privateKeys := strings.Split(privateKeysStr, ",")
 	buyAmounts := strings.Split(buyAmountsStr, ",")
+	buyStrategies := strings.Split(getEnv("GAS_STRATEGY_BUY", "fixed"), ",")
+	sellStrategies := strings.Split(getEnv("GAS_STRATEGY_SELL", "fixed"), ",")
+	approveStrategies := strings.Split(getEnv("GAS_STRATEGY_APPROVE", "fixed"), ",")
 
 	var wallets []WalletConfig
 	for i, pk := range privateKeys {
@@ -63,25 +203,177 @@ func Load() *Config {
 		}
 
 		wallets = append(wallets, WalletConfig{
-			PrivateKey:   pk,
-			BuyAmountBNB: buyAmount,
+			PrivateKey:         pk,
+			BuyAmountBNB:       buyAmount,
+			GasStrategyBuy:     perWallet(buyStrategies, i),
+			GasStrategySell:    perWallet(sellStrategies, i),
+			GasStrategyApprove: perWallet(approveStrategies, i),
 		})
 	}
 
+	honeypotCheck := getEnv("HONEYPOT_CHECK", "true") == "true"
+	honeypotSimAmountBNB, ok := new(big.Float).SetString(getEnv("HONEYPOT_SIM_AMOUNT_BNB", "0.01"))
+	if !ok {
+		honeypotSimAmountBNB = big.NewFloat(0.01)
+	}
+	honeypotMaxLossPercent, _ := strconv.ParseFloat(getEnv("HONEYPOT_MAX_LOSS_PERCENT", "30"), 64)
+
+	routeRepricePercent, _ := strconv.ParseFloat(getEnv("ROUTE_REPRICE_PERCENT", "20"), 64)
+
+	filters := getEnvList("FILTERS", "token_type,funds,offers,quote,creator,tax,honeypot")
+	filterQuoteTokens := getEnvList("FILTER_QUOTE_TOKENS", "")
+	filterCreatorBlacklist := getEnvList("FILTER_CREATOR_BLACKLIST", "")
+	filterMaxBuyTaxPercent, _ := strconv.ParseFloat(getEnv("FILTER_MAX_BUY_TAX_PERCENT", "0"), 64)
+	filterMaxSellTaxPercent, _ := strconv.ParseFloat(getEnv("FILTER_MAX_SELL_TAX_PERCENT", "0"), 64)
+
 	stopLossPercent, _ := strconv.Atoi(getEnv("STOP_LOSS_PERCENT", "20"))
 	enableStopLoss := getEnv("ENABLE_STOP_LOSS", "true") == "true"
+	sellEscalateBlocks, _ := strconv.ParseUint(getEnv("SELL_ESCALATE_BLOCKS", "3"), 10, 64)
 
 	return &Config{
-		BSCRPCURL:       getEnv("BSC_RPC_URL", "wss://bsc-ws-node.nariox.org:443"),
-		BSCRPCHttp:      getEnv("BSC_RPC_HTTP", "https://bsc-dataseed.binance.org/"),
-		Wallets:         wallets,
-		ContractAddress: getEnv("CONTRACT_ADDRESS", ""),
-		Slippage:        slippage,
-		GasLimit:        gasLimit,
-		GasPriceGwei:    gasPriceGwei,
-		StopLossPercent: stopLossPercent,
-		EnableStopLoss:  enableStopLoss,
+		BSCRPCURLs:              bscRPCURLs,
+		WSMaxLag:                time.Duration(wsMaxLagMs) * time.Millisecond,
+		PollInterval:            time.Duration(pollIntervalMs) * time.Millisecond,
+		MempoolWSURL:            mempoolWSURL,
+		EventWorkers:            eventWorkers,
+		EventQueueSize:          eventQueueSize,
+		EventDeadline:           time.Duration(eventDeadlineMs) * time.Millisecond,
+		BSCRPCHttp:              getEnv("BSC_RPC_HTTP", "https://bsc-dataseed.binance.org/"),
+		Wallets:                 wallets,
+		ContractAddress:         getEnv("CONTRACT_ADDRESS", ""),
+		TradingMode:             strings.ToLower(getEnv("TRADING_MODE", "dex")),
+		FlapPortalAddress:       getEnv("FLAP_PORTAL", "0xe2cE6ab80874Fa9Fa2aAE65D277Dd6B8e65C9De0"),
+		ListenEvents:            listenEvents,
+		BackfillFreshBlocks:     backfillFreshBlocks,
+		BackfillMaxBlocks:       backfillMaxBlocks,
+		ConfirmDepth:            confirmDepth,
+		SeenLogsFile:            getEnv("SEEN_LOGS_FILE", "seen_logs.json"),
+		TokenTypeRules:          tokenTypeRules,
+		QuoteBuyAmounts:         getEnvQuoteAmounts("QUOTE_BUY_AMOUNTS"),
+		RouterAddress:           getEnv("ROUTER_ADDRESS", "0x10ED43C718714eb63d5aA57B78B54704E256024E"),
+		FactoryAddress:          getEnv("FACTORY_ADDRESS", "0xcA143Ce32Fe78f1f7019d7d551a6402fC5350c73"),
+		V3RouterAddress:         getEnv("V3_ROUTER_ADDRESS", "0x13f4EA83D0bd40E75C8222255bc855a974568Dd4"),
+		V3QuoterAddress:         getEnv("V3_QUOTER_ADDRESS", "0xB048Bbc1Ee6b733FFfCFb9e9CeF7375518e25997"),
+		DexVenues:               dexVenues,
+		RouteFinder:             getEnv("ROUTE_FINDER", "true") == "true",
+		RouteBaseTokens:         getEnvList("ROUTE_BASE_TOKENS", ""),
+		RouteRepricePercent:     routeRepricePercent,
+		Slippage:                slippage,
+		DefaultTaxPercent:       defaultTaxPercent,
+		GasLimit:                gasLimit,
+		GasLimitMultiplier:      gasLimitMultiplier,
+		GasLimitFloor:           gasLimitFloor,
+		GasLimitCeiling:         gasLimitCeiling,
+		FastBuyGasLimit:         fastBuyGasLimit,
+		GasPriceGwei:            gasPriceGwei,
+		GasSuggestMultiplier:    gasSuggestMultiplier,
+		GasFeeHistoryBlocks:     gasFeeHistoryBlocks,
+		GasFeeHistoryPercentile: gasFeeHistoryPercentile,
+		GasTipGwei:              gasTipGwei,
+		GasBaseFeeMultiplier:    gasBaseFeeMultiplier,
+		GasBumpPercents:         gasBumpPercents,
+		MaxGasPriceGwei:         maxGasPriceGwei,
+		TxConfirmations:         txConfirmations,
+		TxDropTimeout:           time.Duration(txDropTimeoutSec) * time.Second,
+		HoneypotCheck:           honeypotCheck,
+		HoneypotSimAmountBNB:    honeypotSimAmountBNB,
+		HoneypotMaxLossPercent:  honeypotMaxLossPercent,
+		Filters:                 filters,
+		FilterMinFunds:          getEnvAmount("FILTER_MIN_FUNDS"),
+		FilterMaxFunds:          getEnvAmount("FILTER_MAX_FUNDS"),
+		FilterMinOffers:         getEnvAmount("FILTER_MIN_OFFERS"),
+		FilterMaxOffers:         getEnvAmount("FILTER_MAX_OFFERS"),
+		FilterQuoteTokens:       filterQuoteTokens,
+		FilterCreatorBlacklist:  filterCreatorBlacklist,
+		FilterMaxBuyTaxPercent:  filterMaxBuyTaxPercent,
+		FilterMaxSellTaxPercent: filterMaxSellTaxPercent,
+		DecisionJournalFile:     getEnv("DECISION_JOURNAL_FILE", "decisions.jsonl"),
+		StopLossPercent:         stopLossPercent,
+		EnableStopLoss:          enableStopLoss,
+		ApproveAfterBuy:         getEnv("APPROVE_AFTER_BUY", "false") == "true",
+		SellEscalateBlocks:      sellEscalateBlocks,
+	}, nil
+}
+
+// loadTokenTypeRule reads the TOKEN_TYPE_<n>_* overrides for one type.
+func loadTokenTypeRule(tokenType int64) TokenTypeRule {
+	prefix := "TOKEN_TYPE_" + strconv.FormatInt(tokenType, 10) + "_"
+
+	var buyAmounts []*big.Float
+	if amountsStr := getEnv(prefix+"BUY_AMOUNTS_BNB", ""); amountsStr != "" {
+		for _, amtStr := range strings.Split(amountsStr, ",") {
+			amount, ok := new(big.Float).SetString(strings.TrimSpace(amtStr))
+			if !ok {
+				amount = nil
+			}
+			buyAmounts = append(buyAmounts, amount)
+		}
+	}
+
+	stopLossPercent, _ := strconv.Atoi(getEnv(prefix+"STOP_LOSS_PERCENT", "0"))
+	takeProfitPriceUSDT, _ := strconv.ParseFloat(getEnv(prefix+"TAKE_PROFIT_PRICE_USDT", "0"), 64)
+	takeProfitSellPercent, _ := strconv.Atoi(getEnv(prefix+"TAKE_PROFIT_SELL_PERCENT", "0"))
+
+	return TokenTypeRule{
+		TokenType:             tokenType,
+		BuyAmountsBNB:         buyAmounts,
+		StopLossPercent:       stopLossPercent,
+		TakeProfitPriceUSDT:   takeProfitPriceUSDT,
+		TakeProfitSellPercent: takeProfitSellPercent,
+	}
+}
+
+// perWallet picks the i-th entry of a comma-separated per-wallet setting and
+// falls back to the last entry, so a single value applies to every wallet.
+func perWallet(values []string, i int) string {
+	if i >= len(values) {
+		i = len(values) - 1
+	}
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[i])
+}
+
+// getEnvList splits a comma-separated setting, dropping empty entries.
+func getEnvList(key, defaultValue string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvQuoteAmounts reads comma-separated address:amount pairs, keyed by the
+// address as written.
+func getEnvQuoteAmounts(key string) map[string]*big.Float {
+	amounts := make(map[string]*big.Float)
+	for _, pair := range getEnvList(key, "") {
+		address, amountStr, found := strings.Cut(pair, ":")
+		amount, ok := new(big.Float).SetString(strings.TrimSpace(amountStr))
+		if !found || !ok {
+			log.Printf("Warning: ignoring invalid entry %q in %s", pair, key)
+			continue
+		}
+		amounts[strings.TrimSpace(address)] = amount
+	}
+	return amounts
+}
+
+// getEnvAmount reads a decimal token amount; unset or invalid values are nil.
+func getEnvAmount(key string) *big.Float {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil
+	}
+	amount, ok := new(big.Float).SetString(value)
+	if !ok {
+		log.Printf("Warning: ignoring invalid amount %q in %s", value, key)
+		return nil
 	}
+	return amount
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadRPCURLs(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantErr     bool
+		wantURLs    []string
+		wantMempool string
+	}{
+		{name: "separators only", value: " , ,", wantErr: true},
+		{name: "whitespace only", value: "   ", wantErr: true},
+		{
+			name:        "list with blanks",
+			value:       "ws://a:8546, ,ws://b:8546",
+			wantURLs:    []string{"ws://a:8546", "ws://b:8546"},
+			wantMempool: "ws://a:8546",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BSC_RPC_URL", tt.value)
+			t.Setenv("MEMPOOL_WATCH", "true")
+			t.Setenv("MEMPOOL_WS_URL", "")
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.BSCRPCURLs, tt.wantURLs) {
+				t.Errorf("BSCRPCURLs = %v, want %v", cfg.BSCRPCURLs, tt.wantURLs)
+			}
+			if cfg.MempoolWSURL != tt.wantMempool {
+				t.Errorf("MempoolWSURL = %q, want %q", cfg.MempoolWSURL, tt.wantMempool)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package contracts
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// BNBDecimals is the precision of BNB and WBNB.
+const BNBDecimals = 18
+
+// Amount is an exact token amount: Raw base units of a token with Decimals
+// places. It never goes through floating point, so formatting and parsing
+// round-trip for any decimals.
+type Amount struct {
+	Raw      *big.Int
+	Decimals uint8
+}
+
+func NewAmount(raw *big.Int, decimals uint8) Amount {
+	return Amount{Raw: raw, Decimals: decimals}
+}
+
+// ParseAmount reads a decimal string such as "0.15" into base units. More
+// fractional digits than decimals is an error rather than a silent rounding.
+func ParseAmount(value string, decimals uint8) (Amount, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, frac, _ := strings.Cut(value, ".")
+	if whole == "" && frac == "" {
+		return Amount{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if len(frac) > int(decimals) {
+		return Amount{}, fmt.Errorf("amount %q has more than %d decimals", value, decimals)
+	}
+
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	if strings.Trim(digits, "0123456789") != "" {
+		return Amount{}, fmt.Errorf("invalid amount %q", value)
+	}
+	raw, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Amount{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		raw.Neg(raw)
+	}
+	return Amount{Raw: raw, Decimals: decimals}, nil
+}
+
+// FloatAmount converts a configured decimal amount to base units exactly,
+// going through its shortest decimal form rather than a float
+// multiplication. Digits beyond decimals are rounded off.
+func FloatAmount(amount *big.Float, decimals uint8) (Amount, error) {
+	if parsed, err := ParseAmount(amount.Text('f', -1), decimals); err == nil {
+		return parsed, nil
+	}
+	return ParseAmount(amount.Text('f', int(decimals)), decimals)
+}
+
+// BNB is an amount of wei.
+func BNB(wei *big.Int) Amount {
+	return Amount{Raw: wei, Decimals: BNBDecimals}
+}
+
+// Unit is one whole token in base units, 10^decimals.
+func Unit(decimals uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
+
+// String formats the amount exactly, without trailing zeros.
+func (a Amount) String() string {
+	if a.Raw == nil {
+		return "0"
+	}
+	abs := new(big.Int).Abs(a.Raw)
+	whole, frac := new(big.Int).QuoRem(abs, Unit(a.Decimals), new(big.Int))
+
+	s := whole.String()
+	if frac.Sign() != 0 {
+		fracStr := fmt.Sprintf("%0*s", int(a.Decimals), frac.String())
+		s += "." + strings.TrimRight(fracStr, "0")
+	}
+	if a.Raw.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// Rat is the amount in whole tokens.
+func (a Amount) Rat() *big.Rat {
+	if a.Raw == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(a.Raw, Unit(a.Decimals))
+}
+
+// Float64 is the amount in whole tokens, for comparisons against float
+// settings and for display.
+func (a Amount) Float64() float64 {
+	f, _ := a.Rat().Float64()
+	return f
+}
+
+// UnitPrice is the price of one whole token given that amountIn of it is
+// worth amountOut, in whole units of amountOut's token.
+func UnitPrice(amountIn, amountOut Amount) *big.Rat {
+	in := amountIn.Rat()
+	if in.Sign() == 0 {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Quo(amountOut.Rat(), in)
+}
@@ -0,0 +1,107 @@
+package contracts
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value    string
+		decimals uint8
+		wantRaw  string
+		want     string
+		wantErr  bool
+	}{
+		{value: "0.15", decimals: 18, wantRaw: "150000000000000000", want: "0.15"},
+		{value: "1", decimals: 18, wantRaw: "1000000000000000000", want: "1"},
+		{value: "1.", decimals: 6, wantRaw: "1000000", want: "1"},
+		{value: ".5", decimals: 6, wantRaw: "500000", want: "0.5"},
+		{value: "  2.50  ", decimals: 6, wantRaw: "2500000", want: "2.5"},
+		{value: "0.000001", decimals: 6, wantRaw: "1", want: "0.000001"},
+		{value: "-1.25", decimals: 9, wantRaw: "-1250000000", want: "-1.25"},
+		{value: "42", decimals: 0, wantRaw: "42", want: "42"},
+		{value: "123456789.123456789012345678", decimals: 18, wantRaw: "123456789123456789012345678", want: "123456789.123456789012345678"},
+		{value: "0.0000001", decimals: 6, wantErr: true},
+		{value: "1.5", decimals: 0, wantErr: true},
+		{value: "", decimals: 18, wantErr: true},
+		{value: ".", decimals: 18, wantErr: true},
+		{value: "-", decimals: 18, wantErr: true},
+		{value: "1.2.3", decimals: 18, wantErr: true},
+		{value: "1e18", decimals: 18, wantErr: true},
+		{value: "+1", decimals: 18, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			amount, err := ParseAmount(tt.value, tt.decimals)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseAmount(%q, %d) = %s, want an error", tt.value, tt.decimals, amount.Raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if amount.Raw.String() != tt.wantRaw || amount.Decimals != tt.decimals {
+				t.Errorf("ParseAmount(%q, %d) = %s (%d decimals), want %s", tt.value, tt.decimals, amount.Raw, amount.Decimals, tt.wantRaw)
+			}
+			if got := amount.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			again, err := ParseAmount(amount.String(), tt.decimals)
+			if err != nil || again.Raw.Cmp(amount.Raw) != 0 {
+				t.Errorf("ParseAmount(String()) = %v, %v, want %s", again.Raw, err, amount.Raw)
+			}
+		})
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{amount: Amount{}, want: "0"},
+		{amount: NewAmount(new(big.Int), 18), want: "0"},
+		{amount: BNB(big.NewInt(1)), want: "0.000000000000000001"},
+		{amount: NewAmount(big.NewInt(1_000_000), 6), want: "1"},
+		{amount: NewAmount(big.NewInt(-100), 2), want: "-1"},
+		{amount: NewAmount(big.NewInt(-5), 2), want: "-0.05"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.want {
+			t.Errorf("%v.String() = %q, want %q", tt.amount.Raw, got, tt.want)
+		}
+	}
+}
+
+func TestFloatAmount(t *testing.T) {
+	tests := []struct {
+		value    string
+		decimals uint8
+		want     string
+	}{
+		{value: "0.1", decimals: 18, want: "100000000000000000"},
+		{value: "24", decimals: 6, want: "24000000"},
+		{value: "1.1234565", decimals: 6, want: "1123457"},
+		{value: "1000000.5", decimals: 9, want: "1000000500000000"},
+	}
+
+	for _, tt := range tests {
+		f, ok := new(big.Float).SetString(tt.value)
+		if !ok {
+			t.Fatalf("bad float %q", tt.value)
+		}
+		amount, err := FloatAmount(f, tt.decimals)
+		if err != nil {
+			t.Errorf("FloatAmount(%s, %d): %v", tt.value, tt.decimals, err)
+			continue
+		}
+		if amount.Raw.String() != tt.want {
+			t.Errorf("FloatAmount(%s, %d) = %s, want %s", tt.value, tt.decimals, amount.Raw, tt.want)
+		}
+	}
+}
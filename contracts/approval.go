@@ -0,0 +1,155 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MaxApproval is what ensureApproval approves, so one approval covers every
+// later sell of the token.
+var MaxApproval = new(big.Int).Set(abi.MaxUint256)
+
+type approvalKey struct {
+	token   common.Address
+	spender common.Address
+}
+
+// approval is what a wallet knows about one allowance: MaxApproval once seen
+// or approved, or the hash of an approval still in flight. A smaller allowance
+// shrinks with every sell, so it is never cached and is read again each time.
+// claimed is open while one caller is reading or approving the allowance.
+type approval struct {
+	allowance *big.Int
+	pending   string
+	claimed   chan struct{}
+}
+
+// ensureApproval makes sure spender may move amount of the token from this
+// wallet. A tracked or on-chain allowance that covers amount sends nothing
+// and returns ""; an approval already in flight is returned rather than sent
+// again. Only a shortfall sends approve(MaxApproval). The first caller claims
+// the token and spender and does the RPCs without holding the lock; others
+// wait for its result, so concurrent callers never approve twice.
+func (b *baseSwapper) ensureApproval(tokenAddress, spender common.Address, amount *big.Int) (string, error) {
+	key := approvalKey{token: tokenAddress, spender: spender}
+
+	claimed, txHash, done := b.claimApproval(key, amount)
+	if done {
+		return txHash, nil
+	}
+
+	allowance, err := b.allowance(tokenAddress, spender)
+	if err != nil {
+		b.settleApproval(key, claimed, nil)
+		return "", err
+	}
+	if allowance.Cmp(amount) >= 0 {
+		var a *approval
+		if allowance.Cmp(MaxApproval) == 0 {
+			a = &approval{allowance: allowance}
+		}
+		b.settleApproval(key, claimed, a)
+		log.Printf("[%s] Allowance of %s for %s already covers %s, not approving", b.address.Hex(), tokenAddress.Hex(), spender.Hex(), amount.String())
+		return "", nil
+	}
+
+	txHash, err = b.approve(tokenAddress, spender, MaxApproval)
+	if err != nil {
+		b.settleApproval(key, claimed, nil)
+		return "", err
+	}
+	b.settleApproval(key, claimed, &approval{pending: txHash})
+
+	err = b.OnTxDone(txHash, func(result TxResult) {
+		b.approvalMu.Lock()
+		defer b.approvalMu.Unlock()
+		if !result.Success() {
+			log.Printf("[%s] Approval %s of %s %s", b.address.Hex(), txHash, tokenAddress.Hex(), result.Status)
+			delete(b.approvals, key)
+			return
+		}
+		b.approvals[key] = &approval{allowance: MaxApproval}
+	})
+	if err != nil {
+		log.Printf("[%s] Failed to watch approval %s: %v", b.address.Hex(), txHash, err)
+		b.approvalMu.Lock()
+		delete(b.approvals, key)
+		b.approvalMu.Unlock()
+	}
+	return txHash, nil
+}
+
+// claimApproval either answers from what is known about the allowance, with
+// done set, or claims the key for the caller. It waits out another caller's
+// claim first.
+func (b *baseSwapper) claimApproval(key approvalKey, amount *big.Int) (claimed chan struct{}, txHash string, done bool) {
+	for {
+		b.approvalMu.Lock()
+		a, ok := b.approvals[key]
+		if ok && a.claimed != nil {
+			wait := a.claimed
+			b.approvalMu.Unlock()
+			<-wait
+			continue
+		}
+		if ok && a.pending != "" {
+			b.approvalMu.Unlock()
+			return nil, a.pending, true
+		}
+		if ok && a.allowance.Cmp(amount) >= 0 {
+			b.approvalMu.Unlock()
+			return nil, "", true
+		}
+
+		claimed = make(chan struct{})
+		b.approvals[key] = &approval{claimed: claimed}
+		b.approvalMu.Unlock()
+		return claimed, "", false
+	}
+}
+
+// settleApproval records the claimed key's outcome, forgetting it when a is
+// nil, and wakes the callers waiting on it.
+func (b *baseSwapper) settleApproval(key approvalKey, claimed chan struct{}, a *approval) {
+	b.approvalMu.Lock()
+	if a == nil {
+		delete(b.approvals, key)
+	} else {
+		b.approvals[key] = a
+	}
+	b.approvalMu.Unlock()
+	close(claimed)
+}
+
+func (b *baseSwapper) allowance(tokenAddress, spender common.Address) (*big.Int, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(ERC20ABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ERC20 ABI: %w", err)
+	}
+
+	data, err := parsedABI.Pack("allowance", b.address, spender)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack allowance: %w", err)
+	}
+
+	result, err := b.client.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &tokenAddress,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call allowance: %w", err)
+	}
+
+	outputs, err := parsedABI.Unpack("allowance", result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack allowance: %w", err)
+	}
+	return outputs[0].(*big.Int), nil
+}
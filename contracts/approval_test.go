@@ -0,0 +1,108 @@
+package contracts
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// allowanceNode answers allowance() with a fixed value and counts the reads.
+type allowanceNode struct {
+	allowance *big.Int
+	fail      bool
+	mu        sync.Mutex
+	reads     int
+}
+
+func (n *allowanceNode) Call(args callArgs, block string) (hexutil.Bytes, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.reads++
+	if n.fail {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	return erc20TestABI.Methods["allowance"].Outputs.Pack(n.allowance)
+}
+
+func TestEnsureApprovalCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowance *big.Int
+		fail      bool
+		wantReads int
+		wantErr   bool
+	}{
+		{
+			name:      "max allowance is read once",
+			allowance: MaxApproval,
+			wantReads: 1,
+		},
+		{
+			name:      "smaller allowance is read before every sell",
+			allowance: ether(1_000_000),
+			wantReads: 3,
+		},
+		{
+			name:      "failed read is not cached",
+			fail:      true,
+			wantReads: 3,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &allowanceNode{allowance: tt.allowance, fail: tt.fail}
+			b := &baseSwapper{
+				client:    newTestClient(t, node),
+				address:   testWallet,
+				approvals: make(map[approvalKey]*approval),
+			}
+
+			for i := 0; i < 3; i++ {
+				txHash, err := b.ensureApproval(testToken, testRouter, ether(1000))
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("call %d: err = %v, want error: %v", i, err, tt.wantErr)
+				}
+				if txHash != "" {
+					t.Fatalf("call %d sent approval %s, want none", i, txHash)
+				}
+			}
+			if node.reads != tt.wantReads {
+				t.Errorf("allowance read %d times, want %d", node.reads, tt.wantReads)
+			}
+
+			key := approvalKey{token: testToken, spender: testRouter}
+			a, cached := b.approvals[key]
+			if wantCached := tt.allowance == MaxApproval; cached != wantCached {
+				t.Fatalf("cached = %v, want %v", cached, wantCached)
+			}
+			if cached && (a.allowance.Cmp(MaxApproval) != 0 || a.claimed != nil || a.pending != "") {
+				t.Errorf("cached %+v, want a settled max allowance", a)
+			}
+		})
+	}
+}
+
+func TestEnsureApprovalOtherSpender(t *testing.T) {
+	node := &allowanceNode{allowance: MaxApproval}
+	b := &baseSwapper{
+		client:    newTestClient(t, node),
+		address:   testWallet,
+		approvals: make(map[approvalKey]*approval),
+	}
+	spenders := []common.Address{testRouter, common.HexToAddress("0x13f4EA83D0bd40E75C8222255bc855a974568Dd4")}
+
+	for _, spender := range append(spenders, spenders...) {
+		if _, err := b.ensureApproval(testToken, spender, ether(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if node.reads != len(spenders) {
+		t.Errorf("allowance read %d times, want once per spender (%d)", node.reads, len(spenders))
+	}
+}
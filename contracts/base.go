@@ -0,0 +1,431 @@
+package contracts
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	bpsDenominator  = 10000
+	maxNonceRetries = 2
+)
+
+type TokenTax struct {
+	BuyBps  int64
+	SellBps int64
+}
+
+type SwapperConfig struct {
+	Router  common.Address
+	Factory common.Address
+	Quoter  common.Address
+	// Routes, when set, picks V2 paths instead of the fixed ones through
+	// WBNB or the token's quote.
+	Routes            *RouteFinder
+	GasLimits         GasLimitConfig
+	GasStrategies     GasStrategies
+	Slippage          int
+	DefaultTaxPercent float64
+	GasBumpPercents   []int
+	MaxGasPriceGwei   int64
+}
+
+// baseSwapper is everything a venue needs to trade from one wallet: signing,
+// nonces, gas, tracking and the slippage/tax settings. Venues embed it and
+// only add their own calldata and quoting.
+type baseSwapper struct {
+	client        *ethclient.Client
+	nonces        *NonceManager
+	tracker       *TxTracker
+	privateKey    *ecdsa.PrivateKey
+	address       common.Address
+	chainID       *big.Int
+	gasLimits     GasLimitConfig
+	gasLimitCache *gasLimitCache
+	gas           GasStrategies
+	maxGasPrice   *big.Int
+	gasBumps      []int
+	slippage      int
+	defaultTax    TokenTax
+	taxes         map[common.Address]TokenTax
+	taxMu         sync.RWMutex
+	quotes        map[common.Address]common.Address
+	quoteMu       sync.RWMutex
+	approvals     map[approvalKey]*approval
+	approvalMu    sync.Mutex
+}
+
+func newBaseSwapper(client *ethclient.Client, nonces *NonceManager, tracker *TxTracker, privateKeyHex string, cfg SwapperConfig) (*baseSwapper, error) {
+	privateKey, err := crypto.HexToECDSA(privateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	publicKey := privateKey.Public()
+	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
+	}
+
+	address := crypto.PubkeyToAddress(*publicKeyECDSA)
+
+	chainID, err := client.NetworkID(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	if err := nonces.Sync(address); err != nil {
+		return nil, fmt.Errorf("failed to sync nonce: %w", err)
+	}
+
+	maxGasPrice := new(big.Int).Mul(big.NewInt(cfg.MaxGasPriceGwei), big.NewInt(1e9))
+	defaultTaxBps := int64(cfg.DefaultTaxPercent * 100)
+
+	return &baseSwapper{
+		client:        client,
+		nonces:        nonces,
+		tracker:       tracker,
+		privateKey:    privateKey,
+		address:       address,
+		chainID:       chainID,
+		gasLimits:     cfg.GasLimits,
+		gasLimitCache: &gasLimitCache{limits: make(map[string]uint64)},
+		gas:           cfg.GasStrategies,
+		maxGasPrice:   maxGasPrice,
+		gasBumps:      cfg.GasBumpPercents,
+		slippage:      cfg.Slippage,
+		defaultTax:    TokenTax{BuyBps: defaultTaxBps, SellBps: defaultTaxBps},
+		taxes:         make(map[common.Address]TokenTax),
+		quotes:        make(map[common.Address]common.Address),
+		approvals:     make(map[approvalKey]*approval),
+	}, nil
+}
+
+// fees asks the strategy for the given action and clamps the result to the
+// configured gas cap.
+func (b *baseSwapper) fees(strategy GasStrategy) (*GasFees, error) {
+	fees, err := strategy.Fees(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("gas strategy %s: %w", strategy.Name(), err)
+	}
+	b.clampFees(fees, "Gas strategy "+strategy.Name())
+	return fees, nil
+}
+
+func (b *baseSwapper) clampFees(fees *GasFees, source string) {
+	if b.maxGasPrice.Sign() <= 0 {
+		return
+	}
+	for _, fee := range []*big.Int{fees.GasPrice, fees.GasFeeCap, fees.GasTipCap} {
+		if fee != nil && fee.Cmp(b.maxGasPrice) > 0 {
+			log.Printf("[%s] %s returned %s, clamping to %s wei", b.address.Hex(), source, fees.String(), b.maxGasPrice.String())
+			fee.Set(b.maxGasPrice)
+		}
+	}
+}
+
+func (b *baseSwapper) newTransaction(nonce uint64, to common.Address, value *big.Int, gasLimit uint64, fees *GasFees, data []byte) *types.Transaction {
+	if fees.Dynamic() {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   b.chainID,
+			Nonce:     nonce,
+			GasTipCap: fees.GasTipCap,
+			GasFeeCap: fees.GasFeeCap,
+			Gas:       gasLimit,
+			To:        &to,
+			Value:     value,
+			Data:      data,
+		})
+	}
+	return types.NewTransaction(nonce, to, value, gasLimit, fees.GasPrice, data)
+}
+
+func (b *baseSwapper) signTransaction(tx *types.Transaction) (*types.Transaction, error) {
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(b.chainID), b.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	return signedTx, nil
+}
+
+// sendTransaction signs with a nonce from the shared manager so concurrent
+// buys, approvals and sells from one wallet never collide.
+func (b *baseSwapper) sendTransaction(method string, strategy GasStrategy, to common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	fees, err := b.fees(strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	gasLimit, err := b.gasLimitFor(method, ethereum.CallMsg{
+		From:      b.address,
+		To:        &to,
+		GasPrice:  fees.GasPrice,
+		GasFeeCap: fees.GasFeeCap,
+		GasTipCap: fees.GasTipCap,
+		Value:     value,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.send(to, value, gasLimit, fees, data)
+}
+
+// sendBehind sends a transaction that must be mined right after trigger,
+// which is still pending. It pays exactly the trigger's fees, so the
+// validator orders it behind the trigger rather than ahead of it, and skips
+// gas estimation, which would only revert against the pre-trigger state.
+func (b *baseSwapper) sendBehind(trigger *types.Transaction, method string, to common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	fees := txFees(trigger)
+	b.clampFees(fees, "Trigger "+trigger.Hash().Hex())
+	return b.send(to, value, b.fallbackGasLimit(method), fees, data)
+}
+
+func (b *baseSwapper) send(to common.Address, value *big.Int, gasLimit uint64, fees *GasFees, data []byte) (*types.Transaction, error) {
+	for attempt := 0; ; attempt++ {
+		nonce, err := b.nonces.Acquire(b.address)
+		if err != nil {
+			return nil, err
+		}
+
+		signedTx, err := b.signTransaction(b.newTransaction(nonce, to, value, gasLimit, fees, data))
+		if err != nil {
+			b.nonces.Release(b.address, nonce)
+			return nil, err
+		}
+
+		err = b.client.SendTransaction(context.Background(), signedTx)
+		if err == nil || isAlreadyKnown(err) {
+			b.track(signedTx)
+			return signedTx, nil
+		}
+
+		if isNonceTooLow(err) && attempt < maxNonceRetries {
+			log.Printf("[%s] Nonce %d rejected (%v), resyncing from chain", b.address.Hex(), nonce, err)
+			b.nonces.Release(b.address, nonce)
+			if syncErr := b.nonces.Sync(b.address); syncErr != nil {
+				return nil, fmt.Errorf("failed to send transaction: %w (resync: %v)", err, syncErr)
+			}
+			continue
+		}
+
+		b.nonces.Release(b.address, nonce)
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+}
+
+func (b *baseSwapper) track(tx *types.Transaction) {
+	b.tracker.Track(tx, b.address)
+	err := b.tracker.OnDone(tx.Hash(), func(result TxResult) {
+		if result.Status == TxDropped {
+			log.Printf("[%s] Tx %s dropped, settling nonce %d", b.address.Hex(), result.Hash.Hex(), tx.Nonce())
+			if err := b.nonces.Drop(b.address, tx.Nonce()); err != nil {
+				log.Printf("[%s] Failed to settle nonce %d, keeping it for reuse: %v", b.address.Hex(), tx.Nonce(), err)
+			}
+		}
+	})
+	if err != nil {
+		log.Printf("[%s] Failed to watch tx %s, nonce %d will not be settled if it drops: %v", b.address.Hex(), tx.Hash().Hex(), tx.Nonce(), err)
+	}
+}
+
+func (b *baseSwapper) WaitForTx(ctx context.Context, txHash string) (*TxResult, error) {
+	return b.tracker.Wait(ctx, common.HexToHash(txHash))
+}
+
+func (b *baseSwapper) OnTxDone(txHash string, cb func(TxResult)) error {
+	return b.tracker.OnDone(common.HexToHash(txHash), cb)
+}
+
+// SetTokenTax records a token's known tax, which replaces the default tax
+// in amountOutMin and in after-tax valuation.
+func (b *baseSwapper) SetTokenTax(tokenAddress common.Address, tax TokenTax) {
+	b.taxMu.Lock()
+	defer b.taxMu.Unlock()
+	b.taxes[tokenAddress] = tax
+}
+
+func (b *baseSwapper) GetTokenTax(tokenAddress common.Address) TokenTax {
+	return b.tokenTax(tokenAddress)
+}
+
+func (b *baseSwapper) tokenTax(tokenAddress common.Address) TokenTax {
+	b.taxMu.RLock()
+	defer b.taxMu.RUnlock()
+	if tax, ok := b.taxes[tokenAddress]; ok {
+		return tax
+	}
+	return b.defaultTax
+}
+
+// SetTokenQuote records the asset a token is paired against. Trades and
+// prices for the token then route through it, with BNB swapped in and out
+// on the same path; WBNB or the zero address restores the direct pair.
+func (b *baseSwapper) SetTokenQuote(tokenAddress, quote common.Address) {
+	b.quoteMu.Lock()
+	defer b.quoteMu.Unlock()
+	if quote == WBNB || quote == (common.Address{}) {
+		delete(b.quotes, tokenAddress)
+		return
+	}
+	b.quotes[tokenAddress] = quote
+}
+
+func (b *baseSwapper) tokenQuote(tokenAddress common.Address) common.Address {
+	b.quoteMu.RLock()
+	defer b.quoteMu.RUnlock()
+	if quote, ok := b.quotes[tokenAddress]; ok {
+		return quote
+	}
+	return WBNB
+}
+
+// buyPath is the V2 path from BNB to the token through its quote asset.
+func (b *baseSwapper) buyPath(tokenAddress common.Address) []common.Address {
+	if quote := b.tokenQuote(tokenAddress); quote != WBNB {
+		return []common.Address{WBNB, quote, tokenAddress}
+	}
+	return []common.Address{WBNB, tokenAddress}
+}
+
+// sellPath is buyPath reversed.
+func (b *baseSwapper) sellPath(tokenAddress common.Address) []common.Address {
+	if quote := b.tokenQuote(tokenAddress); quote != WBNB {
+		return []common.Address{tokenAddress, quote, WBNB}
+	}
+	return []common.Address{tokenAddress, WBNB}
+}
+
+// minAmountOut discounts a router quote by the configured slippage and the
+// token's transfer tax, since getAmountsOut knows nothing about either.
+func (b *baseSwapper) minAmountOut(quote *big.Int, taxBps int64) *big.Int {
+	keepBps := int64(bpsDenominator - b.slippage*100)
+	if keepBps < 0 {
+		keepBps = 0
+	}
+	afterTaxBps := bpsDenominator - taxBps
+	if afterTaxBps < 0 {
+		afterTaxBps = 0
+	}
+
+	minOut := new(big.Int).Mul(quote, big.NewInt(keepBps))
+	minOut.Mul(minOut, big.NewInt(afterTaxBps))
+	minOut.Div(minOut, big.NewInt(bpsDenominator*bpsDenominator))
+	return minOut
+}
+
+func (b *baseSwapper) GetAddress() common.Address {
+	return b.address
+}
+
+func (b *baseSwapper) GetTokenBalance(tokenAddress common.Address) (*big.Int, error) {
+	return b.tokenBalanceAt(tokenAddress, nil)
+}
+
+func (b *baseSwapper) tokenBalanceAt(tokenAddress common.Address, blockNumber *big.Int) (*big.Int, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(ERC20ABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ERC20 ABI: %w", err)
+	}
+
+	data, err := parsedABI.Pack("balanceOf", b.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack balanceOf: %w", err)
+	}
+
+	result, err := b.client.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &tokenAddress,
+		Data: data,
+	}, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call balanceOf: %w", err)
+	}
+
+	outputs, err := parsedABI.Unpack("balanceOf", result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack balanceOf: %w", err)
+	}
+
+	return outputs[0].(*big.Int), nil
+}
+
+// logRealizedBuy compares the balance right before and right after the mined
+// block, so the logged amount is what actually arrived after transfer tax.
+func (b *baseSwapper) logRealizedBuy(result TxResult, tokenAddress common.Address, quote, minOut *big.Int) {
+	txHash := result.Hash
+	if result.Status != TxMined {
+		log.Printf("[%s] Buy %s %s (quote: %s, min out: %s)", b.address.Hex(), txHash.Hex(), result.Status, quote.String(), minOut.String())
+		return
+	}
+	receipt := result.Receipt
+
+	before, err := b.tokenBalanceAt(tokenAddress, new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1)))
+	if err != nil {
+		log.Printf("[%s] Buy %s: failed to read balance before: %v", b.address.Hex(), txHash.Hex(), err)
+		return
+	}
+	after, err := b.tokenBalanceAt(tokenAddress, receipt.BlockNumber)
+	if err != nil {
+		log.Printf("[%s] Buy %s: failed to read balance after: %v", b.address.Hex(), txHash.Hex(), err)
+		return
+	}
+
+	realized := new(big.Int).Sub(after, before)
+	log.Printf("[%s] Buy %s realized: %s tokens (quote: %s, min out: %s)", b.address.Hex(), txHash.Hex(), realized.String(), quote.String(), minOut.String())
+}
+
+func (b *baseSwapper) logRealizedSell(result TxResult, tokenAddress common.Address, quote, minOut *big.Int) {
+	txHash := result.Hash
+	if result.Status != TxMined {
+		log.Printf("[%s] Sell %s of %s %s (quote: %s, min out: %s)", b.address.Hex(), txHash.Hex(), tokenAddress.Hex(), result.Status, quote.String(), minOut.String())
+		return
+	}
+	receipt := result.Receipt
+
+	before, err := b.client.BalanceAt(context.Background(), b.address, new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1)))
+	if err != nil {
+		log.Printf("[%s] Sell %s: failed to read balance before: %v", b.address.Hex(), txHash.Hex(), err)
+		return
+	}
+	after, err := b.client.BalanceAt(context.Background(), b.address, receipt.BlockNumber)
+	if err != nil {
+		log.Printf("[%s] Sell %s: failed to read balance after: %v", b.address.Hex(), txHash.Hex(), err)
+		return
+	}
+
+	gasCost := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice)
+	realized := new(big.Int).Sub(after, before)
+	realized.Add(realized, gasCost)
+	log.Printf("[%s] Sell %s realized: %s wei BNB (quote: %s, min out: %s)", b.address.Hex(), txHash.Hex(), realized.String(), quote.String(), minOut.String())
+}
+
+func (b *baseSwapper) approve(tokenAddress, spender common.Address, amount *big.Int) (string, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(ERC20ABI))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse ERC20 ABI: %w", err)
+	}
+
+	data, err := parsedABI.Pack("approve", spender, amount)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack approve: %w", err)
+	}
+
+	signedTx, err := b.sendTransaction(methodApprove, b.gas.Approve, tokenAddress, big.NewInt(0), data)
+	if err != nil {
+		return "", err
+	}
+
+	return signedTx.Hash().Hex(), nil
+}
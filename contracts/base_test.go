@@ -0,0 +1,96 @@
+package contracts
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMinAmountOut(t *testing.T) {
+	tests := []struct {
+		name     string
+		quote    *big.Int
+		slippage int
+		taxBps   int64
+		want     *big.Int
+	}{
+		{name: "no slippage, no tax", quote: big.NewInt(1000), want: big.NewInt(1000)},
+		{name: "slippage only", quote: big.NewInt(1000), slippage: 10, want: big.NewInt(900)},
+		{name: "tax only", quote: big.NewInt(1000), taxBps: 250, want: big.NewInt(975)},
+		{name: "slippage and tax multiply", quote: ether(1), slippage: 10, taxBps: 500, want: big.NewInt(855_000_000_000_000_000)},
+		{name: "rounds down", quote: big.NewInt(999), slippage: 1, taxBps: 333, want: big.NewInt(956)},
+		{name: "rounds once, not per factor", quote: big.NewInt(2), slippage: 10, taxBps: 1000, want: big.NewInt(1)},
+		{name: "full slippage", quote: big.NewInt(1000), slippage: 100, want: big.NewInt(0)},
+		{name: "slippage past 100% clamps", quote: big.NewInt(1000), slippage: 150, want: big.NewInt(0)},
+		{name: "full tax", quote: big.NewInt(1000), taxBps: bpsDenominator, want: big.NewInt(0)},
+		{name: "tax past 100% clamps", quote: big.NewInt(1000), taxBps: 20_000, want: big.NewInt(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &baseSwapper{slippage: tt.slippage}
+			if got := b.minAmountOut(tt.quote, tt.taxBps); got.Cmp(tt.want) != 0 {
+				t.Errorf("minAmountOut(%s, %d bps) at %d%% slippage = %s, want %s", tt.quote, tt.taxBps, tt.slippage, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTradePaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		quotes   []common.Address
+		wantBuy  []common.Address
+		wantSell []common.Address
+	}{
+		{
+			name:     "BNB launch trades directly",
+			wantBuy:  []common.Address{WBNB, testToken},
+			wantSell: []common.Address{testToken, WBNB},
+		},
+		{
+			name:     "quote launch hops through the quote",
+			quotes:   []common.Address{USDT},
+			wantBuy:  []common.Address{WBNB, USDT, testToken},
+			wantSell: []common.Address{testToken, USDT, WBNB},
+		},
+		{
+			name:     "latest quote wins",
+			quotes:   []common.Address{USDT, USDC},
+			wantBuy:  []common.Address{WBNB, USDC, testToken},
+			wantSell: []common.Address{testToken, USDC, WBNB},
+		},
+		{
+			name:     "WBNB quote clears an earlier one",
+			quotes:   []common.Address{USDT, WBNB},
+			wantBuy:  []common.Address{WBNB, testToken},
+			wantSell: []common.Address{testToken, WBNB},
+		},
+		{
+			name:     "zero quote clears an earlier one",
+			quotes:   []common.Address{USDT, {}},
+			wantBuy:  []common.Address{WBNB, testToken},
+			wantSell: []common.Address{testToken, WBNB},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &baseSwapper{quotes: make(map[common.Address]common.Address)}
+			for _, quote := range tt.quotes {
+				b.SetTokenQuote(testToken, quote)
+			}
+			// Another token's quote must not leak into this one's paths.
+			b.SetTokenQuote(testWallet, BUSD)
+
+			if got := b.buyPath(testToken); !reflect.DeepEqual(got, tt.wantBuy) {
+				t.Errorf("buyPath() = %s, want %s", formatPath(got), formatPath(tt.wantBuy))
+			}
+			if got := b.sellPath(testToken); !reflect.DeepEqual(got, tt.wantSell) {
+				t.Errorf("sellPath() = %s, want %s", formatPath(got), formatPath(tt.wantSell))
+			}
+		})
+	}
+}
@@ -1,75 +1,254 @@
 package contracts
 
 import (
-	"context"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
 
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
-var LiquidityAddedEventSig = crypto.Keccak256Hash([]byte("LiquidityAdded(address,uint256,address,uint256)"))
+const (
+	EventLiquidityAdded  = "LiquidityAdded"
+	EventTokenCreated    = "TokenCreated"
+	EventTokenBought     = "TokenBought"
+	EventTokenSold       = "TokenSold"
+	EventLaunchedToDEX   = "LaunchedToDEX"
+	EventFlapTokenTaxSet = "FlapTokenTaxSet"
+)
+
+// EventPendingLaunch names PendingLaunchEvent in logs and the decision
+// journal. It is not an on-chain event and cannot be subscribed to.
+const EventPendingLaunch = "PendingLaunch"
+
+const EventsABI = `[{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"base","type":"address"},{"indexed":false,"internalType":"uint256","name":"offers","type":"uint256"},{"indexed":false,"internalType":"address","name":"quote","type":"address"},{"indexed":false,"internalType":"uint256","name":"funds","type":"uint256"}],"name":"LiquidityAdded","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"uint256","name":"ts","type":"uint256"},{"indexed":false,"internalType":"address","name":"creator","type":"address"},{"indexed":false,"internalType":"uint256","name":"nonce","type":"uint256"},{"indexed":false,"internalType":"address","name":"token","type":"address"},{"indexed":false,"internalType":"string","name":"name","type":"string"},{"indexed":false,"internalType":"string","name":"symbol","type":"string"},{"indexed":false,"internalType":"string","name":"meta","type":"string"}],"name":"TokenCreated","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"uint256","name":"ts","type":"uint256"},{"indexed":false,"internalType":"address","name":"token","type":"address"},{"indexed":false,"internalType":"address","name":"buyer","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"eth","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"fee","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"postPrice","type":"uint256"}],"name":"TokenBought","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"uint256","name":"ts","type":"uint256"},{"indexed":false,"internalType":"address","name":"token","type":"address"},{"indexed":false,"internalType":"address","name":"seller","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"eth","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"fee","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"postPrice","type":"uint256"}],"name":"TokenSold","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"token","type":"address"},{"indexed":false,"internalType":"address","name":"pool","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"eth","type":"uint256"}],"name":"LaunchedToDEX","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"token","type":"address"},{"indexed":false,"internalType":"uint256","name":"tax","type":"uint256"}],"name":"FlapTokenTaxSet","type":"event"}]`
+
+var eventsABI = mustParseABI(EventsABI)
 
-var TokenManager = common.HexToAddress("0x5c952063c7fc8610FFDB798152D69F0B9550762b")
+var (
+	LiquidityAddedEventSig  = eventsABI.Events[EventLiquidityAdded].ID
+	TokenCreatedEventSig    = eventsABI.Events[EventTokenCreated].ID
+	TokenBoughtEventSig     = eventsABI.Events[EventTokenBought].ID
+	TokenSoldEventSig       = eventsABI.Events[EventTokenSold].ID
+	LaunchedToDEXEventSig   = eventsABI.Events[EventLaunchedToDEX].ID
+	FlapTokenTaxSetEventSig = eventsABI.Events[EventFlapTokenTaxSet].ID
+)
 
-const TokenTypeTax = 5
+// Event is any decoded launchpad event; Log is the raw log it came from.
+type Event interface {
+	EventName() string
+	Log() types.Log
+}
 
-const TokenInfoABI = `[{"inputs":[{"internalType":"address","name":"","type":"address"}],"name":"_tokenInfos","outputs":[{"internalType":"uint256","name":"template","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+type eventLog struct {
+	raw types.Log
+}
+
+func (e *eventLog) Log() types.Log {
+	return e.raw
+}
 
 type LiquidityAddedEvent struct {
+	eventLog
 	Base   common.Address
 	Offers *big.Int
 	Quote  common.Address
 	Funds  *big.Int
 }
 
-func ParseLiquidityAddedEvent(data []byte, topics []common.Hash) (*LiquidityAddedEvent, error) {
-	if len(data) < 128 {
-		return nil, fmt.Errorf("invalid data length: %d", len(data))
+func (e *LiquidityAddedEvent) EventName() string { return EventLiquidityAdded }
+
+type TokenCreatedEvent struct {
+	eventLog
+	Ts      *big.Int
+	Creator common.Address
+	Nonce   *big.Int
+	Token   common.Address
+	Name    string
+	Symbol  string
+	Meta    string
+}
+
+func (e *TokenCreatedEvent) EventName() string { return EventTokenCreated }
+
+type TokenBoughtEvent struct {
+	eventLog
+	Ts        *big.Int
+	Token     common.Address
+	Buyer     common.Address
+	Amount    *big.Int
+	Eth       *big.Int
+	Fee       *big.Int
+	PostPrice *big.Int
+}
+
+func (e *TokenBoughtEvent) EventName() string { return EventTokenBought }
+
+type TokenSoldEvent struct {
+	eventLog
+	Ts        *big.Int
+	Token     common.Address
+	Seller    common.Address
+	Amount    *big.Int
+	Eth       *big.Int
+	Fee       *big.Int
+	PostPrice *big.Int
+}
+
+func (e *TokenSoldEvent) EventName() string { return EventTokenSold }
+
+type LaunchedToDEXEvent struct {
+	eventLog
+	Token  common.Address
+	Pool   common.Address
+	Amount *big.Int
+	Eth    *big.Int
+}
+
+func (e *LaunchedToDEXEvent) EventName() string { return EventLaunchedToDEX }
+
+type TokenTaxSetEvent struct {
+	eventLog
+	Token common.Address
+	Tax   *big.Int
+}
+
+func (e *TokenTaxSetEvent) EventName() string { return EventFlapTokenTaxSet }
+
+// EventDecoder turns one log into its typed event.
+type EventDecoder func(vLog types.Log) (Event, error)
+
+// EventRegistry maps topic0 to the decoder for that event.
+var EventRegistry = map[common.Hash]EventDecoder{
+	LiquidityAddedEventSig:  func(vLog types.Log) (Event, error) { return ParseLiquidityAddedEvent(vLog) },
+	TokenCreatedEventSig:    func(vLog types.Log) (Event, error) { return ParseTokenCreatedEvent(vLog) },
+	TokenBoughtEventSig:     func(vLog types.Log) (Event, error) { return ParseTokenBoughtEvent(vLog) },
+	TokenSoldEventSig:       func(vLog types.Log) (Event, error) { return ParseTokenSoldEvent(vLog) },
+	LaunchedToDEXEventSig:   func(vLog types.Log) (Event, error) { return ParseLaunchedToDEXEvent(vLog) },
+	FlapTokenTaxSetEventSig: func(vLog types.Log) (Event, error) { return ParseTokenTaxSetEvent(vLog) },
+}
+
+// DecodeEvent looks the log up by topic0 and decodes it.
+func DecodeEvent(vLog types.Log) (Event, error) {
+	if len(vLog.Topics) == 0 {
+		return nil, fmt.Errorf("log has no topics")
+	}
+	decode, ok := EventRegistry[vLog.Topics[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown event topic %s", vLog.Topics[0].Hex())
+	}
+	return decode(vLog)
+}
+
+// EventTopics returns topic0 for each named event, for building a filter
+// query that subscribes to just those events.
+func EventTopics(names []string) ([]common.Hash, error) {
+	var topics []common.Hash
+	for _, name := range names {
+		event, ok := eventsABI.Events[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown event %q (known: %s)", name, strings.Join(EventNames(), ", "))
+		}
+		topics = append(topics, event.ID)
+	}
+	return topics, nil
+}
+
+func EventNames() []string {
+	var names []string
+	for name := range eventsABI.Events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeEvent checks topic0, the topic count and the data length against the
+// ABI before unpacking, so a log from a different contract that happens to
+// share the signature fails loudly instead of decoding garbage.
+func decodeEvent(name string, vLog types.Log, out interface{}) error {
+	event := eventsABI.Events[name]
+
+	indexed := 0
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed++
+		}
+	}
+	if len(vLog.Topics) != indexed+1 {
+		return fmt.Errorf("%s: expected %d topics, got %d", name, indexed+1, len(vLog.Topics))
+	}
+	if vLog.Topics[0] != event.ID {
+		return fmt.Errorf("%s: unexpected topic0 %s", name, vLog.Topics[0].Hex())
 	}
 
-	event := &LiquidityAddedEvent{
-		Base:   common.BytesToAddress(data[12:32]),
-		Offers: new(big.Int).SetBytes(data[32:64]),
-		Quote:  common.BytesToAddress(data[76:96]),
-		Funds:  new(big.Int).SetBytes(data[96:128]),
+	// Every non-indexed argument takes one head word; dynamic ones add a tail.
+	minLen := 32 * (len(event.Inputs) - indexed)
+	if len(vLog.Data) < minLen || len(vLog.Data)%32 != 0 {
+		return fmt.Errorf("%s: invalid data length: %d", name, len(vLog.Data))
 	}
 
+	if err := eventsABI.UnpackIntoInterface(out, name, vLog.Data); err != nil {
+		return fmt.Errorf("failed to unpack %s: %w", name, err)
+	}
+	return nil
+}
+
+func ParseLiquidityAddedEvent(vLog types.Log) (*LiquidityAddedEvent, error) {
+	event := &LiquidityAddedEvent{eventLog: eventLog{raw: vLog}}
+	if err := decodeEvent(EventLiquidityAdded, vLog, event); err != nil {
+		return nil, err
+	}
 	return event, nil
 }
 
-func IsTaxToken(client *ethclient.Client, tokenAddress common.Address) (bool, error) {
-	parsedABI, err := abi.JSON(strings.NewReader(TokenInfoABI))
-	if err != nil {
-		return false, fmt.Errorf("failed to parse ABI: %w", err)
+func ParseTokenCreatedEvent(vLog types.Log) (*TokenCreatedEvent, error) {
+	event := &TokenCreatedEvent{eventLog: eventLog{raw: vLog}}
+	if err := decodeEvent(EventTokenCreated, vLog, event); err != nil {
+		return nil, err
 	}
+	return event, nil
+}
 
-	data, err := parsedABI.Pack("_tokenInfos", tokenAddress)
-	if err != nil {
-		return false, fmt.Errorf("failed to pack _tokenInfos: %w", err)
+func ParseTokenBoughtEvent(vLog types.Log) (*TokenBoughtEvent, error) {
+	event := &TokenBoughtEvent{eventLog: eventLog{raw: vLog}}
+	if err := decodeEvent(EventTokenBought, vLog, event); err != nil {
+		return nil, err
 	}
+	return event, nil
+}
 
-	result, err := client.CallContract(context.Background(), ethereum.CallMsg{
-		To:   &TokenManager,
-		Data: data,
-	}, nil)
-	if err != nil {
-		return false, fmt.Errorf("failed to call _tokenInfos: %w", err)
+func ParseTokenSoldEvent(vLog types.Log) (*TokenSoldEvent, error) {
+	event := &TokenSoldEvent{eventLog: eventLog{raw: vLog}}
+	if err := decodeEvent(EventTokenSold, vLog, event); err != nil {
+		return nil, err
 	}
+	return event, nil
+}
 
-	outputs, err := parsedABI.Unpack("_tokenInfos", result)
-	if err != nil {
-		return false, fmt.Errorf("failed to unpack _tokenInfos: %w", err)
+func ParseLaunchedToDEXEvent(vLog types.Log) (*LaunchedToDEXEvent, error) {
+	event := &LaunchedToDEXEvent{eventLog: eventLog{raw: vLog}}
+	if err := decodeEvent(EventLaunchedToDEX, vLog, event); err != nil {
+		return nil, err
 	}
+	return event, nil
+}
 
-	template := outputs[0].(*big.Int)
-	creatorType := new(big.Int).Rsh(template, 10)
-	creatorType.And(creatorType, big.NewInt(0x3F))
+func ParseTokenTaxSetEvent(vLog types.Log) (*TokenTaxSetEvent, error) {
+	event := &TokenTaxSetEvent{eventLog: eventLog{raw: vLog}}
+	if err := decodeEvent(EventFlapTokenTaxSet, vLog, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
 
-	return creatorType.Int64() == TokenTypeTax, nil
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("invalid ABI: %v", err))
+	}
+	return parsed
 }
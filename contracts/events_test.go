@@ -0,0 +1,128 @@
+package contracts
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// eventTestLog ABI-encodes args as the data of a log carrying name's topic0.
+func eventTestLog(t *testing.T, name string, args ...interface{}) types.Log {
+	t.Helper()
+	event := eventsABI.Events[name]
+	data, err := event.Inputs.Pack(args...)
+	if err != nil {
+		t.Fatalf("pack %s: %v", name, err)
+	}
+	return types.Log{
+		Address: TokenManager,
+		Topics:  []common.Hash{event.ID},
+		Data:    data,
+		TxHash:  common.HexToHash("0xabc"),
+		Index:   1,
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	creator := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	pool := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+	ts := big.NewInt(1_700_000_000)
+
+	liquidity := eventTestLog(t, EventLiquidityAdded, testToken, ether(200_000_000), USDT, ether(24))
+	created := eventTestLog(t, EventTokenCreated, ts, creator, big.NewInt(7), testToken, "Test Token", "TEST", "ipfs://meta")
+	bought := eventTestLog(t, EventTokenBought, ts, testToken, creator, ether(1000), ether(1), big.NewInt(1e16), big.NewInt(42))
+	sold := eventTestLog(t, EventTokenSold, ts, testToken, creator, ether(500), ether(1), big.NewInt(1e16), big.NewInt(41))
+	launched := eventTestLog(t, EventLaunchedToDEX, testToken, pool, ether(200_000_000), ether(20))
+	taxSet := eventTestLog(t, EventFlapTokenTaxSet, testToken, big.NewInt(300))
+
+	tests := []struct {
+		name string
+		log  types.Log
+		want Event
+	}{
+		{
+			name: EventLiquidityAdded,
+			log:  liquidity,
+			want: &LiquidityAddedEvent{eventLog: eventLog{raw: liquidity}, Base: testToken, Offers: ether(200_000_000), Quote: USDT, Funds: ether(24)},
+		},
+		{
+			name: EventTokenCreated,
+			log:  created,
+			want: &TokenCreatedEvent{eventLog: eventLog{raw: created}, Ts: ts, Creator: creator, Nonce: big.NewInt(7), Token: testToken, Name: "Test Token", Symbol: "TEST", Meta: "ipfs://meta"},
+		},
+		{
+			name: EventTokenBought,
+			log:  bought,
+			want: &TokenBoughtEvent{eventLog: eventLog{raw: bought}, Ts: ts, Token: testToken, Buyer: creator, Amount: ether(1000), Eth: ether(1), Fee: big.NewInt(1e16), PostPrice: big.NewInt(42)},
+		},
+		{
+			name: EventTokenSold,
+			log:  sold,
+			want: &TokenSoldEvent{eventLog: eventLog{raw: sold}, Ts: ts, Token: testToken, Seller: creator, Amount: ether(500), Eth: ether(1), Fee: big.NewInt(1e16), PostPrice: big.NewInt(41)},
+		},
+		{
+			name: EventLaunchedToDEX,
+			log:  launched,
+			want: &LaunchedToDEXEvent{eventLog: eventLog{raw: launched}, Token: testToken, Pool: pool, Amount: ether(200_000_000), Eth: ether(20)},
+		},
+		{
+			name: EventFlapTokenTaxSet,
+			log:  taxSet,
+			want: &TokenTaxSetEvent{eventLog: eventLog{raw: taxSet}, Token: testToken, Tax: big.NewInt(300)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeEvent(tt.log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.EventName() != tt.name {
+				t.Errorf("EventName() = %s, want %s", got.EventName(), tt.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeEventRejects(t *testing.T) {
+	valid := eventTestLog(t, EventLiquidityAdded, testToken, ether(1), WBNB, ether(1))
+	with := func(change func(l *types.Log)) types.Log {
+		l := valid
+		l.Topics = append([]common.Hash(nil), valid.Topics...)
+		l.Data = append([]byte(nil), valid.Data...)
+		change(&l)
+		return l
+	}
+
+	tests := []struct {
+		name string
+		log  types.Log
+	}{
+		{name: "no topics", log: with(func(l *types.Log) { l.Topics = nil })},
+		{name: "unknown topic0", log: with(func(l *types.Log) { l.Topics[0] = common.HexToHash("0xdead") })},
+		{name: "extra topic", log: with(func(l *types.Log) { l.Topics = append(l.Topics, common.HexToHash("0x01")) })},
+		{name: "truncated data", log: with(func(l *types.Log) { l.Data = l.Data[:96] })},
+		{name: "data not word aligned", log: with(func(l *types.Log) { l.Data = append(l.Data, 0) })},
+		{name: "empty data", log: with(func(l *types.Log) { l.Data = nil })},
+		{name: "truncated string tail", log: func() types.Log {
+			l := eventTestLog(t, EventTokenCreated, big.NewInt(1), testWallet, big.NewInt(1), testToken, "Name", "SYM", "meta")
+			l.Data = l.Data[:7*32]
+			return l
+		}()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if event, err := DecodeEvent(tt.log); err == nil {
+				t.Errorf("decoded %+v, want an error", event)
+			}
+		})
+	}
+}
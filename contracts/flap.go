@@ -0,0 +1,331 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const FlapPortalABI = `[{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"address","name":"recipient","type":"address"},{"internalType":"uint256","name":"minAmount","type":"uint256"}],"name":"buy","outputs":[{"internalType":"uint256","name":"amount","type":"uint256"}],"stateMutability":"payable","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"},{"internalType":"uint256","name":"minEth","type":"uint256"}],"name":"sell","outputs":[{"internalType":"uint256","name":"eth","type":"uint256"}],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"uint256","name":"eth","type":"uint256"}],"name":"previewBuy","outputs":[{"internalType":"uint256","name":"amount","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"}],"name":"previewSell","outputs":[{"internalType":"uint256","name":"eth","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"}],"name":"getTokenV2","outputs":[],"stateMutability":"view","type":"function"}]`
+
+type FlapTokenStatus uint8
+
+const (
+	FlapStatusInvalid FlapTokenStatus = iota
+	FlapStatusTradable
+	FlapStatusInDuel
+	FlapStatusKilled
+	FlapStatusDEX
+	FlapStatusStaged
+)
+
+func (s FlapTokenStatus) String() string {
+	switch s {
+	case FlapStatusInvalid:
+		return "invalid"
+	case FlapStatusTradable:
+		return "tradable"
+	case FlapStatusInDuel:
+		return "in duel"
+	case FlapStatusKilled:
+		return "killed"
+	case FlapStatusDEX:
+		return "dex"
+	case FlapStatusStaged:
+		return "staged"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
+
+// ErrNotApproved is returned by a sell that needs a fresh approval first,
+// e.g. because the token moved from the portal to a DEX router.
+var ErrNotApproved = errors.New("token not approved for current venue")
+
+// GetFlapTokenStatus reads the status of a token on the portal. Every
+// getToken* struct starts with the status, so only the first word is decoded
+// and the call keeps working as the struct grows fields.
+func GetFlapTokenStatus(ctx context.Context, client *ethclient.Client, portal, tokenAddress common.Address) (FlapTokenStatus, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(FlapPortalABI))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse ABI: %w", err)
+	}
+
+	data, err := parsedABI.Pack("getTokenV2", tokenAddress)
+	if err != nil {
+		return 0, fmt.Errorf("failed to pack getTokenV2: %w", err)
+	}
+
+	result, err := client.CallContract(ctx, ethereum.CallMsg{
+		To:   &portal,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call getTokenV2: %w", err)
+	}
+	if len(result) < 32 {
+		return 0, fmt.Errorf("invalid getTokenV2 result length: %d", len(result))
+	}
+
+	return FlapTokenStatus(new(big.Int).SetBytes(result[:32]).Uint64()), nil
+}
+
+// CurveSwapper trades on the Flap portal's bonding curve while a token is
+// still there and hands everything over to the DEX swapper once the token
+// has migrated, so a position opened on the curve keeps being priced and
+// exited after graduation without the caller noticing.
+type CurveSwapper struct {
+	*baseSwapper
+	portal      common.Address
+	dex         Swapper
+	migrated    map[common.Address]bool
+	dexApproved map[common.Address]bool
+	mu          sync.RWMutex
+}
+
+func NewCurveSwapper(client *ethclient.Client, nonces *NonceManager, tracker *TxTracker, privateKeyHex string, portal common.Address, dex Swapper, cfg SwapperConfig) (*CurveSwapper, error) {
+	base, err := newBaseSwapper(client, nonces, tracker, privateKeyHex, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if dex.GetAddress() != base.address {
+		return nil, fmt.Errorf("DEX swapper uses wallet %s, expected %s", dex.GetAddress().Hex(), base.address.Hex())
+	}
+
+	return &CurveSwapper{
+		baseSwapper: base,
+		portal:      portal,
+		dex:         dex,
+		migrated:    make(map[common.Address]bool),
+		dexApproved: make(map[common.Address]bool),
+	}, nil
+}
+
+// onCurve reports whether the token still trades on the portal. Migration is
+// one-way, so once a token is seen on the DEX the portal is not asked again.
+func (c *CurveSwapper) onCurve(tokenAddress common.Address) (bool, error) {
+	c.mu.RLock()
+	migrated := c.migrated[tokenAddress]
+	c.mu.RUnlock()
+	if migrated {
+		return false, nil
+	}
+
+	status, err := GetFlapTokenStatus(context.Background(), c.client, c.portal, tokenAddress)
+	if err != nil {
+		return false, err
+	}
+
+	switch status {
+	case FlapStatusTradable:
+		return true, nil
+	case FlapStatusDEX:
+		c.mu.Lock()
+		c.migrated[tokenAddress] = true
+		c.mu.Unlock()
+		log.Printf("[%s] %s migrated to DEX, switching price source", c.address.Hex(), tokenAddress.Hex())
+		return false, nil
+	default:
+		return false, fmt.Errorf("token %s is %s on the portal", tokenAddress.Hex(), status)
+	}
+}
+
+func (c *CurveSwapper) callPortal(method string, args ...interface{}) (*big.Int, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(FlapPortalABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ABI: %w", err)
+	}
+
+	data, err := parsedABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", method, err)
+	}
+
+	result, err := c.client.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &c.portal,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", method, err)
+	}
+
+	outputs, err := parsedABI.Unpack(method, result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s: %w", method, err)
+	}
+
+	return outputs[0].(*big.Int), nil
+}
+
+// SetTokenQuote only matters once the token trades on the DEX, so it is
+// passed on to the DEX swapper as well.
+func (c *CurveSwapper) SetTokenQuote(tokenAddress, quote common.Address) {
+	c.baseSwapper.SetTokenQuote(tokenAddress, quote)
+	c.dex.SetTokenQuote(tokenAddress, quote)
+}
+
+func (c *CurveSwapper) SetTokenTax(tokenAddress common.Address, tax TokenTax) {
+	c.baseSwapper.SetTokenTax(tokenAddress, tax)
+	c.dex.SetTokenTax(tokenAddress, tax)
+}
+
+func (c *CurveSwapper) QuoteBuy(tokenAddress common.Address, amountBNB *big.Int) (*big.Int, error) {
+	curve, err := c.onCurve(tokenAddress)
+	if err != nil {
+		return nil, err
+	}
+	if !curve {
+		return c.dex.QuoteBuy(tokenAddress, amountBNB)
+	}
+	return c.callPortal("previewBuy", tokenAddress, amountBNB)
+}
+
+func (c *CurveSwapper) BuyToken(tokenAddress common.Address, amountBNB *big.Int) (string, error) {
+	curve, err := c.onCurve(tokenAddress)
+	if err != nil {
+		return "", err
+	}
+	if !curve {
+		return c.dex.BuyToken(tokenAddress, amountBNB)
+	}
+
+	quote, err := c.callPortal("previewBuy", tokenAddress, amountBNB)
+	if err != nil {
+		return "", fmt.Errorf("failed to quote buy: %w", err)
+	}
+	amountOutMin := c.minAmountOut(quote, c.tokenTax(tokenAddress).BuyBps)
+	log.Printf("[%s] Curve buy quote for %s: %s tokens, min out: %s", c.address.Hex(), tokenAddress.Hex(), quote.String(), amountOutMin.String())
+
+	parsedABI, err := abi.JSON(strings.NewReader(FlapPortalABI))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse ABI: %w", err)
+	}
+	data, err := parsedABI.Pack("buy", tokenAddress, c.address, amountOutMin)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack data: %w", err)
+	}
+
+	signedTx, err := c.sendTransaction(methodBuy, c.gas.Buy, c.portal, amountBNB, data)
+	if err != nil {
+		return "", err
+	}
+
+	c.OnTxDone(signedTx.Hash().Hex(), func(result TxResult) {
+		c.logRealizedBuy(result, tokenAddress, quote, amountOutMin)
+	})
+
+	return signedTx.Hash().Hex(), nil
+}
+
+// BackrunBuy goes to the DEX swapper: a pending launch is a TokenManager
+// token, never a portal one.
+func (c *CurveSwapper) BackrunBuy(trigger *types.Transaction, tokenAddress common.Address, amountBNB *big.Int, pool LaunchPool) (string, error) {
+	return c.dex.BackrunBuy(trigger, tokenAddress, amountBNB, pool)
+}
+
+func (c *CurveSwapper) SellToken(tokenAddress common.Address, amount *big.Int) (string, error) {
+	curve, err := c.onCurve(tokenAddress)
+	if err != nil {
+		return "", err
+	}
+	if !curve {
+		c.mu.RLock()
+		approved := c.dexApproved[tokenAddress]
+		c.mu.RUnlock()
+		if !approved {
+			return "", fmt.Errorf("%s: %w", tokenAddress.Hex(), ErrNotApproved)
+		}
+		return c.dex.SellToken(tokenAddress, amount)
+	}
+
+	quote, err := c.callPortal("previewSell", tokenAddress, amount)
+	if err != nil {
+		return "", fmt.Errorf("failed to quote sell: %w", err)
+	}
+	amountOutMin := c.minAmountOut(quote, c.tokenTax(tokenAddress).SellBps)
+	log.Printf("[%s] Curve sell quote for %s: %s wei BNB, min out: %s", c.address.Hex(), tokenAddress.Hex(), quote.String(), amountOutMin.String())
+
+	parsedABI, err := abi.JSON(strings.NewReader(FlapPortalABI))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse ABI: %w", err)
+	}
+	data, err := parsedABI.Pack("sell", tokenAddress, amount, amountOutMin)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack data: %w", err)
+	}
+
+	signedTx, err := c.sendTransaction(methodSell, c.gas.Sell, c.portal, big.NewInt(0), data)
+	if err != nil {
+		return "", err
+	}
+
+	c.OnTxDone(signedTx.Hash().Hex(), func(result TxResult) {
+		c.logRealizedSell(result, tokenAddress, quote, amountOutMin)
+	})
+
+	return signedTx.Hash().Hex(), nil
+}
+
+// ApproveToken approves whichever contract currently trades the token. An
+// approval given to the portal is useless after migration, which is why a
+// DEX sell without its own approval returns ErrNotApproved.
+func (c *CurveSwapper) ApproveToken(tokenAddress common.Address, amount *big.Int) (string, error) {
+	curve, err := c.onCurve(tokenAddress)
+	if err != nil {
+		return "", err
+	}
+	if curve {
+		return c.ensureApproval(tokenAddress, c.portal, amount)
+	}
+
+	txHash, err := c.dex.ApproveToken(tokenAddress, amount)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.dexApproved[tokenAddress] = true
+	c.mu.Unlock()
+	return txHash, nil
+}
+
+func (c *CurveSwapper) GetTokenPrice(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+	curve, err := c.onCurve(tokenAddress)
+	if err != nil {
+		return nil, err
+	}
+	if !curve {
+		return c.dex.GetTokenPrice(tokenAddress, amount)
+	}
+	return c.callPortal("previewSell", tokenAddress, amount)
+}
+
+// GetTokenPriceInUSDT values curve tokens in BNB through the portal and
+// converts that to USDT with the DEX's WBNB/USDT quote.
+func (c *CurveSwapper) GetTokenPriceInUSDT(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+	curve, err := c.onCurve(tokenAddress)
+	if err != nil {
+		return nil, err
+	}
+	if !curve {
+		return c.dex.GetTokenPriceInUSDT(tokenAddress, amount)
+	}
+
+	amountBNB, err := c.callPortal("previewSell", tokenAddress, amount)
+	if err != nil {
+		return nil, err
+	}
+	if amountBNB.Sign() == 0 {
+		return amountBNB, nil
+	}
+	return c.dex.QuoteBuy(USDT, amountBNB)
+}
@@ -0,0 +1,213 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// GasFees is either a legacy gas price or, when GasTipCap is set, the tip and
+// fee cap of a DynamicFeeTx.
+type GasFees struct {
+	GasPrice  *big.Int
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+}
+
+func (f *GasFees) Dynamic() bool {
+	return f.GasTipCap != nil
+}
+
+// txFees copies the fees a transaction pays.
+func txFees(tx *types.Transaction) *GasFees {
+	if tx.Type() == types.DynamicFeeTxType {
+		return &GasFees{GasTipCap: new(big.Int).Set(tx.GasTipCap()), GasFeeCap: new(big.Int).Set(tx.GasFeeCap())}
+	}
+	return &GasFees{GasPrice: new(big.Int).Set(tx.GasPrice())}
+}
+
+func (f *GasFees) String() string {
+	if f.Dynamic() {
+		return fmt.Sprintf("tip %s wei, fee cap %s wei", f.GasTipCap.String(), f.GasFeeCap.String())
+	}
+	return fmt.Sprintf("gas price %s wei", f.GasPrice.String())
+}
+
+type GasStrategy interface {
+	Name() string
+	Fees(ctx context.Context) (*GasFees, error)
+}
+
+type GasStrategies struct {
+	Buy     GasStrategy
+	Sell    GasStrategy
+	Approve GasStrategy
+}
+
+type GasStrategyConfig struct {
+	GasPriceGwei         int64
+	SuggestMultiplier    float64
+	FeeHistoryBlocks     uint64
+	FeeHistoryPercentile float64
+	TipGwei              float64
+	BaseFeeMultiplier    float64
+}
+
+func NewGasStrategy(client *ethclient.Client, name string, cfg GasStrategyConfig) (GasStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "fixed":
+		return NewFixedGasStrategy(gweiToWei(float64(cfg.GasPriceGwei))), nil
+	case "suggest":
+		return &SuggestedGasStrategy{client: client, multiplier: cfg.SuggestMultiplier}, nil
+	case "feehistory":
+		return &FeeHistoryGasStrategy{client: client, blocks: cfg.FeeHistoryBlocks, percentile: cfg.FeeHistoryPercentile}, nil
+	case "eip1559":
+		var tip *big.Int
+		if cfg.TipGwei > 0 {
+			tip = gweiToWei(cfg.TipGwei)
+		}
+		return &DynamicFeeGasStrategy{client: client, tipCap: tip, baseFeeMultiplier: cfg.BaseFeeMultiplier}, nil
+	default:
+		return nil, fmt.Errorf("unknown gas strategy %q", name)
+	}
+}
+
+type FixedGasStrategy struct {
+	gasPrice *big.Int
+}
+
+func NewFixedGasStrategy(gasPrice *big.Int) *FixedGasStrategy {
+	return &FixedGasStrategy{gasPrice: gasPrice}
+}
+
+func (s *FixedGasStrategy) Name() string {
+	return "fixed"
+}
+
+func (s *FixedGasStrategy) Fees(ctx context.Context) (*GasFees, error) {
+	return &GasFees{GasPrice: new(big.Int).Set(s.gasPrice)}, nil
+}
+
+type SuggestedGasStrategy struct {
+	client     *ethclient.Client
+	multiplier float64
+}
+
+func (s *SuggestedGasStrategy) Name() string {
+	return "suggest"
+}
+
+func (s *SuggestedGasStrategy) Fees(ctx context.Context) (*GasFees, error) {
+	price, err := s.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to suggest gas price: %w", err)
+	}
+	return &GasFees{GasPrice: mulFloat(price, s.multiplier)}, nil
+}
+
+// FeeHistoryGasStrategy prices a legacy transaction at the latest base fee
+// plus the median, over the last few blocks, of each block's tip percentile.
+type FeeHistoryGasStrategy struct {
+	client     *ethclient.Client
+	blocks     uint64
+	percentile float64
+}
+
+func (s *FeeHistoryGasStrategy) Name() string {
+	return "feehistory"
+}
+
+func (s *FeeHistoryGasStrategy) Fees(ctx context.Context) (*GasFees, error) {
+	tip, baseFee, err := feeHistoryTip(ctx, s.client, s.blocks, s.percentile)
+	if err != nil {
+		return nil, err
+	}
+	return &GasFees{GasPrice: new(big.Int).Add(baseFee, tip)}, nil
+}
+
+type DynamicFeeGasStrategy struct {
+	client            *ethclient.Client
+	tipCap            *big.Int
+	baseFeeMultiplier float64
+}
+
+func (s *DynamicFeeGasStrategy) Name() string {
+	return "eip1559"
+}
+
+func (s *DynamicFeeGasStrategy) Fees(ctx context.Context) (*GasFees, error) {
+	tip := s.tipCap
+	if tip == nil {
+		suggested, err := s.client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to suggest gas tip: %w", err)
+		}
+		tip = suggested
+	}
+
+	head, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest header: %w", err)
+	}
+	baseFee := big.NewInt(0)
+	if head.BaseFee != nil {
+		baseFee = head.BaseFee
+	}
+
+	multiplier := s.baseFeeMultiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	feeCap := new(big.Int).Add(mulFloat(baseFee, multiplier), tip)
+
+	return &GasFees{GasTipCap: new(big.Int).Set(tip), GasFeeCap: feeCap}, nil
+}
+
+func feeHistoryTip(ctx context.Context, client *ethclient.Client, blocks uint64, percentile float64) (*big.Int, *big.Int, error) {
+	if blocks == 0 {
+		blocks = 20
+	}
+	if percentile <= 0 || percentile > 100 {
+		percentile = 50
+	}
+
+	history, err := client.FeeHistory(ctx, blocks, nil, []float64{percentile})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get fee history: %w", err)
+	}
+
+	var tips []*big.Int
+	for _, reward := range history.Reward {
+		if len(reward) > 0 && reward[0] != nil {
+			tips = append(tips, reward[0])
+		}
+	}
+	if len(tips) == 0 {
+		return nil, nil, fmt.Errorf("fee history returned no rewards")
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+
+	baseFee := big.NewInt(0)
+	if n := len(history.BaseFee); n > 0 && history.BaseFee[n-1] != nil {
+		baseFee = history.BaseFee[n-1]
+	}
+
+	return new(big.Int).Set(tips[len(tips)/2]), baseFee, nil
+}
+
+func gweiToWei(gwei float64) *big.Int {
+	return mulFloat(big.NewInt(1e9), gwei)
+}
+
+func mulFloat(value *big.Int, multiplier float64) *big.Int {
+	if multiplier <= 0 {
+		return new(big.Int).Set(value)
+	}
+	result, _ := new(big.Float).Mul(new(big.Float).SetInt(value), big.NewFloat(multiplier)).Int(nil)
+	return result
+}
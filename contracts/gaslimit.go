@@ -0,0 +1,173 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const estimateGasTimeout = 3 * time.Second
+
+const (
+	methodBuy     = "buy"
+	methodSell    = "sell"
+	methodApprove = "approve"
+)
+
+type GasLimitConfig struct {
+	Default    uint64
+	Multiplier float64
+	Floor      uint64
+	Ceiling    uint64
+	FastBuy    bool
+}
+
+type GasEstimateKind int
+
+const (
+	GasEstimateReverted GasEstimateKind = iota
+	GasEstimateInsufficientFunds
+	GasEstimateAboveCeiling
+)
+
+func (k GasEstimateKind) String() string {
+	switch k {
+	case GasEstimateReverted:
+		return "reverted"
+	case GasEstimateInsufficientFunds:
+		return "insufficient funds"
+	case GasEstimateAboveCeiling:
+		return "above ceiling"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(k))
+	}
+}
+
+var ErrWouldFail = errors.New("transaction would fail")
+
+// GasEstimateError means the node already told us the transaction cannot
+// succeed, so it is returned instead of sending it.
+type GasEstimateError struct {
+	Method string
+	Kind   GasEstimateKind
+	Reason string
+}
+
+func (e *GasEstimateError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s: gas estimation %s", e.Method, e.Kind)
+	}
+	return fmt.Sprintf("%s: gas estimation %s: %s", e.Method, e.Kind, e.Reason)
+}
+
+func (e *GasEstimateError) Unwrap() error {
+	return ErrWouldFail
+}
+
+type gasLimitCache struct {
+	limits map[string]uint64
+	mu     sync.RWMutex
+}
+
+func (c *gasLimitCache) get(method string) (uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	limit, ok := c.limits[method]
+	return limit, ok
+}
+
+func (c *gasLimitCache) set(method string, limit uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limits[method] = limit
+}
+
+// gasLimitFor estimates the call and pads it by the configured multiplier.
+// Fast buys skip estimation once a limit for the method is cached; a node
+// that fails to answer falls back to the cache or the configured default.
+func (b *baseSwapper) gasLimitFor(method string, msg ethereum.CallMsg) (uint64, error) {
+	cached, hasCached := b.gasLimitCache.get(method)
+	if method == methodBuy && b.gasLimits.FastBuy && hasCached {
+		return cached, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), estimateGasTimeout)
+	defer cancel()
+
+	estimated, err := b.client.EstimateGas(ctx, msg)
+	if err != nil {
+		if estimateErr := classifyEstimateError(method, err); estimateErr != nil {
+			return 0, estimateErr
+		}
+		fallback := b.fallbackGasLimit(method)
+		log.Printf("[%s] Gas estimation for %s unavailable (%v), using %d", b.address.Hex(), method, err, fallback)
+		return fallback, nil
+	}
+
+	if b.gasLimits.Ceiling > 0 && estimated > b.gasLimits.Ceiling {
+		return 0, &GasEstimateError{
+			Method: method,
+			Kind:   GasEstimateAboveCeiling,
+			Reason: fmt.Sprintf("estimated %d > ceiling %d", estimated, b.gasLimits.Ceiling),
+		}
+	}
+
+	limit := estimated
+	if b.gasLimits.Multiplier > 0 {
+		limit = uint64(float64(estimated) * b.gasLimits.Multiplier)
+	}
+	if limit < b.gasLimits.Floor {
+		limit = b.gasLimits.Floor
+	}
+	if b.gasLimits.Ceiling > 0 && limit > b.gasLimits.Ceiling {
+		limit = b.gasLimits.Ceiling
+	}
+
+	b.gasLimitCache.set(method, limit)
+	return limit, nil
+}
+
+// fallbackGasLimit is the limit to use without an estimate: the last one
+// cached for the method, or the configured default.
+func (b *baseSwapper) fallbackGasLimit(method string) uint64 {
+	if cached, ok := b.gasLimitCache.get(method); ok {
+		return cached
+	}
+	return b.gasLimits.Default
+}
+
+func classifyEstimateError(method string, err error) *GasEstimateError {
+	msg := strings.ToLower(err.Error())
+
+	switch {
+	case strings.Contains(msg, "insufficient funds"):
+		return &GasEstimateError{Method: method, Kind: GasEstimateInsufficientFunds, Reason: err.Error()}
+	case strings.Contains(msg, "execution reverted"), strings.Contains(msg, "gas required exceeds"):
+		return &GasEstimateError{Method: method, Kind: GasEstimateReverted, Reason: revertReason(err)}
+	default:
+		return nil
+	}
+}
+
+func revertReason(err error) string {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			if raw, decodeErr := hexutil.Decode(data); decodeErr == nil {
+				if reason, unpackErr := abi.UnpackRevert(raw); unpackErr == nil {
+					return reason
+				}
+			}
+		}
+	}
+	return err.Error()
+}
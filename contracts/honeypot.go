@@ -0,0 +1,453 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// The executor only ever exists inside an eth_call state override, so any
+// address without code on chain works.
+var honeypotExecutor = common.HexToAddress("0x00000000000000000000000000000000f1a95e11")
+
+// Stages reported by the executor: how far the round trip got.
+const (
+	HoneypotStageBuyFailed = iota
+	HoneypotStageApproveFailed
+	HoneypotStageSellFailed
+	HoneypotStageOK
+)
+
+// Executors for a direct BNB pair and for a pair against another quote
+// asset, which is reached through WBNB.
+var (
+	honeypotExecutorCode    = buildHoneypotExecutor(false)
+	honeypotExecutorViaCode = buildHoneypotExecutor(true)
+)
+
+type HoneypotResult struct {
+	Stage          int
+	TokensQuoted   *big.Int
+	TokensReceived *big.Int
+	SellQuoted     *big.Int
+	BNBIn          *big.Int
+	BNBOut         *big.Int
+	BuyTaxBps      int64
+	SellTaxBps     int64
+	LossBps        int64
+}
+
+func (r *HoneypotResult) Sellable() bool {
+	return r.Stage == HoneypotStageOK && r.BNBOut.Sign() > 0
+}
+
+func (r *HoneypotResult) String() string {
+	switch r.Stage {
+	case HoneypotStageBuyFailed:
+		return "buy reverted"
+	case HoneypotStageApproveFailed:
+		return "balanceOf/approve reverted after buy"
+	case HoneypotStageSellFailed:
+		return fmt.Sprintf("sell reverted (bought %s tokens, buy tax %.2f%%)", r.TokensReceived.String(), bpsToPercent(r.BuyTaxBps))
+	default:
+		return fmt.Sprintf("buy tax %.2f%%, sell tax %.2f%%, round-trip loss %.2f%% (%s -> %s wei)",
+			bpsToPercent(r.BuyTaxBps), bpsToPercent(r.SellTaxBps), bpsToPercent(r.LossBps), r.BNBIn.String(), r.BNBOut.String())
+	}
+}
+
+type HoneypotChecker struct {
+	client     *ethclient.Client
+	router     common.Address
+	amountIn   *big.Int
+	maxLossBps int64
+}
+
+func NewHoneypotChecker(client *ethclient.Client, router common.Address, amountIn *big.Int, maxLossPercent float64) *HoneypotChecker {
+	return &HoneypotChecker{
+		client:     client,
+		router:     router,
+		amountIn:   amountIn,
+		maxLossBps: int64(maxLossPercent * 100),
+	}
+}
+
+// Check simulates buying with amountIn and immediately selling everything
+// back in a single eth_call. The taxes are inferred by comparing what
+// arrived with what getAmountsOut promised; the sell side comparison is
+// slightly optimistic for taxed tokens because the pool curve is convex.
+func (h *HoneypotChecker) Check(ctx context.Context, tokenAddress common.Address) (*HoneypotResult, bool, error) {
+	return h.CheckQuote(ctx, tokenAddress, WBNB)
+}
+
+// CheckQuote is Check for a token paired against quote; a quote other than
+// WBNB is bought and sold through, as the swappers do.
+func (h *HoneypotChecker) CheckQuote(ctx context.Context, tokenAddress, quote common.Address) (*HoneypotResult, bool, error) {
+	path := []common.Address{WBNB, tokenAddress}
+	code := honeypotExecutorCode
+	if quote != WBNB && quote != (common.Address{}) {
+		path = []common.Address{WBNB, quote, tokenAddress}
+		code = honeypotExecutorViaCode
+	}
+
+	amounts, err := getAmountsOut(ctx, h.client, h.router, h.amountIn, path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to quote simulated buy: %w", err)
+	}
+
+	msg := map[string]interface{}{
+		"to":   honeypotExecutor,
+		"data": hexutil.Bytes(honeypotInput(h.router, tokenAddress, h.amountIn, quote)),
+	}
+	// eth_call's third parameter is the state override set, keyed by address.
+	overrides := map[common.Address]map[string]interface{}{
+		honeypotExecutor: {
+			"code":    hexutil.Bytes(code),
+			"balance": (*hexutil.Big)(h.amountIn),
+		},
+	}
+
+	var output hexutil.Bytes
+	err = h.client.Client().CallContext(ctx, &output, "eth_call", msg, "latest", overrides)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to simulate round trip: %w", err)
+	}
+	result, err := decodeHoneypotOutput(output, amounts[len(amounts)-1], h.amountIn)
+	if err != nil {
+		return nil, false, err
+	}
+
+	safe := result.Sellable() && result.LossBps <= h.maxLossBps
+	return result, safe, nil
+}
+
+// honeypotInput is the executor's calldata: router, token, wbnb, amountIn
+// and quote, one word each.
+func honeypotInput(router, tokenAddress common.Address, amountIn *big.Int, quote common.Address) []byte {
+	input := make([]byte, 0, 160)
+	input = append(input, common.LeftPadBytes(router.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(tokenAddress.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(WBNB.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(amountIn.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(quote.Bytes(), 32)...)
+	return input
+}
+
+// decodeHoneypotOutput reads the executor's result words and works out the
+// taxes against tokensQuoted, what getAmountsOut promised for the buy.
+func decodeHoneypotOutput(output []byte, tokensQuoted, amountIn *big.Int) (*HoneypotResult, error) {
+	if len(output) < 128 {
+		return nil, fmt.Errorf("invalid simulation output length: %d", len(output))
+	}
+
+	result := &HoneypotResult{
+		Stage:          int(new(big.Int).SetBytes(output[0:32]).Int64()),
+		TokensQuoted:   tokensQuoted,
+		TokensReceived: new(big.Int).SetBytes(output[32:64]),
+		SellQuoted:     new(big.Int).SetBytes(output[64:96]),
+		BNBIn:          amountIn,
+		BNBOut:         new(big.Int).SetBytes(output[96:128]),
+	}
+	if result.Stage > HoneypotStageBuyFailed {
+		result.BuyTaxBps = shortfallBps(result.TokensQuoted, result.TokensReceived)
+	}
+	if result.Stage == HoneypotStageOK {
+		result.SellTaxBps = shortfallBps(result.SellQuoted, result.BNBOut)
+	}
+	result.LossBps = shortfallBps(result.BNBIn, result.BNBOut)
+	return result, nil
+}
+
+func (h *HoneypotChecker) MaxLossPercent() float64 {
+	return bpsToPercent(h.maxLossBps)
+}
+
+// shortfallBps is how much less than expected arrived, in basis points.
+func shortfallBps(expected, actual *big.Int) int64 {
+	if expected.Sign() <= 0 {
+		return 0
+	}
+	if actual.Cmp(expected) >= 0 {
+		return 0
+	}
+	diff := new(big.Int).Sub(expected, actual)
+	diff.Mul(diff, big.NewInt(bpsDenominator))
+	diff.Div(diff, expected)
+	return diff.Int64()
+}
+
+func bpsToPercent(bps int64) float64 {
+	return float64(bps) / 100
+}
+
+func selector(signature string) []byte {
+	return crypto.Keccak256([]byte(signature))[:4]
+}
+
+// buildHoneypotExecutor assembles the round-trip contract. Calldata is five
+// words: router, token, wbnb, amountIn, quote; quote is only read when via is
+// set, making the path [wbnb, quote, token]. It returns four words: stage,
+// tokens received, getAmountsOut quote for selling them, BNB received.
+// Calls with empty calldata just accept BNB, which is how the router pays
+// out the sell.
+//
+// Memory: 0x00-0x80 call output, 0x100+ outgoing calldata, 0x400-0x480
+// result words, 0x480 BNB balance before the sell.
+func buildHoneypotExecutor(via bool) []byte {
+	const (
+		router = 0x00
+		token  = 0x20
+		wbnb   = 0x40
+		amount = 0x60
+		quote  = 0x80
+
+		callBuf   = 0x100
+		args      = callBuf + 4
+		resStage  = 0x400
+		resTokens = 0x420
+		resQuote  = 0x440
+		resBNB    = 0x460
+		bnbBefore = 0x480
+	)
+
+	buyPath := []uint64{wbnb, token}
+	sellPath := []uint64{token, wbnb}
+	if via {
+		buyPath = []uint64{wbnb, quote, token}
+		sellPath = []uint64{token, quote, wbnb}
+	}
+	hops := uint64(len(buyPath))
+
+	a := newAssembler()
+
+	a.op(opCalldataSize, opIsZero)
+	a.jumpi("receive")
+
+	// router.swapExactETHForTokensSupportingFeeOnTransferTokens{value: amountIn}(0, buyPath, this, now)
+	a.mstoreSelector(callBuf, "swapExactETHForTokensSupportingFeeOnTransferTokens(uint256,address[],address,uint256)")
+	a.mstore(args+0x00, func() { a.push(0) })
+	a.mstore(args+0x20, func() { a.push(0x80) })
+	a.mstore(args+0x40, func() { a.op(opAddress) })
+	a.mstore(args+0x60, func() { a.op(opTimestamp) })
+	a.path(args+0x80, buyPath)
+	a.call(func() { a.calldata(router) }, func() { a.calldata(amount) }, callBuf, 4+0xa0+hops*0x20, 0, 0)
+	a.op(opIsZero)
+	a.jumpi("buyFailed")
+
+	// token.balanceOf(this)
+	a.mstoreSelector(callBuf, "balanceOf(address)")
+	a.mstore(args, func() { a.op(opAddress) })
+	a.staticcall(func() { a.calldata(token) }, callBuf, 4+0x20, 0, 0x20)
+	a.op(opIsZero)
+	a.jumpi("approveFailed")
+	a.mstore(resTokens, func() { a.mload(0) })
+
+	// token.approve(router, balance)
+	a.mstoreSelector(callBuf, "approve(address,uint256)")
+	a.mstore(args+0x00, func() { a.calldata(router) })
+	a.mstore(args+0x20, func() { a.mload(resTokens) })
+	a.call(func() { a.calldata(token) }, func() { a.push(0) }, callBuf, 4+0x40, 0, 0)
+	a.op(opIsZero)
+	a.jumpi("approveFailed")
+
+	// router.getAmountsOut(balance, sellPath); a failed quote stores 0
+	a.mstoreSelector(callBuf, "getAmountsOut(uint256,address[])")
+	a.mstore(args+0x00, func() { a.mload(resTokens) })
+	a.mstore(args+0x20, func() { a.push(0x40) })
+	a.path(args+0x40, sellPath)
+	a.mstore(resQuote, func() {
+		a.staticcall(func() { a.calldata(router) }, callBuf, 4+0x60+hops*0x20, 0, 0x40+hops*0x20)
+		a.mload(0x20 + hops*0x20)
+		a.op(opMul)
+	})
+
+	// router.swapExactTokensForETHSupportingFeeOnTransferTokens(balance, 0, sellPath, this, now)
+	a.mstore(bnbBefore, func() { a.op(opSelfBalance) })
+	a.mstoreSelector(callBuf, "swapExactTokensForETHSupportingFeeOnTransferTokens(uint256,uint256,address[],address,uint256)")
+	a.mstore(args+0x00, func() { a.mload(resTokens) })
+	a.mstore(args+0x20, func() { a.push(0) })
+	a.mstore(args+0x40, func() { a.push(0xa0) })
+	a.mstore(args+0x60, func() { a.op(opAddress) })
+	a.mstore(args+0x80, func() { a.op(opTimestamp) })
+	a.path(args+0xa0, sellPath)
+	a.call(func() { a.calldata(router) }, func() { a.push(0) }, callBuf, 4+0xc0+hops*0x20, 0, 0)
+	a.op(opIsZero)
+	a.jumpi("sellFailed")
+
+	a.mstore(resBNB, func() {
+		a.mload(bnbBefore)
+		a.op(opSelfBalance, opSub)
+	})
+	a.mstore(resStage, func() { a.push(HoneypotStageOK) })
+	a.jump("return")
+
+	a.label("buyFailed")
+	a.mstore(resStage, func() { a.push(HoneypotStageBuyFailed) })
+	a.jump("return")
+
+	a.label("approveFailed")
+	a.mstore(resStage, func() { a.push(HoneypotStageApproveFailed) })
+	a.jump("return")
+
+	a.label("sellFailed")
+	a.mstore(resStage, func() { a.push(HoneypotStageSellFailed) })
+
+	a.label("return")
+	a.push(0x80)
+	a.push(resStage)
+	a.op(opReturn)
+
+	a.label("receive")
+	a.op(opStop)
+
+	return a.assemble()
+}
+
+// The opcodes the executor uses.
+type opcode byte
+
+const (
+	opStop         opcode = 0x00
+	opMul          opcode = 0x02
+	opSub          opcode = 0x03
+	opIsZero       opcode = 0x15
+	opAddress      opcode = 0x30
+	opCalldataLoad opcode = 0x35
+	opCalldataSize opcode = 0x36
+	opTimestamp    opcode = 0x42
+	opSelfBalance  opcode = 0x47
+	opMload        opcode = 0x51
+	opMstore       opcode = 0x52
+	opJump         opcode = 0x56
+	opJumpi        opcode = 0x57
+	opGas          opcode = 0x5a
+	opJumpdest     opcode = 0x5b
+	opPush1        opcode = 0x60
+	opPush2        opcode = 0x61
+	opCall         opcode = 0xf1
+	opReturn       opcode = 0xf3
+	opStaticcall   opcode = 0xfa
+)
+
+// assembler is just enough of an EVM assembler for the executor above:
+// pushes, labels resolved to PUSH2 jump targets, and call helpers.
+type assembler struct {
+	code   []byte
+	labels map[string]int
+	fixups map[int]string
+}
+
+func newAssembler() *assembler {
+	return &assembler{
+		labels: make(map[string]int),
+		fixups: make(map[int]string),
+	}
+}
+
+func (a *assembler) op(ops ...opcode) {
+	for _, op := range ops {
+		a.code = append(a.code, byte(op))
+	}
+}
+
+func (a *assembler) push(value uint64) {
+	a.pushBytes(new(big.Int).SetUint64(value).Bytes())
+}
+
+func (a *assembler) pushBytes(value []byte) {
+	if len(value) == 0 {
+		value = []byte{0}
+	}
+	a.code = append(a.code, byte(opPush1)+byte(len(value)-1))
+	a.code = append(a.code, value...)
+}
+
+func (a *assembler) pushLabel(name string) {
+	a.op(opPush2)
+	a.fixups[len(a.code)] = name
+	a.code = append(a.code, 0, 0)
+}
+
+func (a *assembler) label(name string) {
+	a.labels[name] = len(a.code)
+	a.op(opJumpdest)
+}
+
+func (a *assembler) jump(name string) {
+	a.pushLabel(name)
+	a.op(opJump)
+}
+
+func (a *assembler) jumpi(name string) {
+	a.pushLabel(name)
+	a.op(opJumpi)
+}
+
+func (a *assembler) calldata(offset uint64) {
+	a.push(offset)
+	a.op(opCalldataLoad)
+}
+
+func (a *assembler) mload(offset uint64) {
+	a.push(offset)
+	a.op(opMload)
+}
+
+func (a *assembler) mstore(offset uint64, value func()) {
+	value()
+	a.push(offset)
+	a.op(opMstore)
+}
+
+// path writes an address[] at offset: its length, then each element loaded
+// from the given calldata words.
+func (a *assembler) path(offset uint64, words []uint64) {
+	a.mstore(offset, func() { a.push(uint64(len(words))) })
+	for i, word := range words {
+		word := word
+		a.mstore(offset+0x20*uint64(i+1), func() { a.calldata(word) })
+	}
+}
+
+// mstoreSelector writes the selector left-aligned at offset; arguments are
+// written afterwards starting at offset+4 and overwrite the zero padding.
+func (a *assembler) mstoreSelector(offset uint64, signature string) {
+	a.mstore(offset, func() {
+		a.pushBytes(common.RightPadBytes(selector(signature), 32))
+	})
+}
+
+func (a *assembler) call(to, value func(), inOffset, inSize, outOffset, outSize uint64) {
+	a.push(outSize)
+	a.push(outOffset)
+	a.push(inSize)
+	a.push(inOffset)
+	value()
+	to()
+	a.op(opGas, opCall)
+}
+
+func (a *assembler) staticcall(to func(), inOffset, inSize, outOffset, outSize uint64) {
+	a.push(outSize)
+	a.push(outOffset)
+	a.push(inSize)
+	a.push(inOffset)
+	to()
+	a.op(opGas, opStaticcall)
+}
+
+func (a *assembler) assemble() []byte {
+	for pos, name := range a.fixups {
+		target, ok := a.labels[name]
+		if !ok {
+			panic("honeypot executor: undefined label " + name)
+		}
+		a.code[pos] = byte(target >> 8)
+		a.code[pos+1] = byte(target)
+	}
+	return a.code
+}
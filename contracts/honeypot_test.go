@@ -0,0 +1,421 @@
+package contracts
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The executor is run on a small interpreter that knows exactly the opcodes
+// buildHoneypotExecutor emits, so the test needs nothing beyond the packages
+// the bot already imports. The router and token are Go stubs.
+
+var (
+	testRouter = common.HexToAddress("0x10ED43C718714eb63d5aA57B78B54704E256024E")
+	testToken  = common.HexToAddress("0x000000000000000000000000000000000000cafe")
+	testQuote  = USDT
+)
+
+var uint256Mod = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// stub is a contract implemented in Go; it returns its output and whether it
+// succeeded. Stubs check everything before changing state, so a failed call
+// leaves nothing to roll back.
+type stub func(w *world, caller common.Address, value *big.Int, input []byte) ([]byte, bool)
+
+type world struct {
+	balances map[common.Address]*big.Int
+	code     map[common.Address][]byte
+	stubs    map[common.Address]stub
+	time     uint64
+}
+
+func (w *world) balance(addr common.Address) *big.Int {
+	if b, ok := w.balances[addr]; ok {
+		return b
+	}
+	return new(big.Int)
+}
+
+// call moves value and runs the callee; a failed call returns the value.
+func (w *world) call(caller, to common.Address, value *big.Int, input []byte) ([]byte, bool) {
+	if value.Sign() > 0 {
+		if w.balance(caller).Cmp(value) < 0 {
+			return nil, false
+		}
+		w.balances[caller] = new(big.Int).Sub(w.balance(caller), value)
+		w.balances[to] = new(big.Int).Add(w.balance(to), value)
+	}
+
+	var out []byte
+	ok := true
+	if s, isStub := w.stubs[to]; isStub {
+		out, ok = s(w, caller, value, input)
+	} else if code, hasCode := w.code[to]; hasCode {
+		out, ok = w.run(to, code, input)
+	}
+	if !ok && value.Sign() > 0 {
+		w.balances[to] = new(big.Int).Sub(w.balance(to), value)
+		w.balances[caller] = new(big.Int).Add(w.balance(caller), value)
+	}
+	return out, ok
+}
+
+func word(b []byte, offset uint64) *big.Int {
+	buf := make([]byte, 32)
+	if offset < uint64(len(b)) {
+		copy(buf, b[offset:])
+	}
+	return new(big.Int).SetBytes(buf)
+}
+
+func (w *world) run(self common.Address, code, input []byte) ([]byte, bool) {
+	var stack []*big.Int
+	var memory []byte
+	pop := func() *big.Int {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	push := func(v *big.Int) {
+		stack = append(stack, new(big.Int).Mod(v, uint256Mod))
+	}
+	grow := func(offset, size uint64) {
+		if end := offset + size; size > 0 && end > uint64(len(memory)) {
+			memory = append(memory, make([]byte, end-uint64(len(memory)))...)
+		}
+	}
+	subcall := func(static bool) {
+		pop() // gas
+		to := common.BigToAddress(pop())
+		value := new(big.Int)
+		if !static {
+			value = pop()
+		}
+		inOffset, inSize := pop().Uint64(), pop().Uint64()
+		outOffset, outSize := pop().Uint64(), pop().Uint64()
+		grow(inOffset, inSize)
+		grow(outOffset, outSize)
+		in := append([]byte(nil), memory[inOffset:inOffset+inSize]...)
+		out, ok := w.call(self, to, value, in)
+		if ok {
+			copy(memory[outOffset:outOffset+outSize], out)
+			push(big.NewInt(1))
+		} else {
+			push(new(big.Int))
+		}
+	}
+
+	for pc := 0; pc < len(code); pc++ {
+		op := opcode(code[pc])
+		switch {
+		case op >= opPush1 && op <= opPush1+31:
+			n := int(op-opPush1) + 1
+			push(new(big.Int).SetBytes(code[pc+1 : pc+1+n]))
+			pc += n
+			continue
+		}
+		switch op {
+		case opStop:
+			return nil, true
+		case opMul:
+			a, b := pop(), pop()
+			push(new(big.Int).Mul(a, b))
+		case opSub:
+			a, b := pop(), pop()
+			push(new(big.Int).Sub(a, b))
+		case opIsZero:
+			if pop().Sign() == 0 {
+				push(big.NewInt(1))
+			} else {
+				push(new(big.Int))
+			}
+		case opAddress:
+			push(new(big.Int).SetBytes(self.Bytes()))
+		case opCalldataLoad:
+			push(word(input, pop().Uint64()))
+		case opCalldataSize:
+			push(big.NewInt(int64(len(input))))
+		case opTimestamp:
+			push(new(big.Int).SetUint64(w.time))
+		case opSelfBalance:
+			push(w.balance(self))
+		case opMload:
+			offset := pop().Uint64()
+			grow(offset, 32)
+			push(word(memory, offset))
+		case opMstore:
+			offset, value := pop().Uint64(), pop()
+			grow(offset, 32)
+			copy(memory[offset:offset+32], common.LeftPadBytes(value.Bytes(), 32))
+		case opJump:
+			pc = int(pop().Uint64())
+			if opcode(code[pc]) != opJumpdest {
+				return nil, false
+			}
+		case opJumpi:
+			dest, cond := pop().Uint64(), pop()
+			if cond.Sign() != 0 {
+				pc = int(dest)
+				if opcode(code[pc]) != opJumpdest {
+					return nil, false
+				}
+			}
+		case opGas:
+			push(big.NewInt(1_000_000))
+		case opJumpdest:
+		case opCall:
+			subcall(false)
+		case opStaticcall:
+			subcall(true)
+		case opReturn:
+			offset, size := pop().Uint64(), pop().Uint64()
+			grow(offset, size)
+			return append([]byte(nil), memory[offset:offset+size]...), true
+		default:
+			panic(fmt.Sprintf("opcode 0x%02x at %d not supported by the test interpreter", byte(op), pc))
+		}
+	}
+	return nil, true
+}
+
+// stubToken is an ERC20 that takes buyTaxBps of transfers out of the router
+// and sellTaxBps of transfers into it.
+type stubToken struct {
+	balances   map[common.Address]*big.Int
+	allowances map[[2]common.Address]*big.Int
+	buyTaxBps  int64
+	sellTaxBps int64
+	noApprove  bool
+	noSell     bool
+}
+
+var erc20TestABI = mustParseABI(ERC20ABI)
+
+func (t *stubToken) balance(addr common.Address) *big.Int {
+	if b, ok := t.balances[addr]; ok {
+		return b
+	}
+	return new(big.Int)
+}
+
+func (t *stubToken) move(from, to common.Address, amount *big.Int, taxBps int64) bool {
+	if t.balance(from).Cmp(amount) < 0 {
+		return false
+	}
+	t.balances[from] = new(big.Int).Sub(t.balance(from), amount)
+	t.balances[to] = new(big.Int).Add(t.balance(to), AfterTax(amount, taxBps))
+	return true
+}
+
+func (t *stubToken) stub(w *world, caller common.Address, value *big.Int, input []byte) ([]byte, bool) {
+	method, err := erc20TestABI.MethodById(input[:4])
+	if err != nil {
+		return t.transfer(caller, input)
+	}
+	args, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, false
+	}
+	switch method.Name {
+	case "balanceOf":
+		return common.LeftPadBytes(t.balance(args[0].(common.Address)).Bytes(), 32), true
+	case "approve":
+		if t.noApprove {
+			return nil, false
+		}
+		t.allowances[[2]common.Address{caller, args[0].(common.Address)}] = args[1].(*big.Int)
+		return common.LeftPadBytes([]byte{1}, 32), true
+	}
+	return nil, false
+}
+
+// transfer and transferFrom are not in ERC20ABI, so they are matched by
+// selector.
+func (t *stubToken) transfer(caller common.Address, input []byte) ([]byte, bool) {
+	switch string(input[:4]) {
+	case string(selector("transfer(address,uint256)")):
+		to, amount := common.BytesToAddress(input[4:36]), new(big.Int).SetBytes(input[36:68])
+		taxBps := int64(0)
+		if caller == testRouter {
+			taxBps = t.buyTaxBps
+		}
+		return common.LeftPadBytes([]byte{1}, 32), t.move(caller, to, amount, taxBps)
+	case string(selector("transferFrom(address,address,uint256)")):
+		from, to := common.BytesToAddress(input[4:36]), common.BytesToAddress(input[36:68])
+		amount := new(big.Int).SetBytes(input[68:100])
+		key := [2]common.Address{from, caller}
+		allowance, ok := t.allowances[key]
+		if t.noSell || !ok || allowance.Cmp(amount) < 0 {
+			return nil, false
+		}
+		if !t.move(from, to, amount, t.sellTaxBps) {
+			return nil, false
+		}
+		t.allowances[key] = new(big.Int).Sub(allowance, amount)
+		return common.LeftPadBytes([]byte{1}, 32), true
+	}
+	return nil, false
+}
+
+// stubRouter swaps at a fixed rate of tokens per wei, whatever the path,
+// and records the paths it was asked to trade.
+type stubRouter struct {
+	token   *stubToken
+	rate    int64
+	noBuy   bool
+	buyPath []common.Address
+	sellOut []common.Address
+}
+
+var routerTestABI = func() abi.ABI {
+	var methods []string
+	for _, def := range []string{SwapExactETHForTokensABI, SwapExactTokensForETHABI, GetAmountsOutABI} {
+		methods = append(methods, strings.TrimSuffix(strings.TrimPrefix(def, "["), "]"))
+	}
+	return mustParseABI("[" + strings.Join(methods, ",") + "]")
+}()
+
+func (r *stubRouter) amountOut(amountIn *big.Int, path []common.Address) *big.Int {
+	if path[0] == WBNB {
+		return new(big.Int).Mul(amountIn, big.NewInt(r.rate))
+	}
+	return new(big.Int).Div(amountIn, big.NewInt(r.rate))
+}
+
+func (r *stubRouter) stub(w *world, caller common.Address, value *big.Int, input []byte) ([]byte, bool) {
+	method, err := routerTestABI.MethodById(input[:4])
+	if err != nil {
+		return nil, false
+	}
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, input[4:]); err != nil {
+		return nil, false
+	}
+	path := args["path"].([]common.Address)
+
+	switch method.Name {
+	case "getAmountsOut":
+		amounts := make([]*big.Int, len(path))
+		amounts[0] = args["amountIn"].(*big.Int)
+		for i := 1; i < len(path); i++ {
+			amounts[i] = new(big.Int)
+		}
+		amounts[len(path)-1] = r.amountOut(amounts[0], path)
+		out, err := method.Outputs.Pack(amounts)
+		return out, err == nil
+
+	case "swapExactETHForTokensSupportingFeeOnTransferTokens":
+		if r.noBuy {
+			return nil, false
+		}
+		r.buyPath = path
+		transfer := append(selector("transfer(address,uint256)"), common.LeftPadBytes(args["to"].(common.Address).Bytes(), 32)...)
+		transfer = append(transfer, common.LeftPadBytes(r.amountOut(value, path).Bytes(), 32)...)
+		_, ok := w.call(testRouter, testToken, new(big.Int), transfer)
+		return nil, ok
+
+	case "swapExactTokensForETHSupportingFeeOnTransferTokens":
+		r.sellOut = path
+		before := new(big.Int).Set(r.token.balance(testRouter))
+		transferFrom := append(selector("transferFrom(address,address,uint256)"), common.LeftPadBytes(caller.Bytes(), 32)...)
+		transferFrom = append(transferFrom, common.LeftPadBytes(testRouter.Bytes(), 32)...)
+		transferFrom = append(transferFrom, common.LeftPadBytes(args["amountIn"].(*big.Int).Bytes(), 32)...)
+		if _, ok := w.call(testRouter, testToken, new(big.Int), transferFrom); !ok {
+			return nil, false
+		}
+		received := new(big.Int).Sub(r.token.balance(testRouter), before)
+		_, ok := w.call(testRouter, args["to"].(common.Address), r.amountOut(received, path), nil)
+		return nil, ok
+	}
+	return nil, false
+}
+
+func TestHoneypotExecutor(t *testing.T) {
+	amountIn := big.NewInt(1e18)
+	const rate = 1000
+
+	tests := []struct {
+		name        string
+		quote       common.Address
+		buyTaxBps   int64
+		sellTaxBps  int64
+		noBuy       bool
+		noApprove   bool
+		noSell      bool
+		wantStage   int
+		wantBuyTax  int64
+		wantSellTax int64
+		wantLoss    int64
+	}{
+		{name: "untaxed", quote: WBNB, wantStage: HoneypotStageOK},
+		{name: "taxed", quote: WBNB, buyTaxBps: 500, sellTaxBps: 1000, wantStage: HoneypotStageOK, wantBuyTax: 500, wantSellTax: 1000, wantLoss: 1450},
+		{name: "taxed through quote", quote: testQuote, buyTaxBps: 300, sellTaxBps: 300, wantStage: HoneypotStageOK, wantBuyTax: 300, wantSellTax: 300, wantLoss: 591},
+		{name: "buy reverts", quote: WBNB, noBuy: true, wantStage: HoneypotStageBuyFailed, wantLoss: 10000},
+		{name: "approve reverts", quote: WBNB, noApprove: true, wantStage: HoneypotStageApproveFailed, wantLoss: 10000},
+		{name: "sell reverts", quote: WBNB, buyTaxBps: 200, noSell: true, wantStage: HoneypotStageSellFailed, wantBuyTax: 200, wantLoss: 10000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &stubToken{
+				balances:   map[common.Address]*big.Int{testRouter: new(big.Int).Mul(amountIn, big.NewInt(rate*10))},
+				allowances: make(map[[2]common.Address]*big.Int),
+				buyTaxBps:  tt.buyTaxBps,
+				sellTaxBps: tt.sellTaxBps,
+				noApprove:  tt.noApprove,
+				noSell:     tt.noSell,
+			}
+			router := &stubRouter{token: token, rate: rate, noBuy: tt.noBuy}
+			via := tt.quote != WBNB
+			w := &world{
+				balances: map[common.Address]*big.Int{
+					honeypotExecutor: new(big.Int).Set(amountIn),
+					testRouter:       new(big.Int).Mul(amountIn, big.NewInt(10)),
+				},
+				code:  map[common.Address][]byte{honeypotExecutor: buildHoneypotExecutor(via)},
+				stubs: map[common.Address]stub{testRouter: router.stub, testToken: token.stub},
+				time:  1_700_000_000,
+			}
+
+			output, ok := w.call(common.Address{}, honeypotExecutor, new(big.Int), honeypotInput(testRouter, testToken, amountIn, tt.quote))
+			if !ok {
+				t.Fatal("executor reverted")
+			}
+			quoted := new(big.Int).Mul(amountIn, big.NewInt(rate))
+			result, err := decodeHoneypotOutput(output, quoted, amountIn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Stage != tt.wantStage {
+				t.Fatalf("stage = %d (%s), want %d", result.Stage, result.String(), tt.wantStage)
+			}
+			if result.BuyTaxBps != tt.wantBuyTax || result.SellTaxBps != tt.wantSellTax || result.LossBps != tt.wantLoss {
+				t.Errorf("taxes = buy %d, sell %d, loss %d bps; want %d, %d, %d",
+					result.BuyTaxBps, result.SellTaxBps, result.LossBps, tt.wantBuyTax, tt.wantSellTax, tt.wantLoss)
+			}
+
+			if tt.noBuy {
+				return
+			}
+			wantBuy := []common.Address{WBNB, testToken}
+			wantSell := []common.Address{testToken, WBNB}
+			if via {
+				wantBuy = []common.Address{WBNB, tt.quote, testToken}
+				wantSell = []common.Address{testToken, tt.quote, WBNB}
+			}
+			if !reflect.DeepEqual(router.buyPath, wantBuy) {
+				t.Errorf("buy path = %v, want %v", router.buyPath, wantBuy)
+			}
+			if tt.wantStage == HoneypotStageOK && !reflect.DeepEqual(router.sellOut, wantSell) {
+				t.Errorf("sell path = %v, want %v", router.sellOut, wantSell)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TokenManagerBuyABI covers the TokenManager buy entry points. A buy that
+// takes the last tokens off the curve makes the manager add liquidity and
+// emit LiquidityAdded in the same transaction.
+const TokenManagerBuyABI = `[{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"},{"internalType":"uint256","name":"maxFunds","type":"uint256"}],"name":"buyToken","outputs":[],"stateMutability":"payable","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"address","name":"to","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"},{"internalType":"uint256","name":"maxFunds","type":"uint256"}],"name":"buyToken","outputs":[],"stateMutability":"payable","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"uint256","name":"funds","type":"uint256"},{"internalType":"uint256","name":"minAmount","type":"uint256"}],"name":"buyTokenAMAP","outputs":[],"stateMutability":"payable","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"address","name":"to","type":"address"},{"internalType":"uint256","name":"funds","type":"uint256"},{"internalType":"uint256","name":"minAmount","type":"uint256"}],"name":"buyTokenAMAP","outputs":[],"stateMutability":"payable","type":"function"}]`
+
+var tokenManagerBuyABI = mustParseABI(TokenManagerBuyABI)
+
+// ErrBackrunUnsupported is returned by venues that cannot buy a launch before
+// its pool exists. TokenManager launches only list on PancakeSwap V2.
+var ErrBackrunUnsupported = errors.New("venue cannot buy behind a launch trigger")
+
+// ManagerBuy is a decoded TokenManager buy. Exactly one of Amount (tokens,
+// buyToken) and Funds (quote, buyTokenAMAP) is set.
+type ManagerBuy struct {
+	Method string
+	Token  common.Address
+	Amount *big.Int
+	Funds  *big.Int
+}
+
+// DecodeManagerBuy decodes TokenManager calldata. Calls that are not buys
+// return a nil ManagerBuy and no error.
+func DecodeManagerBuy(data []byte) (*ManagerBuy, error) {
+	if len(data) < 4 {
+		return nil, nil
+	}
+	method, err := tokenManagerBuyABI.MethodById(data[:4])
+	if err != nil {
+		return nil, nil
+	}
+
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, data[4:]); err != nil {
+		return nil, fmt.Errorf("failed to unpack %s: %w", method.RawName, err)
+	}
+
+	buy := &ManagerBuy{Method: method.RawName, Token: args["token"].(common.Address)}
+	if amount, ok := args["amount"].(*big.Int); ok {
+		buy.Amount = amount
+	}
+	if funds, ok := args["funds"].(*big.Int); ok {
+		buy.Funds = funds
+	}
+	return buy, nil
+}
+
+// WouldLaunch reports whether the buy is large enough to empty the curve,
+// given the token's current record. It is an estimate: buys mined ahead of it
+// and the trading fee can still change the outcome.
+func (i *TokenInfo) WouldLaunch(buy *ManagerBuy) bool {
+	if !i.Known() {
+		return false
+	}
+	if buy.Amount != nil {
+		remaining := new(big.Int).Sub(i.MaxOffers, i.Offers)
+		return buy.Amount.Cmp(remaining) >= 0
+	}
+	remaining := new(big.Int).Sub(i.MaxRaising, i.Funds)
+	return buy.Funds != nil && buy.Funds.Cmp(remaining) >= 0
+}
+
+// PancakeSwap V2 keeps 0.25% of every swap input.
+const (
+	v2FeeKeep        = 9975
+	v2FeeDenominator = 10000
+)
+
+// LaunchPool is the V2 pool a launch is expected to create: the tokens left
+// off the curve against the funds it raised.
+type LaunchPool struct {
+	Tokens *big.Int
+	Funds  *big.Int
+}
+
+// LaunchPool estimates the pool the manager will seed once the curve is
+// emptied. It is an upper bound on the funds, since the launch fee comes out
+// of them first, so quotes taken from it err towards a revert rather than a
+// bad fill.
+func (i *TokenInfo) LaunchPool() LaunchPool {
+	return LaunchPool{
+		Tokens: new(big.Int).Sub(i.TotalSupply, i.MaxOffers),
+		Funds:  new(big.Int).Set(i.MaxRaising),
+	}
+}
+
+// AmountOut is getAmountOut of the V2 router for a buy of funds into the
+// pool.
+func (p LaunchPool) AmountOut(amountIn *big.Int) *big.Int {
+	if amountIn.Sign() <= 0 || p.Tokens.Sign() <= 0 || p.Funds.Sign() <= 0 {
+		return new(big.Int)
+	}
+	inWithFee := new(big.Int).Mul(amountIn, big.NewInt(v2FeeKeep))
+	numerator := new(big.Int).Mul(inWithFee, p.Tokens)
+	denominator := new(big.Int).Mul(p.Funds, big.NewInt(v2FeeDenominator))
+	denominator.Add(denominator, inWithFee)
+	return numerator.Div(numerator, denominator)
+}
+
+// PendingLaunchEvent is a TokenManager buy still in the mempool that will
+// empty the curve. It stands in for LiquidityAdded while the listener buys
+// behind the trigger; Log carries only the trigger's hash.
+type PendingLaunchEvent struct {
+	eventLog
+	Trigger *types.Transaction
+	Buy     *ManagerBuy
+	Info    *TokenInfo
+}
+
+func NewPendingLaunchEvent(trigger *types.Transaction, buy *ManagerBuy, info *TokenInfo) *PendingLaunchEvent {
+	return &PendingLaunchEvent{
+		eventLog: eventLog{raw: types.Log{TxHash: trigger.Hash()}},
+		Trigger:  trigger,
+		Buy:      buy,
+		Info:     info,
+	}
+}
+
+func (e *PendingLaunchEvent) EventName() string { return EventPendingLaunch }
@@ -0,0 +1,80 @@
+package contracts
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func ether(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+func TestWouldLaunch(t *testing.T) {
+	curve := &TokenInfo{
+		Base:       common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		MaxOffers:  ether(800_000_000),
+		Offers:     ether(700_000_000),
+		MaxRaising: ether(24),
+		Funds:      ether(20),
+	}
+
+	tests := []struct {
+		name string
+		info *TokenInfo
+		buy  *ManagerBuy
+		want bool
+	}{
+		{
+			name: "amount below remaining offers",
+			info: curve,
+			buy:  &ManagerBuy{Amount: ether(99_999_999)},
+			want: false,
+		},
+		{
+			name: "amount equal to remaining offers",
+			info: curve,
+			buy:  &ManagerBuy{Amount: ether(100_000_000)},
+			want: true,
+		},
+		{
+			name: "amount above remaining offers but below sold offers",
+			info: curve,
+			buy:  &ManagerBuy{Amount: ether(150_000_000)},
+			want: true,
+		},
+		{
+			name: "funds below remaining raise",
+			info: curve,
+			buy:  &ManagerBuy{Funds: ether(3)},
+			want: false,
+		},
+		{
+			name: "funds equal to remaining raise",
+			info: curve,
+			buy:  &ManagerBuy{Funds: ether(4)},
+			want: true,
+		},
+		{
+			name: "neither amount nor funds",
+			info: curve,
+			buy:  &ManagerBuy{},
+			want: false,
+		},
+		{
+			name: "unknown token",
+			info: &TokenInfo{MaxOffers: ether(1), Offers: ether(0), MaxRaising: ether(1), Funds: ether(0)},
+			buy:  &ManagerBuy{Amount: ether(1)},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.WouldLaunch(tt.buy); got != tt.want {
+				t.Errorf("WouldLaunch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
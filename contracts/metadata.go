@@ -0,0 +1,122 @@
+package contracts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const ERC20MetadataABI = `[{"inputs":[],"name":"decimals","outputs":[{"internalType":"uint8","name":"","type":"uint8"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"symbol","outputs":[{"internalType":"string","name":"","type":"string"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"name","outputs":[{"internalType":"string","name":"","type":"string"}],"stateMutability":"view","type":"function"}]`
+
+var erc20MetadataABI = mustParseABI(ERC20MetadataABI)
+
+type TokenMetadata struct {
+	Address  common.Address
+	Name     string
+	Symbol   string
+	Decimals uint8
+}
+
+// Amount wraps raw base units of this token.
+func (t *TokenMetadata) Amount(raw *big.Int) Amount {
+	return NewAmount(raw, t.Decimals)
+}
+
+// Format renders raw base units as "1.5 SYMBOL".
+func (t *TokenMetadata) Format(raw *big.Int) string {
+	symbol := t.Symbol
+	if symbol == "" {
+		symbol = t.Address.Hex()
+	}
+	return t.Amount(raw).String() + " " + symbol
+}
+
+// MetadataService fetches and caches decimals, symbol and name per token.
+// Metadata never changes, so a successful lookup is kept for good; decimals
+// are required, while a missing symbol or name is left empty.
+type MetadataService struct {
+	client *ethclient.Client
+	tokens map[common.Address]*TokenMetadata
+	mu     sync.RWMutex
+}
+
+func NewMetadataService(client *ethclient.Client) *MetadataService {
+	return &MetadataService{
+		client: client,
+		tokens: make(map[common.Address]*TokenMetadata),
+	}
+}
+
+func (s *MetadataService) Get(ctx context.Context, tokenAddress common.Address) (*TokenMetadata, error) {
+	s.mu.RLock()
+	meta, ok := s.tokens[tokenAddress]
+	s.mu.RUnlock()
+	if ok {
+		return meta, nil
+	}
+
+	result, err := s.call(ctx, tokenAddress, "decimals")
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := erc20MetadataABI.Unpack("decimals", result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack decimals of %s: %w", tokenAddress.Hex(), err)
+	}
+
+	meta = &TokenMetadata{
+		Address:  tokenAddress,
+		Decimals: outputs[0].(uint8),
+		Symbol:   s.text(ctx, tokenAddress, "symbol"),
+		Name:     s.text(ctx, tokenAddress, "name"),
+	}
+
+	s.mu.Lock()
+	s.tokens[tokenAddress] = meta
+	s.mu.Unlock()
+	return meta, nil
+}
+
+// Decimals is Get for callers that only need the precision.
+func (s *MetadataService) Decimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	meta, err := s.Get(ctx, tokenAddress)
+	if err != nil {
+		return 0, err
+	}
+	return meta.Decimals, nil
+}
+
+func (s *MetadataService) call(ctx context.Context, tokenAddress common.Address, method string) ([]byte, error) {
+	data, err := erc20MetadataABI.Pack(method)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", method, err)
+	}
+	result, err := s.client.CallContract(ctx, ethereum.CallMsg{To: &tokenAddress, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s on %s: %w", method, tokenAddress.Hex(), err)
+	}
+	return result, nil
+}
+
+// text reads symbol or name, which older tokens return as bytes32 instead
+// of string.
+func (s *MetadataService) text(ctx context.Context, tokenAddress common.Address, method string) string {
+	result, err := s.call(ctx, tokenAddress, method)
+	if err != nil {
+		return ""
+	}
+	if outputs, err := erc20MetadataABI.Unpack(method, result); err == nil {
+		return strings.TrimSpace(outputs[0].(string))
+	}
+	if len(result) == 32 {
+		return strings.TrimSpace(string(bytes.TrimRight(result, "\x00")))
+	}
+	return ""
+}
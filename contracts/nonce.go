@@ -0,0 +1,188 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+type walletNonce struct {
+	mu       sync.Mutex
+	synced   bool
+	next     uint64
+	inFlight map[uint64]struct{}
+	gaps     map[uint64]struct{}
+}
+
+type NonceManager struct {
+	client  *ethclient.Client
+	wallets map[common.Address]*walletNonce
+	mu      sync.Mutex
+}
+
+func NewNonceManager(client *ethclient.Client) *NonceManager {
+	return &NonceManager{
+		client:  client,
+		wallets: make(map[common.Address]*walletNonce),
+	}
+}
+
+func (n *NonceManager) wallet(address common.Address) *walletNonce {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	w, ok := n.wallets[address]
+	if !ok {
+		w = &walletNonce{
+			inFlight: make(map[uint64]struct{}),
+			gaps:     make(map[uint64]struct{}),
+		}
+		n.wallets[address] = w
+	}
+	return w
+}
+
+func (n *NonceManager) Sync(address common.Address) error {
+	w := n.wallet(address)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return n.syncLocked(address, w)
+}
+
+// syncLocked takes the pending nonce from chain but never moves backwards past
+// a nonce we already handed out and that is still in flight.
+func (n *NonceManager) syncLocked(address common.Address, w *walletNonce) error {
+	pending, err := n.client.PendingNonceAt(context.Background(), address)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %w", err)
+	}
+
+	for nonce := range w.inFlight {
+		if nonce < pending {
+			delete(w.inFlight, nonce)
+		}
+	}
+	for nonce := range w.gaps {
+		if nonce < pending {
+			delete(w.gaps, nonce)
+		}
+	}
+
+	next := pending
+	for nonce := range w.inFlight {
+		if nonce >= next {
+			next = nonce + 1
+		}
+	}
+
+	for nonce := range w.gaps {
+		if nonce >= next {
+			delete(w.gaps, nonce)
+		}
+	}
+
+	w.next = next
+	w.synced = true
+	return nil
+}
+
+func (n *NonceManager) Acquire(address common.Address) (uint64, error) {
+	w := n.wallet(address)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.synced {
+		if err := n.syncLocked(address, w); err != nil {
+			return 0, err
+		}
+	}
+
+	nonce, ok := lowestGap(w.gaps)
+	if ok {
+		delete(w.gaps, nonce)
+	} else {
+		nonce = w.next
+		w.next++
+	}
+	w.inFlight[nonce] = struct{}{}
+	return nonce, nil
+}
+
+// Release is called when a transaction with the nonce was never accepted by
+// the node. The last nonce is simply handed back; one in the middle is kept
+// as a gap and reused first, otherwise every later nonce would be stuck.
+func (n *NonceManager) Release(address common.Address, nonce uint64) {
+	w := n.wallet(address)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.inFlight, nonce)
+	if nonce+1 == w.next {
+		w.next = nonce
+		return
+	}
+	if nonce < w.next {
+		w.gaps[nonce] = struct{}{}
+	}
+}
+
+// Drop is called when a sent transaction left the mempool without being
+// mined. Only its nonce is settled, so the wallet's other transactions stay
+// in flight: a nonce the chain has since used, say by a replacement, is
+// forgotten, and an unused one is handed back like Release.
+func (n *NonceManager) Drop(address common.Address, nonce uint64) error {
+	w := n.wallet(address)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.inFlight, nonce)
+	pending, err := n.client.PendingNonceAt(context.Background(), address)
+	if err != nil {
+		w.gaps[nonce] = struct{}{}
+		return fmt.Errorf("failed to get nonce: %w", err)
+	}
+	if nonce < pending {
+		return nil
+	}
+	if nonce+1 == w.next {
+		w.next = nonce
+		return nil
+	}
+	if nonce < w.next {
+		w.gaps[nonce] = struct{}{}
+	}
+	return nil
+}
+
+func (n *NonceManager) InFlight(address common.Address) int {
+	w := n.wallet(address)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.inFlight)
+}
+
+func lowestGap(gaps map[uint64]struct{}) (uint64, bool) {
+	var lowest uint64
+	found := false
+	for nonce := range gaps {
+		if !found || nonce < lowest {
+			lowest = nonce
+			found = true
+		}
+	}
+	return lowest, found
+}
+
+func isNonceTooLow(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce too low")
+}
+
+func isAlreadyKnown(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
@@ -0,0 +1,175 @@
+package contracts
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var testWallet = common.HexToAddress("0x000000000000000000000000000000000000beef")
+
+// nonceNode answers eth_getTransactionCount with a fixed pending nonce.
+type nonceNode struct {
+	pending uint64
+}
+
+func (n *nonceNode) GetTransactionCount(address common.Address, block string) (hexutil.Uint64, error) {
+	return hexutil.Uint64(n.pending), nil
+}
+
+func newTestNonceManager(t *testing.T, node *nonceNode) *NonceManager {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", node); err != nil {
+		t.Fatal(err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+	return NewNonceManager(client)
+}
+
+func acquire(t *testing.T, n *NonceManager) uint64 {
+	t.Helper()
+	nonce, err := n.Acquire(testWallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nonce
+}
+
+func TestNonceManager(t *testing.T) {
+	tests := []struct {
+		name    string
+		pending uint64
+		run     func(t *testing.T, n *NonceManager, node *nonceNode) []uint64
+		want    []uint64
+		flight  int
+	}{
+		{
+			name:    "acquire counts up from the pending nonce",
+			pending: 7,
+			run: func(t *testing.T, n *NonceManager, node *nonceNode) []uint64 {
+				return []uint64{acquire(t, n), acquire(t, n), acquire(t, n)}
+			},
+			want:   []uint64{7, 8, 9},
+			flight: 3,
+		},
+		{
+			name:    "releasing the last nonce hands it back",
+			pending: 7,
+			run: func(t *testing.T, n *NonceManager, node *nonceNode) []uint64 {
+				acquire(t, n)
+				last := acquire(t, n)
+				n.Release(testWallet, last)
+				return []uint64{acquire(t, n)}
+			},
+			want:   []uint64{8},
+			flight: 2,
+		},
+		{
+			name:    "a released middle nonce is reused first, lowest gap first",
+			pending: 7,
+			run: func(t *testing.T, n *NonceManager, node *nonceNode) []uint64 {
+				acquire(t, n)
+				a, b := acquire(t, n), acquire(t, n)
+				acquire(t, n)
+				n.Release(testWallet, b)
+				n.Release(testWallet, a)
+				return []uint64{acquire(t, n), acquire(t, n), acquire(t, n)}
+			},
+			want:   []uint64{8, 9, 11},
+			flight: 5,
+		},
+		{
+			name:    "dropping an unused middle nonce keeps the others in flight",
+			pending: 7,
+			run: func(t *testing.T, n *NonceManager, node *nonceNode) []uint64 {
+				acquire(t, n)
+				dropped := acquire(t, n)
+				acquire(t, n)
+				if err := n.Drop(testWallet, dropped); err != nil {
+					t.Fatal(err)
+				}
+				return []uint64{acquire(t, n), acquire(t, n)}
+			},
+			want:   []uint64{8, 10},
+			flight: 4,
+		},
+		{
+			name:    "dropping a nonce the chain used forgets it",
+			pending: 7,
+			run: func(t *testing.T, n *NonceManager, node *nonceNode) []uint64 {
+				dropped := acquire(t, n)
+				acquire(t, n)
+				node.pending = 8
+				if err := n.Drop(testWallet, dropped); err != nil {
+					t.Fatal(err)
+				}
+				return []uint64{acquire(t, n)}
+			},
+			want:   []uint64{9},
+			flight: 2,
+		},
+		{
+			name:    "sync never moves below a nonce in flight",
+			pending: 7,
+			run: func(t *testing.T, n *NonceManager, node *nonceNode) []uint64 {
+				acquire(t, n)
+				acquire(t, n)
+				node.pending = 8
+				if err := n.Sync(testWallet); err != nil {
+					t.Fatal(err)
+				}
+				return []uint64{acquire(t, n)}
+			},
+			want:   []uint64{9},
+			flight: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &nonceNode{pending: tt.pending}
+			n := newTestNonceManager(t, node)
+
+			got := tt.run(t, n, node)
+			if len(got) != len(tt.want) {
+				t.Fatalf("nonces = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("nonces = %v, want %v", got, tt.want)
+				}
+			}
+			if flight := n.InFlight(testWallet); flight != tt.flight {
+				t.Errorf("InFlight() = %d, want %d", flight, tt.flight)
+			}
+		})
+	}
+}
+
+func TestIsNonceTooLow(t *testing.T) {
+	tests := []struct {
+		err  string
+		want bool
+	}{
+		{err: "nonce too low", want: true},
+		{err: "Nonce too low: next nonce 12, tx nonce 11", want: true},
+		{err: "replacement transaction underpriced", want: false},
+		{err: "already known", want: false},
+		{err: "insufficient funds for gas * price + value", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isNonceTooLow(errors.New(tt.err)); got != tt.want {
+			t.Errorf("isNonceTooLow(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
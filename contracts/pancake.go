@@ -2,8 +2,8 @@ package contracts
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
+	"log"
 	"math/big"
 	"strings"
 	"time"
@@ -12,14 +12,12 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 var (
-	PancakeRouterV2 = common.HexToAddress("0x10ED43C718714eb63d5aA57B78B54704E256024E")
-	WBNB            = common.HexToAddress("0xbb4CdB9CBd36B01bD1cBaEBF2De08d9173bc095c")
-	USDT            = common.HexToAddress("0x55d398326f99059fF775485246999027B3197955")
+	WBNB = common.HexToAddress("0xbb4CdB9CBd36B01bD1cBaEBF2De08d9173bc095c")
+	USDT = common.HexToAddress("0x55d398326f99059fF775485246999027B3197955")
 )
 
 const SwapExactETHForTokensABI = `[{"inputs":[{"internalType":"uint256","name":"amountOutMin","type":"uint256"},{"internalType":"address[]","name":"path","type":"address[]"},{"internalType":"address","name":"to","type":"address"},{"internalType":"uint256","name":"deadline","type":"uint256"}],"name":"swapExactETHForTokensSupportingFeeOnTransferTokens","outputs":[],"stateMutability":"payable","type":"function"}]`
@@ -28,130 +26,46 @@ const SwapExactTokensForETHABI = `[{"inputs":[{"internalType":"uint256","name":"
 
 const GetAmountsOutABI = `[{"inputs":[{"internalType":"uint256","name":"amountIn","type":"uint256"},{"internalType":"address[]","name":"path","type":"address[]"}],"name":"getAmountsOut","outputs":[{"internalType":"uint256[]","name":"amounts","type":"uint256[]"}],"stateMutability":"view","type":"function"}]`
 
-const ERC20ABI = `[{"inputs":[{"internalType":"address","name":"spender","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"}],"name":"approve","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"account","type":"address"}],"name":"balanceOf","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+const ERC20ABI = `[{"inputs":[{"internalType":"address","name":"spender","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"}],"name":"approve","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"owner","type":"address"},{"internalType":"address","name":"spender","type":"address"}],"name":"allowance","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"account","type":"address"}],"name":"balanceOf","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
 
 type PancakeSwapper struct {
-	client     *ethclient.Client
-	privateKey *ecdsa.PrivateKey
-	address    common.Address
-	chainID    *big.Int
-	gasLimit   uint64
-	gasPrice   *big.Int
-	slippage   int
+	*baseSwapper
+	router  common.Address
+	factory common.Address
+	routes  *RouteFinder
 }
 
-func NewPancakeSwapper(client *ethclient.Client, privateKeyHex string, gasLimit uint64, gasPriceGwei int64, slippage int) (*PancakeSwapper, error) {
-	privateKey, err := crypto.HexToECDSA(privateKeyHex)
+func NewPancakeSwapper(client *ethclient.Client, nonces *NonceManager, tracker *TxTracker, privateKeyHex string, cfg SwapperConfig) (*PancakeSwapper, error) {
+	base, err := newBaseSwapper(client, nonces, tracker, privateKeyHex, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("invalid private key: %w", err)
+		return nil, err
 	}
 
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
-	}
-
-	address := crypto.PubkeyToAddress(*publicKeyECDSA)
-
-	chainID, err := client.NetworkID(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get chain ID: %w", err)
-	}
-
-	gasPrice := new(big.Int).Mul(big.NewInt(gasPriceGwei), big.NewInt(1e9))
-
 	return &PancakeSwapper{
-		client:     client,
-		privateKey: privateKey,
-		address:    address,
-		chainID:    chainID,
-		gasLimit:   gasLimit,
-		gasPrice:   gasPrice,
-		slippage:   slippage,
+		baseSwapper: base,
+		router:      cfg.Router,
+		factory:     cfg.Factory,
+		routes:      cfg.Routes,
 	}, nil
 }
 
-func (p *PancakeSwapper) BuyToken(tokenAddress common.Address, amountBNB *big.Int) (string, error) {
-	parsedABI, err := abi.JSON(strings.NewReader(SwapExactETHForTokensABI))
-	if err != nil {
-		return "", fmt.Errorf("failed to parse ABI: %w", err)
-	}
-
-	path := []common.Address{WBNB, tokenAddress}
-	deadline := big.NewInt(time.Now().Unix() + 300)
-	amountOutMin := big.NewInt(0)
-
-	data, err := parsedABI.Pack("swapExactETHForTokensSupportingFeeOnTransferTokens", amountOutMin, path, p.address, deadline)
-	if err != nil {
-		return "", fmt.Errorf("failed to pack data: %w", err)
-	}
-
-	nonce, err := p.client.PendingNonceAt(context.Background(), p.address)
-	if err != nil {
-		return "", fmt.Errorf("failed to get nonce: %w", err)
-	}
-
-	tx := types.NewTransaction(nonce, PancakeRouterV2, amountBNB, p.gasLimit, p.gasPrice, data)
-
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(p.chainID), p.privateKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
-	}
-
-	err = p.client.SendTransaction(context.Background(), signedTx)
-	if err != nil {
-		return "", fmt.Errorf("failed to send transaction: %w", err)
-	}
-
-	return signedTx.Hash().Hex(), nil
-}
-
-func (p *PancakeSwapper) GetAddress() common.Address {
-	return p.address
-}
-
-func (p *PancakeSwapper) GetTokenBalance(tokenAddress common.Address) (*big.Int, error) {
-	parsedABI, err := abi.JSON(strings.NewReader(ERC20ABI))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse ERC20 ABI: %w", err)
-	}
-
-	data, err := parsedABI.Pack("balanceOf", p.address)
-	if err != nil {
-		return nil, fmt.Errorf("failed to pack balanceOf: %w", err)
-	}
-
-	result, err := p.client.CallContract(context.Background(), ethereum.CallMsg{
-		To:   &tokenAddress,
-		Data: data,
-	}, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to call balanceOf: %w", err)
-	}
-
-	outputs, err := parsedABI.Unpack("balanceOf", result)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unpack balanceOf: %w", err)
-	}
-
-	return outputs[0].(*big.Int), nil
+func (p *PancakeSwapper) getAmountsOut(amountIn *big.Int, path []common.Address) ([]*big.Int, error) {
+	return getAmountsOut(context.Background(), p.client, p.router, amountIn, path)
 }
 
-func (p *PancakeSwapper) GetTokenPrice(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+func getAmountsOut(ctx context.Context, client *ethclient.Client, router common.Address, amountIn *big.Int, path []common.Address) ([]*big.Int, error) {
 	parsedABI, err := abi.JSON(strings.NewReader(GetAmountsOutABI))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse ABI: %w", err)
 	}
 
-	path := []common.Address{tokenAddress, WBNB}
-	data, err := parsedABI.Pack("getAmountsOut", amount, path)
+	data, err := parsedABI.Pack("getAmountsOut", amountIn, path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to pack getAmountsOut: %w", err)
 	}
 
-	result, err := p.client.CallContract(context.Background(), ethereum.CallMsg{
-		To:   &PancakeRouterV2,
+	result, err := client.CallContract(ctx, ethereum.CallMsg{
+		To:   &router,
 		Data: data,
 	}, nil)
 	if err != nil {
@@ -164,75 +78,138 @@ func (p *PancakeSwapper) GetTokenPrice(tokenAddress common.Address, amount *big.
 	}
 
 	amounts := outputs[0].([]*big.Int)
-	if len(amounts) < 2 {
+	if len(amounts) != len(path) {
 		return nil, fmt.Errorf("invalid amounts length")
 	}
 
-	return amounts[1], nil
+	return amounts, nil
 }
 
-func (p *PancakeSwapper) GetTokenPriceInUSDT(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
-	parsedABI, err := abi.JSON(strings.NewReader(GetAmountsOutABI))
+// route picks the path for trading tokenAddress between the ends of
+// fallback: the route finder's best path when there is one, otherwise
+// fallback itself. It returns the path with its quote.
+func (p *PancakeSwapper) route(tokenAddress common.Address, fallback []common.Address, amountIn *big.Int) ([]common.Address, []*big.Int, error) {
+	if p.routes != nil {
+		return p.routes.Best(context.Background(), tokenAddress, fallback[0], fallback[len(fallback)-1], amountIn, p.tokenQuote(tokenAddress))
+	}
+	amounts, err := p.getAmountsOut(amountIn, fallback)
+	return fallback, amounts, err
+}
+
+func (p *PancakeSwapper) QuoteBuy(tokenAddress common.Address, amountBNB *big.Int) (*big.Int, error) {
+	_, amounts, err := p.route(tokenAddress, p.buyPath(tokenAddress), amountBNB)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse ABI: %w", err)
+		return nil, err
 	}
+	return amounts[len(amounts)-1], nil
+}
 
-	path := []common.Address{tokenAddress, WBNB, USDT}
-	data, err := parsedABI.Pack("getAmountsOut", amount, path)
+func (p *PancakeSwapper) BuyToken(tokenAddress common.Address, amountBNB *big.Int) (string, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(SwapExactETHForTokensABI))
 	if err != nil {
-		return nil, fmt.Errorf("failed to pack getAmountsOut: %w", err)
+		return "", fmt.Errorf("failed to parse ABI: %w", err)
 	}
 
-	result, err := p.client.CallContract(context.Background(), ethereum.CallMsg{
-		To:   &PancakeRouterV2,
-		Data: data,
-	}, nil)
+	deadline := big.NewInt(time.Now().Unix() + 300)
+
+	path, amounts, err := p.route(tokenAddress, p.buyPath(tokenAddress), amountBNB)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call getAmountsOut: %w", err)
+		return "", fmt.Errorf("failed to quote buy: %w", err)
 	}
+	quote := amounts[len(amounts)-1]
+	amountOutMin := p.minAmountOut(quote, p.tokenTax(tokenAddress).BuyBps)
+	log.Printf("[%s] Buy quote for %s: %s tokens, min out: %s", p.address.Hex(), tokenAddress.Hex(), quote.String(), amountOutMin.String())
 
-	outputs, err := parsedABI.Unpack("getAmountsOut", result)
+	data, err := parsedABI.Pack("swapExactETHForTokensSupportingFeeOnTransferTokens", amountOutMin, path, p.address, deadline)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unpack getAmountsOut: %w", err)
+		return "", fmt.Errorf("failed to pack data: %w", err)
 	}
 
-	amounts := outputs[0].([]*big.Int)
-	if len(amounts) < 3 {
-		return nil, fmt.Errorf("invalid amounts length")
+	signedTx, err := p.sendTransaction(methodBuy, p.gas.Buy, p.router, amountBNB, data)
+	if err != nil {
+		return "", err
 	}
 
-	return amounts[2], nil
+	p.OnTxDone(signedTx.Hash().Hex(), func(result TxResult) {
+		p.logRealizedBuy(result, tokenAddress, quote, amountOutMin)
+	})
+
+	return signedTx.Hash().Hex(), nil
 }
 
-func (p *PancakeSwapper) ApproveToken(tokenAddress common.Address, amount *big.Int) (string, error) {
-	parsedABI, err := abi.JSON(strings.NewReader(ERC20ABI))
+// BackrunBuy buys a token whose pair the pending trigger is about to create.
+// The pair cannot be quoted yet, so the output is priced from the expected
+// launch pool; only a hop into the token's quote is quoted on chain.
+func (p *PancakeSwapper) BackrunBuy(trigger *types.Transaction, tokenAddress common.Address, amountBNB *big.Int, pool LaunchPool) (string, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(SwapExactETHForTokensABI))
 	if err != nil {
-		return "", fmt.Errorf("failed to parse ERC20 ABI: %w", err)
+		return "", fmt.Errorf("failed to parse ABI: %w", err)
+	}
+
+	deadline := big.NewInt(time.Now().Unix() + 300)
+
+	path := p.buyPath(tokenAddress)
+	amountIn := amountBNB
+	if len(path) > 2 {
+		amounts, err := p.getAmountsOut(amountBNB, path[:2])
+		if err != nil {
+			return "", fmt.Errorf("failed to quote %s: %w", path[1].Hex(), err)
+		}
+		amountIn = amounts[1]
 	}
+	quote := pool.AmountOut(amountIn)
+	amountOutMin := p.minAmountOut(quote, p.tokenTax(tokenAddress).BuyBps)
+	log.Printf("[%s] Backrun quote for %s: %s tokens, min out: %s", p.address.Hex(), tokenAddress.Hex(), quote.String(), amountOutMin.String())
 
-	data, err := parsedABI.Pack("approve", PancakeRouterV2, amount)
+	data, err := parsedABI.Pack("swapExactETHForTokensSupportingFeeOnTransferTokens", amountOutMin, path, p.address, deadline)
 	if err != nil {
-		return "", fmt.Errorf("failed to pack approve: %w", err)
+		return "", fmt.Errorf("failed to pack data: %w", err)
 	}
 
-	nonce, err := p.client.PendingNonceAt(context.Background(), p.address)
+	signedTx, err := p.sendBehind(trigger, methodBuy, p.router, amountBNB, data)
 	if err != nil {
-		return "", fmt.Errorf("failed to get nonce: %w", err)
+		return "", err
 	}
 
-	tx := types.NewTransaction(nonce, tokenAddress, big.NewInt(0), p.gasLimit, p.gasPrice, data)
+	p.OnTxDone(signedTx.Hash().Hex(), func(result TxResult) {
+		p.logRealizedBuy(result, tokenAddress, quote, amountOutMin)
+	})
 
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(p.chainID), p.privateKey)
+	return signedTx.Hash().Hex(), nil
+}
+
+func (p *PancakeSwapper) Router() common.Address {
+	return p.router
+}
+
+func (p *PancakeSwapper) Factory() common.Address {
+	return p.factory
+}
+
+func (p *PancakeSwapper) GetTokenPrice(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+	_, amounts, err := p.route(tokenAddress, p.sellPath(tokenAddress), amount)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
+		return nil, err
 	}
+	return amounts[len(amounts)-1], nil
+}
 
-	err = p.client.SendTransaction(context.Background(), signedTx)
+func (p *PancakeSwapper) GetTokenPriceInUSDT(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+	path := append(p.sellPath(tokenAddress), USDT)
+	if p.tokenQuote(tokenAddress) == USDT {
+		path = []common.Address{tokenAddress, USDT}
+	}
+	_, amounts, err := p.route(tokenAddress, path, amount)
 	if err != nil {
-		return "", fmt.Errorf("failed to send transaction: %w", err)
+		return nil, err
 	}
+	return amounts[len(amounts)-1], nil
+}
 
-	return signedTx.Hash().Hex(), nil
+// ApproveToken makes sure the router may sell amount of the token. It only
+// sends an approval when the allowance falls short; see ensureApproval.
+func (p *PancakeSwapper) ApproveToken(tokenAddress common.Address, amount *big.Int) (string, error) {
+	return p.ensureApproval(tokenAddress, p.router, amount)
 }
 
 func (p *PancakeSwapper) SellToken(tokenAddress common.Address, amount *big.Int) (string, error) {
@@ -241,31 +218,29 @@ func (p *PancakeSwapper) SellToken(tokenAddress common.Address, amount *big.Int)
 		return "", fmt.Errorf("failed to parse ABI: %w", err)
 	}
 
-	path := []common.Address{tokenAddress, WBNB}
 	deadline := big.NewInt(time.Now().Unix() + 300)
-	amountOutMin := big.NewInt(0)
 
-	data, err := parsedABI.Pack("swapExactTokensForETHSupportingFeeOnTransferTokens", amount, amountOutMin, path, p.address, deadline)
+	path, amounts, err := p.route(tokenAddress, p.sellPath(tokenAddress), amount)
 	if err != nil {
-		return "", fmt.Errorf("failed to pack data: %w", err)
+		return "", fmt.Errorf("failed to quote sell: %w", err)
 	}
+	quote := amounts[len(amounts)-1]
+	amountOutMin := p.minAmountOut(quote, p.tokenTax(tokenAddress).SellBps)
+	log.Printf("[%s] Sell quote for %s: %s wei BNB, min out: %s", p.address.Hex(), tokenAddress.Hex(), quote.String(), amountOutMin.String())
 
-	nonce, err := p.client.PendingNonceAt(context.Background(), p.address)
+	data, err := parsedABI.Pack("swapExactTokensForETHSupportingFeeOnTransferTokens", amount, amountOutMin, path, p.address, deadline)
 	if err != nil {
-		return "", fmt.Errorf("failed to get nonce: %w", err)
+		return "", fmt.Errorf("failed to pack data: %w", err)
 	}
 
-	tx := types.NewTransaction(nonce, PancakeRouterV2, big.NewInt(0), p.gasLimit, p.gasPrice, data)
-
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(p.chainID), p.privateKey)
+	signedTx, err := p.sendTransaction(methodSell, p.gas.Sell, p.router, big.NewInt(0), data)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
+		return "", err
 	}
 
-	err = p.client.SendTransaction(context.Background(), signedTx)
-	if err != nil {
-		return "", fmt.Errorf("failed to send transaction: %w", err)
-	}
+	p.OnTxDone(signedTx.Hash().Hex(), func(result TxResult) {
+		p.logRealizedSell(result, tokenAddress, quote, amountOutMin)
+	})
 
 	return signedTx.Hash().Hex(), nil
 }
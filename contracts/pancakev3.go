@@ -0,0 +1,389 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const V3SmartRouterABI = `[{"inputs":[{"components":[{"internalType":"address","name":"tokenIn","type":"address"},{"internalType":"address","name":"tokenOut","type":"address"},{"internalType":"uint24","name":"fee","type":"uint24"},{"internalType":"address","name":"recipient","type":"address"},{"internalType":"uint256","name":"amountIn","type":"uint256"},{"internalType":"uint256","name":"amountOutMinimum","type":"uint256"},{"internalType":"uint160","name":"sqrtPriceLimitX96","type":"uint160"}],"internalType":"struct IV3SwapRouter.ExactInputSingleParams","name":"params","type":"tuple"}],"name":"exactInputSingle","outputs":[{"internalType":"uint256","name":"amountOut","type":"uint256"}],"stateMutability":"payable","type":"function"},{"inputs":[{"components":[{"internalType":"bytes","name":"path","type":"bytes"},{"internalType":"address","name":"recipient","type":"address"},{"internalType":"uint256","name":"amountIn","type":"uint256"},{"internalType":"uint256","name":"amountOutMinimum","type":"uint256"}],"internalType":"struct IV3SwapRouter.ExactInputParams","name":"params","type":"tuple"}],"name":"exactInput","outputs":[{"internalType":"uint256","name":"amountOut","type":"uint256"}],"stateMutability":"payable","type":"function"},{"inputs":[{"internalType":"uint256","name":"deadline","type":"uint256"},{"internalType":"bytes[]","name":"data","type":"bytes[]"}],"name":"multicall","outputs":[{"internalType":"bytes[]","name":"","type":"bytes[]"}],"stateMutability":"payable","type":"function"},{"inputs":[{"internalType":"uint256","name":"amountMinimum","type":"uint256"},{"internalType":"address","name":"recipient","type":"address"}],"name":"unwrapWETH9","outputs":[],"stateMutability":"payable","type":"function"}]`
+
+const V3QuoterABI = `[{"inputs":[{"components":[{"internalType":"address","name":"tokenIn","type":"address"},{"internalType":"address","name":"tokenOut","type":"address"},{"internalType":"uint256","name":"amountIn","type":"uint256"},{"internalType":"uint24","name":"fee","type":"uint24"},{"internalType":"uint160","name":"sqrtPriceLimitX96","type":"uint160"}],"internalType":"struct IQuoterV2.QuoteExactInputSingleParams","name":"params","type":"tuple"}],"name":"quoteExactInputSingle","outputs":[{"internalType":"uint256","name":"amountOut","type":"uint256"},{"internalType":"uint160","name":"sqrtPriceX96After","type":"uint160"},{"internalType":"uint32","name":"initializedTicksCrossed","type":"uint32"},{"internalType":"uint256","name":"gasEstimate","type":"uint256"}],"stateMutability":"nonpayable","type":"function"}]`
+
+// V3FeeTiers are the PancakeSwap V3 pool fees in hundredths of a bip.
+var V3FeeTiers = []uint32{100, 500, 2500, 10000}
+
+// The SmartRouter reads address(2) as "keep the output in the router", which
+// is how a sell leaves WBNB behind for unwrapWETH9.
+var v3AddressThis = common.HexToAddress("0x0000000000000000000000000000000000000002")
+
+var ErrNoV3Pool = errors.New("no PancakeSwap V3 pool")
+
+type v3ExactInputSingleParams struct {
+	TokenIn           common.Address
+	TokenOut          common.Address
+	Fee               *big.Int
+	Recipient         common.Address
+	AmountIn          *big.Int
+	AmountOutMinimum  *big.Int
+	SqrtPriceLimitX96 *big.Int
+}
+
+type v3ExactInputParams struct {
+	Path             []byte
+	Recipient        common.Address
+	AmountIn         *big.Int
+	AmountOutMinimum *big.Int
+}
+
+type v3QuoteExactInputSingleParams struct {
+	TokenIn           common.Address
+	TokenOut          common.Address
+	AmountIn          *big.Int
+	Fee               *big.Int
+	SqrtPriceLimitX96 *big.Int
+}
+
+// v3Route is a swap path with one fee per hop, so len(fees) == len(tokens)-1.
+type v3Route struct {
+	tokens []common.Address
+	fees   []uint32
+}
+
+// path encodes the route the way exactInput expects it: token, fee, token...
+func (r *v3Route) path() []byte {
+	var path []byte
+	for i, token := range r.tokens {
+		path = append(path, token.Bytes()...)
+		if i < len(r.fees) {
+			fee := r.fees[i]
+			path = append(path, byte(fee>>16), byte(fee>>8), byte(fee))
+		}
+	}
+	return path
+}
+
+func (r *v3Route) String() string {
+	var parts []string
+	for i, token := range r.tokens {
+		parts = append(parts, token.Hex())
+		if i < len(r.fees) {
+			parts = append(parts, fmt.Sprintf("(%d)", r.fees[i]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+type v3PoolKey struct {
+	a common.Address
+	b common.Address
+}
+
+func newV3PoolKey(tokenA, tokenB common.Address) v3PoolKey {
+	if tokenA.Cmp(tokenB) > 0 {
+		tokenA, tokenB = tokenB, tokenA
+	}
+	return v3PoolKey{a: tokenA, b: tokenB}
+}
+
+// PancakeV3Swapper trades through the PancakeSwap V3 SmartRouter. The fee
+// tier of every pool it touches is discovered on first use by quoting all
+// tiers and keeping the one that pays the most.
+type PancakeV3Swapper struct {
+	*baseSwapper
+	router common.Address
+	quoter common.Address
+	fees   map[v3PoolKey]uint32
+	feeMu  sync.RWMutex
+}
+
+func NewPancakeV3Swapper(client *ethclient.Client, nonces *NonceManager, tracker *TxTracker, privateKeyHex string, cfg SwapperConfig) (*PancakeV3Swapper, error) {
+	base, err := newBaseSwapper(client, nonces, tracker, privateKeyHex, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PancakeV3Swapper{
+		baseSwapper: base,
+		router:      cfg.Router,
+		quoter:      cfg.Quoter,
+		fees:        make(map[v3PoolKey]uint32),
+	}, nil
+}
+
+func (p *PancakeV3Swapper) Router() common.Address {
+	return p.router
+}
+
+func (p *PancakeV3Swapper) quoteExactInputSingle(ctx context.Context, tokenIn, tokenOut common.Address, fee uint32, amountIn *big.Int) (*big.Int, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(V3QuoterABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ABI: %w", err)
+	}
+
+	data, err := parsedABI.Pack("quoteExactInputSingle", v3QuoteExactInputSingleParams{
+		TokenIn:           tokenIn,
+		TokenOut:          tokenOut,
+		AmountIn:          amountIn,
+		Fee:               big.NewInt(int64(fee)),
+		SqrtPriceLimitX96: big.NewInt(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack quoteExactInputSingle: %w", err)
+	}
+
+	result, err := p.client.CallContract(ctx, ethereum.CallMsg{
+		To:   &p.quoter,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call quoteExactInputSingle: %w", err)
+	}
+
+	outputs, err := parsedABI.Unpack("quoteExactInputSingle", result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack quoteExactInputSingle: %w", err)
+	}
+
+	return outputs[0].(*big.Int), nil
+}
+
+// quoteHop quotes one pool, using the cached fee tier when there is one and
+// falling back to rediscovery if that pool stopped answering.
+func (p *PancakeV3Swapper) quoteHop(ctx context.Context, tokenIn, tokenOut common.Address, amountIn *big.Int) (uint32, *big.Int, error) {
+	key := newV3PoolKey(tokenIn, tokenOut)
+
+	p.feeMu.RLock()
+	fee, ok := p.fees[key]
+	p.feeMu.RUnlock()
+	if ok {
+		amountOut, err := p.quoteExactInputSingle(ctx, tokenIn, tokenOut, fee, amountIn)
+		if err == nil && amountOut.Sign() > 0 {
+			return fee, amountOut, nil
+		}
+		p.feeMu.Lock()
+		delete(p.fees, key)
+		p.feeMu.Unlock()
+	}
+
+	fee, amountOut, err := p.discoverFee(ctx, tokenIn, tokenOut, amountIn)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	p.feeMu.Lock()
+	p.fees[key] = fee
+	p.feeMu.Unlock()
+	return fee, amountOut, nil
+}
+
+func (p *PancakeV3Swapper) discoverFee(ctx context.Context, tokenIn, tokenOut common.Address, amountIn *big.Int) (uint32, *big.Int, error) {
+	quotes := make([]*big.Int, len(V3FeeTiers))
+	var wg sync.WaitGroup
+	for i, fee := range V3FeeTiers {
+		wg.Add(1)
+		go func(idx int, fee uint32) {
+			defer wg.Done()
+			if amountOut, err := p.quoteExactInputSingle(ctx, tokenIn, tokenOut, fee, amountIn); err == nil {
+				quotes[idx] = amountOut
+			}
+		}(i, fee)
+	}
+	wg.Wait()
+
+	best := -1
+	for i, quote := range quotes {
+		if quote != nil && quote.Sign() > 0 && (best < 0 || quote.Cmp(quotes[best]) > 0) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, nil, fmt.Errorf("%w for %s/%s", ErrNoV3Pool, tokenIn.Hex(), tokenOut.Hex())
+	}
+	return V3FeeTiers[best], quotes[best], nil
+}
+
+func (p *PancakeV3Swapper) quoteRoute(ctx context.Context, tokens []common.Address, amountIn *big.Int) (*v3Route, *big.Int, error) {
+	route := &v3Route{tokens: tokens}
+	amount := amountIn
+	for i := 0; i+1 < len(tokens); i++ {
+		fee, amountOut, err := p.quoteHop(ctx, tokens[i], tokens[i+1], amount)
+		if err != nil {
+			return nil, nil, err
+		}
+		route.fees = append(route.fees, fee)
+		amount = amountOut
+	}
+	return route, amount, nil
+}
+
+// bestRoute goes through the token's quote asset when one is set, otherwise
+// it prefers the direct pool and only goes through USDT when the token has no
+// V3 pool against the other side.
+func (p *PancakeV3Swapper) bestRoute(ctx context.Context, tokenIn, tokenOut common.Address, amountIn *big.Int) (*v3Route, *big.Int, error) {
+	token := tokenIn
+	if token == WBNB {
+		token = tokenOut
+	}
+	if quote := p.tokenQuote(token); quote != WBNB && quote != tokenIn && quote != tokenOut {
+		return p.quoteRoute(ctx, []common.Address{tokenIn, quote, tokenOut}, amountIn)
+	}
+
+	route, amountOut, err := p.quoteRoute(ctx, []common.Address{tokenIn, tokenOut}, amountIn)
+	if err == nil || !errors.Is(err, ErrNoV3Pool) || tokenIn == USDT || tokenOut == USDT {
+		return route, amountOut, err
+	}
+	return p.quoteRoute(ctx, []common.Address{tokenIn, USDT, tokenOut}, amountIn)
+}
+
+func (p *PancakeV3Swapper) packSwap(route *v3Route, recipient common.Address, amountIn, amountOutMin *big.Int) ([]byte, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(V3SmartRouterABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ABI: %w", err)
+	}
+
+	if len(route.fees) == 1 {
+		return parsedABI.Pack("exactInputSingle", v3ExactInputSingleParams{
+			TokenIn:           route.tokens[0],
+			TokenOut:          route.tokens[1],
+			Fee:               big.NewInt(int64(route.fees[0])),
+			Recipient:         recipient,
+			AmountIn:          amountIn,
+			AmountOutMinimum:  amountOutMin,
+			SqrtPriceLimitX96: big.NewInt(0),
+		})
+	}
+	return parsedABI.Pack("exactInput", v3ExactInputParams{
+		Path:             route.path(),
+		Recipient:        recipient,
+		AmountIn:         amountIn,
+		AmountOutMinimum: amountOutMin,
+	})
+}
+
+func (p *PancakeV3Swapper) packMulticall(calls ...[]byte) ([]byte, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(V3SmartRouterABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ABI: %w", err)
+	}
+
+	deadline := big.NewInt(time.Now().Unix() + 300)
+	return parsedABI.Pack("multicall", deadline, calls)
+}
+
+func (p *PancakeV3Swapper) QuoteBuy(tokenAddress common.Address, amountBNB *big.Int) (*big.Int, error) {
+	_, amountOut, err := p.bestRoute(context.Background(), WBNB, tokenAddress, amountBNB)
+	return amountOut, err
+}
+
+// BuyToken sends BNB as msg.value; the router wraps it itself when the first
+// token of the route is WBNB.
+// BackrunBuy is unsupported: launches never seed a V3 pool.
+func (p *PancakeV3Swapper) BackrunBuy(trigger *types.Transaction, tokenAddress common.Address, amountBNB *big.Int, pool LaunchPool) (string, error) {
+	return "", ErrBackrunUnsupported
+}
+
+func (p *PancakeV3Swapper) BuyToken(tokenAddress common.Address, amountBNB *big.Int) (string, error) {
+	route, quote, err := p.bestRoute(context.Background(), WBNB, tokenAddress, amountBNB)
+	if err != nil {
+		return "", fmt.Errorf("failed to quote buy: %w", err)
+	}
+	amountOutMin := p.minAmountOut(quote, p.tokenTax(tokenAddress).BuyBps)
+	log.Printf("[%s] V3 buy quote for %s via %s: %s tokens, min out: %s", p.address.Hex(), tokenAddress.Hex(), route.String(), quote.String(), amountOutMin.String())
+
+	swap, err := p.packSwap(route, p.address, amountBNB, amountOutMin)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack swap: %w", err)
+	}
+	data, err := p.packMulticall(swap)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack data: %w", err)
+	}
+
+	signedTx, err := p.sendTransaction(methodBuy, p.gas.Buy, p.router, amountBNB, data)
+	if err != nil {
+		return "", err
+	}
+
+	p.OnTxDone(signedTx.Hash().Hex(), func(result TxResult) {
+		p.logRealizedBuy(result, tokenAddress, quote, amountOutMin)
+	})
+
+	return signedTx.Hash().Hex(), nil
+}
+
+// SellToken swaps into WBNB held by the router and unwraps it to the wallet
+// in the same multicall.
+func (p *PancakeV3Swapper) SellToken(tokenAddress common.Address, amount *big.Int) (string, error) {
+	route, quote, err := p.bestRoute(context.Background(), tokenAddress, WBNB, amount)
+	if err != nil {
+		return "", fmt.Errorf("failed to quote sell: %w", err)
+	}
+	amountOutMin := p.minAmountOut(quote, p.tokenTax(tokenAddress).SellBps)
+	log.Printf("[%s] V3 sell quote for %s via %s: %s wei BNB, min out: %s", p.address.Hex(), tokenAddress.Hex(), route.String(), quote.String(), amountOutMin.String())
+
+	swap, err := p.packSwap(route, v3AddressThis, amount, amountOutMin)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack swap: %w", err)
+	}
+
+	parsedABI, err := abi.JSON(strings.NewReader(V3SmartRouterABI))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse ABI: %w", err)
+	}
+	unwrap, err := parsedABI.Pack("unwrapWETH9", amountOutMin, p.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack unwrapWETH9: %w", err)
+	}
+
+	data, err := p.packMulticall(swap, unwrap)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack data: %w", err)
+	}
+
+	signedTx, err := p.sendTransaction(methodSell, p.gas.Sell, p.router, big.NewInt(0), data)
+	if err != nil {
+		return "", err
+	}
+
+	p.OnTxDone(signedTx.Hash().Hex(), func(result TxResult) {
+		p.logRealizedSell(result, tokenAddress, quote, amountOutMin)
+	})
+
+	return signedTx.Hash().Hex(), nil
+}
+
+func (p *PancakeV3Swapper) ApproveToken(tokenAddress common.Address, amount *big.Int) (string, error) {
+	return p.ensureApproval(tokenAddress, p.router, amount)
+}
+
+func (p *PancakeV3Swapper) GetTokenPrice(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+	_, amountOut, err := p.bestRoute(context.Background(), tokenAddress, WBNB, amount)
+	return amountOut, err
+}
+
+func (p *PancakeV3Swapper) GetTokenPriceInUSDT(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+	if quote := p.tokenQuote(tokenAddress); quote == USDT {
+		_, amountOut, err := p.quoteRoute(context.Background(), []common.Address{tokenAddress, USDT}, amount)
+		return amountOut, err
+	} else if quote != WBNB {
+		_, amountOut, err := p.quoteRoute(context.Background(), []common.Address{tokenAddress, quote, WBNB, USDT}, amount)
+		return amountOut, err
+	}
+	_, amountOut, err := p.quoteRoute(context.Background(), []common.Address{tokenAddress, WBNB, USDT}, amount)
+	if errors.Is(err, ErrNoV3Pool) {
+		_, amountOut, err = p.quoteRoute(context.Background(), []common.Address{tokenAddress, USDT}, amount)
+	}
+	return amountOut, err
+}
@@ -0,0 +1,166 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// The txpool refuses a replacement unless it pays at least 10% more.
+const minReplacementBumpPercent = 10
+
+var ErrGasCapReached = errors.New("gas price cap reached")
+
+// nextFees applies the bump schedule to the fees of the transaction that is
+// currently pending. Each entry is relative to the previous fees and the last
+// entry repeats once the schedule runs out. Dynamic fee transactions bump tip
+// and fee cap together, and the cap applies to the fee cap.
+func (b *baseSwapper) nextFees(current *types.Transaction, replacements int) (*GasFees, error) {
+	bump := minReplacementBumpPercent
+	if len(b.gasBumps) > 0 {
+		idx := replacements
+		if idx >= len(b.gasBumps) {
+			idx = len(b.gasBumps) - 1
+		}
+		if b.gasBumps[idx] > bump {
+			bump = b.gasBumps[idx]
+		}
+	}
+
+	if current.Type() == types.DynamicFeeTxType {
+		feeCap, err := b.capGasPrice(current.GasFeeCap(), bump)
+		if err != nil {
+			return nil, err
+		}
+		tip := bumpPercent(current.GasTipCap(), bump)
+		if tip.Cmp(feeCap) > 0 {
+			tip = new(big.Int).Set(feeCap)
+		}
+		return &GasFees{GasTipCap: tip, GasFeeCap: feeCap}, nil
+	}
+
+	gasPrice, err := b.capGasPrice(current.GasPrice(), bump)
+	if err != nil {
+		return nil, err
+	}
+	return &GasFees{GasPrice: gasPrice}, nil
+}
+
+func (b *baseSwapper) capGasPrice(current *big.Int, bump int) (*big.Int, error) {
+	next := bumpPercent(current, bump)
+	if b.maxGasPrice.Sign() <= 0 || next.Cmp(b.maxGasPrice) <= 0 {
+		return next, nil
+	}
+
+	// Clamp to the cap as long as the cap still clears the txpool minimum.
+	if b.maxGasPrice.Cmp(bumpPercent(current, minReplacementBumpPercent)) < 0 {
+		return nil, fmt.Errorf("%w: %s wei is already at the %s wei cap", ErrGasCapReached, current.String(), b.maxGasPrice.String())
+	}
+	return new(big.Int).Set(b.maxGasPrice), nil
+}
+
+func bumpPercent(price *big.Int, percent int) *big.Int {
+	bumped := new(big.Int).Mul(price, big.NewInt(int64(100+percent)))
+	bumped.Div(bumped, big.NewInt(100))
+	return bumped.Add(bumped, big.NewInt(1))
+}
+
+func (b *baseSwapper) replaceTransaction(txHash string, cancel bool) (string, error) {
+	hash := common.HexToHash(txHash)
+	if b.tracker.Done(hash) {
+		return "", fmt.Errorf("transaction %s is no longer pending", txHash)
+	}
+
+	current, ok := b.tracker.Latest(hash)
+	if !ok {
+		return "", fmt.Errorf("transaction %s is not tracked", txHash)
+	}
+	status, _ := b.tracker.Status(hash)
+
+	fees, err := b.nextFees(current, status.Replacements)
+	if err != nil {
+		return "", err
+	}
+
+	var tx *types.Transaction
+	if cancel {
+		tx = b.newTransaction(current.Nonce(), b.address, big.NewInt(0), params.TxGas, fees, nil)
+	} else {
+		tx = b.newTransaction(current.Nonce(), *current.To(), current.Value(), current.Gas(), fees, current.Data())
+	}
+
+	signedTx, err := b.signTransaction(tx)
+	if err != nil {
+		return "", err
+	}
+
+	err = b.client.SendTransaction(context.Background(), signedTx)
+	if err != nil && !isAlreadyKnown(err) {
+		return "", fmt.Errorf("failed to send replacement: %w", err)
+	}
+
+	if err := b.tracker.Replace(hash, signedTx, cancel); err != nil {
+		return "", err
+	}
+
+	action := "Sped up"
+	if cancel {
+		action = "Cancelling"
+	}
+	log.Printf("[%s] %s nonce %d: %s -> %s (%s)", b.address.Hex(), action, current.Nonce(), current.Hash().Hex(), signedTx.Hash().Hex(), fees.String())
+
+	return signedTx.Hash().Hex(), nil
+}
+
+func (b *baseSwapper) SpeedUp(txHash string) (string, error) {
+	return b.replaceTransaction(txHash, false)
+}
+
+// CancelTx replaces the pending transaction with a 0-value transfer to
+// ourselves, which frees the nonce without doing anything.
+func (b *baseSwapper) CancelTx(txHash string) (string, error) {
+	return b.replaceTransaction(txHash, true)
+}
+
+// Escalate speeds the transaction up every time it has sat in the pool for
+// everyBlocks blocks, until it is final or the gas cap is hit.
+func (b *baseSwapper) Escalate(ctx context.Context, txHash string, everyBlocks uint64) {
+	hash := common.HexToHash(txHash)
+	ticker := time.NewTicker(trackerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if b.tracker.Done(hash) {
+			return
+		}
+
+		blocks, ok := b.tracker.BlocksPending(hash)
+		if !ok || blocks < everyBlocks {
+			continue
+		}
+
+		newHash, err := b.SpeedUp(txHash)
+		if errors.Is(err, ErrGasCapReached) {
+			log.Printf("[%s] Stop escalating %s: %v", b.address.Hex(), txHash, err)
+			return
+		}
+		if err != nil {
+			log.Printf("[%s] Failed to speed up %s: %v", b.address.Hex(), txHash, err)
+			continue
+		}
+		log.Printf("[%s] %s not mined after %d blocks, replaced by %s", b.address.Hex(), txHash, blocks, newHash)
+	}
+}
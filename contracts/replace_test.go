@@ -0,0 +1,156 @@
+package contracts
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// milliGwei builds a price from thousandths of a gwei.
+func milliGwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e6))
+}
+
+func plusOne(n *big.Int) *big.Int {
+	return new(big.Int).Add(n, big.NewInt(1))
+}
+
+func TestNextFees(t *testing.T) {
+	legacy := types.NewTx(&types.LegacyTx{GasPrice: milliGwei(1000)})
+	dynamic := types.NewTx(&types.DynamicFeeTx{GasTipCap: milliGwei(1000), GasFeeCap: milliGwei(3000)})
+	tipAtCap := types.NewTx(&types.DynamicFeeTx{GasTipCap: milliGwei(3000), GasFeeCap: milliGwei(3000)})
+
+	tests := []struct {
+		name         string
+		tx           *types.Transaction
+		bumps        []int
+		maxGasPrice  *big.Int
+		replacements int
+		wantPrice    *big.Int
+		wantTip      *big.Int
+		wantFeeCap   *big.Int
+		wantErr      error
+	}{
+		{
+			name:        "no schedule bumps the txpool minimum",
+			tx:          legacy,
+			maxGasPrice: new(big.Int),
+			wantPrice:   plusOne(milliGwei(1100)),
+		},
+		{
+			name:        "schedule below the minimum is raised to it",
+			tx:          legacy,
+			bumps:       []int{5},
+			maxGasPrice: new(big.Int),
+			wantPrice:   plusOne(milliGwei(1100)),
+		},
+		{
+			name:        "first replacement takes the first entry",
+			tx:          legacy,
+			bumps:       []int{12, 25, 50},
+			maxGasPrice: new(big.Int),
+			wantPrice:   plusOne(milliGwei(1120)),
+		},
+		{
+			name:         "second replacement takes the second entry",
+			tx:           legacy,
+			bumps:        []int{12, 25, 50},
+			maxGasPrice:  new(big.Int),
+			replacements: 1,
+			wantPrice:    plusOne(milliGwei(1250)),
+		},
+		{
+			name:         "last entry repeats past the schedule",
+			tx:           legacy,
+			bumps:        []int{12, 25, 50},
+			maxGasPrice:  new(big.Int),
+			replacements: 5,
+			wantPrice:    plusOne(milliGwei(1500)),
+		},
+		{
+			name:        "bump under the cap is kept",
+			tx:          legacy,
+			bumps:       []int{50},
+			maxGasPrice: milliGwei(2000),
+			wantPrice:   plusOne(milliGwei(1500)),
+		},
+		{
+			name:        "bump over the cap is clamped to it",
+			tx:          legacy,
+			bumps:       []int{50},
+			maxGasPrice: milliGwei(1300),
+			wantPrice:   milliGwei(1300),
+		},
+		{
+			name:        "cap exactly at the txpool minimum is used",
+			tx:          legacy,
+			bumps:       []int{50},
+			maxGasPrice: plusOne(milliGwei(1100)),
+			wantPrice:   plusOne(milliGwei(1100)),
+		},
+		{
+			name:        "cap below the txpool minimum fails",
+			tx:          legacy,
+			bumps:       []int{50},
+			maxGasPrice: milliGwei(1100),
+			wantErr:     ErrGasCapReached,
+		},
+		{
+			name:        "dynamic fees bump tip and fee cap together",
+			tx:          dynamic,
+			maxGasPrice: new(big.Int),
+			wantTip:     plusOne(milliGwei(1100)),
+			wantFeeCap:  plusOne(milliGwei(3300)),
+		},
+		{
+			name:        "dynamic fee cap is clamped, tip is not",
+			tx:          dynamic,
+			bumps:       []int{25},
+			maxGasPrice: milliGwei(3400),
+			wantTip:     plusOne(milliGwei(1250)),
+			wantFeeCap:  milliGwei(3400),
+		},
+		{
+			name:        "tip never exceeds the clamped fee cap",
+			tx:          tipAtCap,
+			bumps:       []int{50},
+			maxGasPrice: milliGwei(3400),
+			wantTip:     milliGwei(3400),
+			wantFeeCap:  milliGwei(3400),
+		},
+		{
+			name:        "dynamic fee cap below the txpool minimum fails",
+			tx:          dynamic,
+			maxGasPrice: milliGwei(3200),
+			wantErr:     ErrGasCapReached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &baseSwapper{maxGasPrice: tt.maxGasPrice, gasBumps: tt.bumps}
+
+			fees, err := b.nextFees(tt.tx, tt.replacements)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			check := func(field string, got, want *big.Int) {
+				if (got == nil) != (want == nil) || (want != nil && got.Cmp(want) != 0) {
+					t.Errorf("%s = %v, want %v", field, got, want)
+				}
+			}
+			check("GasPrice", fees.GasPrice, tt.wantPrice)
+			check("GasTipCap", fees.GasTipCap, tt.wantTip)
+			check("GasFeeCap", fees.GasFeeCap, tt.wantFeeCap)
+		})
+	}
+}
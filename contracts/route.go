@@ -0,0 +1,286 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var (
+	USDC  = common.HexToAddress("0x8AC76a51cc950d9822D68b83fE1Ad97B32Cd580d")
+	BUSD  = common.HexToAddress("0xe9e7CEA3DedcA5984780Bafc599bD69ADd087D56")
+	FDUSD = common.HexToAddress("0xc5f0f7b66764F6ec8C8Dff7BA683102295E16409")
+)
+
+// DefaultRouteBases are the tokens the route finder hops through when none
+// are configured.
+var DefaultRouteBases = []common.Address{WBNB, USDT, USDC, BUSD, FDUSD}
+
+const PancakeFactoryABI = `[{"inputs":[{"internalType":"address","name":"","type":"address"},{"internalType":"address","name":"","type":"address"}],"name":"getPair","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"}]`
+
+const PancakePairABI = `[{"inputs":[],"name":"getReserves","outputs":[{"internalType":"uint112","name":"_reserve0","type":"uint112"},{"internalType":"uint112","name":"_reserve1","type":"uint112"},{"internalType":"uint32","name":"_blockTimestampLast","type":"uint32"}],"stateMutability":"view","type":"function"}]`
+
+var (
+	pancakeFactoryABI = mustParseABI(PancakeFactoryABI)
+	pancakePairABI    = mustParseABI(PancakePairABI)
+)
+
+type v2PairKey struct {
+	a common.Address
+	b common.Address
+}
+
+func newV2PairKey(tokenA, tokenB common.Address) v2PairKey {
+	if tokenA.Cmp(tokenB) > 0 {
+		tokenA, tokenB = tokenB, tokenA
+	}
+	return v2PairKey{a: tokenA, b: tokenB}
+}
+
+type routeKey struct {
+	from common.Address
+	to   common.Address
+}
+
+// cachedRoute is a chosen path together with the reserves of the token's own
+// pair at the time it was chosen.
+type cachedRoute struct {
+	path     []common.Address
+	pair     common.Address
+	reserves [2]*big.Int
+}
+
+// RouteFinder picks V2 paths for a token. Candidates are the direct pair and
+// every one-hop path through a base token, limited to pairs the factory
+// knows; each is quoted with getAmountsOut and the best output wins. The
+// choice is cached per token and direction until the reserves of the token's
+// pair move by more than the reprice threshold, or the cached path stops
+// quoting. It is shared by every wallet.
+type RouteFinder struct {
+	client     *ethclient.Client
+	router     common.Address
+	factory    common.Address
+	bases      []common.Address
+	repriceBps int64
+	pairs      map[v2PairKey]common.Address
+	pairMu     sync.RWMutex
+	routes     map[routeKey]*cachedRoute
+	routeMu    sync.RWMutex
+}
+
+func NewRouteFinder(client *ethclient.Client, router, factory common.Address, bases []common.Address, repricePercent float64) *RouteFinder {
+	if len(bases) == 0 {
+		bases = DefaultRouteBases
+	}
+	return &RouteFinder{
+		client:     client,
+		router:     router,
+		factory:    factory,
+		bases:      bases,
+		repriceBps: int64(repricePercent * 100),
+		pairs:      make(map[v2PairKey]common.Address),
+		routes:     make(map[routeKey]*cachedRoute),
+	}
+}
+
+// Best returns the best path from one token to another and its quote.
+// tokenAddress is the side being traded, whose pair is watched for reserve
+// changes; via is an extra intermediate to try, such as the quote the token
+// launched against.
+func (r *RouteFinder) Best(ctx context.Context, tokenAddress, from, to common.Address, amountIn *big.Int, via common.Address) ([]common.Address, []*big.Int, error) {
+	key := routeKey{from: from, to: to}
+
+	r.routeMu.RLock()
+	cached, ok := r.routes[key]
+	r.routeMu.RUnlock()
+	if ok {
+		if fresh, err := r.fresh(ctx, cached); err == nil && fresh {
+			amounts, err := getAmountsOut(ctx, r.client, r.router, amountIn, cached.path)
+			if err == nil {
+				return cached.path, amounts, nil
+			}
+		}
+		r.routeMu.Lock()
+		delete(r.routes, key)
+		r.routeMu.Unlock()
+	}
+
+	path, amounts, err := r.discover(ctx, from, to, amountIn, via)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	route := &cachedRoute{path: path}
+	for i := 0; i+1 < len(path); i++ {
+		if path[i] == tokenAddress || path[i+1] == tokenAddress {
+			route.pair, _ = r.pair(ctx, path[i], path[i+1])
+			break
+		}
+	}
+	if route.pair != (common.Address{}) {
+		route.reserves, _ = r.reserves(ctx, route.pair)
+	}
+
+	r.routeMu.Lock()
+	r.routes[key] = route
+	r.routeMu.Unlock()
+	log.Printf("Route for %s: %s", tokenAddress.Hex(), formatPath(path))
+	return path, amounts, nil
+}
+
+// fresh reports whether the token's pair reserves are still within the
+// reprice threshold of those the route was chosen at.
+func (r *RouteFinder) fresh(ctx context.Context, route *cachedRoute) (bool, error) {
+	if route.pair == (common.Address{}) || route.reserves[0] == nil {
+		return true, nil
+	}
+	reserves, err := r.reserves(ctx, route.pair)
+	if err != nil {
+		return false, err
+	}
+	for i := range reserves {
+		if movedBps(route.reserves[i], reserves[i]) > r.repriceBps {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (r *RouteFinder) discover(ctx context.Context, from, to common.Address, amountIn *big.Int, via common.Address) ([]common.Address, []*big.Int, error) {
+	hops := r.bases
+	if via != (common.Address{}) && !containsAddress(hops, via) {
+		hops = append([]common.Address{via}, hops...)
+	}
+
+	candidates := [][]common.Address{{from, to}}
+	for _, hop := range hops {
+		if hop != from && hop != to {
+			candidates = append(candidates, []common.Address{from, hop, to})
+		}
+	}
+
+	quotes := make([][]*big.Int, len(candidates))
+	var wg sync.WaitGroup
+	for i, path := range candidates {
+		wg.Add(1)
+		go func(idx int, path []common.Address) {
+			defer wg.Done()
+			for j := 0; j+1 < len(path); j++ {
+				if pair, err := r.pair(ctx, path[j], path[j+1]); err != nil || pair == (common.Address{}) {
+					return
+				}
+			}
+			if amounts, err := getAmountsOut(ctx, r.client, r.router, amountIn, path); err == nil {
+				quotes[idx] = amounts
+			}
+		}(i, path)
+	}
+	wg.Wait()
+
+	best := -1
+	for i, amounts := range quotes {
+		if amounts == nil || amounts[len(amounts)-1].Sign() <= 0 {
+			continue
+		}
+		if best < 0 || amounts[len(amounts)-1].Cmp(quotes[best][len(quotes[best])-1]) > 0 {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, nil, fmt.Errorf("no V2 route from %s to %s", from.Hex(), to.Hex())
+	}
+	return candidates[best], quotes[best], nil
+}
+
+// pair looks a pair up on the factory. Only existing pairs are cached, since
+// a missing one can be created at any time.
+func (r *RouteFinder) pair(ctx context.Context, tokenA, tokenB common.Address) (common.Address, error) {
+	key := newV2PairKey(tokenA, tokenB)
+
+	r.pairMu.RLock()
+	pair, ok := r.pairs[key]
+	r.pairMu.RUnlock()
+	if ok {
+		return pair, nil
+	}
+
+	data, err := pancakeFactoryABI.Pack("getPair", tokenA, tokenB)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to pack getPair: %w", err)
+	}
+	result, err := r.client.CallContract(ctx, ethereum.CallMsg{To: &r.factory, Data: data}, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to call getPair: %w", err)
+	}
+	outputs, err := pancakeFactoryABI.Unpack("getPair", result)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to unpack getPair: %w", err)
+	}
+
+	pair = outputs[0].(common.Address)
+	if pair != (common.Address{}) {
+		r.pairMu.Lock()
+		r.pairs[key] = pair
+		r.pairMu.Unlock()
+	}
+	return pair, nil
+}
+
+func (r *RouteFinder) reserves(ctx context.Context, pair common.Address) ([2]*big.Int, error) {
+	data, err := pancakePairABI.Pack("getReserves")
+	if err != nil {
+		return [2]*big.Int{}, fmt.Errorf("failed to pack getReserves: %w", err)
+	}
+	result, err := r.client.CallContract(ctx, ethereum.CallMsg{To: &pair, Data: data}, nil)
+	if err != nil {
+		return [2]*big.Int{}, fmt.Errorf("failed to call getReserves: %w", err)
+	}
+	outputs, err := pancakePairABI.Unpack("getReserves", result)
+	if err != nil {
+		return [2]*big.Int{}, fmt.Errorf("failed to unpack getReserves: %w", err)
+	}
+	return [2]*big.Int{outputs[0].(*big.Int), outputs[1].(*big.Int)}, nil
+}
+
+// movedBps is how far a reserve moved relative to where it was, in basis
+// points.
+func movedBps(before, after *big.Int) int64 {
+	if before.Sign() <= 0 {
+		if after.Sign() > 0 {
+			return bpsDenominator
+		}
+		return 0
+	}
+	diff := new(big.Int).Sub(after, before)
+	diff.Abs(diff)
+	diff.Mul(diff, big.NewInt(bpsDenominator))
+	diff.Div(diff, before)
+	if !diff.IsInt64() {
+		return bpsDenominator
+	}
+	return diff.Int64()
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPath(path []common.Address) string {
+	parts := make([]string, len(path))
+	for i, token := range path {
+		parts[i] = token.Hex()
+	}
+	return strings.Join(parts, " -> ")
+}
@@ -0,0 +1,258 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var testFactory = common.HexToAddress("0xcA143Ce32Fe78f1f7019d7d551a6402fC5350c73")
+
+// callArgs is the part of an eth_call request the stub nodes read.
+type callArgs struct {
+	To    *common.Address `json:"to"`
+	Input hexutil.Bytes   `json:"input"`
+}
+
+// newTestClient serves node's methods under the eth namespace in-process.
+func newTestClient(t *testing.T, node interface{}) *ethclient.Client {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", node); err != nil {
+		t.Fatal(err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+	return client
+}
+
+var getAmountsOutTestABI = mustParseABI(GetAmountsOutABI)
+
+// routeNode answers eth_call for a V2 factory, its pairs and a router that
+// quotes each path at a fixed output.
+type routeNode struct {
+	mu       sync.Mutex
+	pairs    map[v2PairKey]common.Address
+	reserves map[common.Address][2]*big.Int
+	quotes   map[string]*big.Int
+}
+
+func newRouteNode() *routeNode {
+	return &routeNode{
+		pairs:    make(map[v2PairKey]common.Address),
+		reserves: make(map[common.Address][2]*big.Int),
+		quotes:   make(map[string]*big.Int),
+	}
+}
+
+func (n *routeNode) addPair(a, b common.Address) common.Address {
+	pair := common.BigToAddress(big.NewInt(int64(0x1000 + len(n.pairs))))
+	n.pairs[newV2PairKey(a, b)] = pair
+	n.reserves[pair] = [2]*big.Int{ether(1000), ether(1000)}
+	return pair
+}
+
+func (n *routeNode) quote(out int64, path ...common.Address) {
+	n.quotes[formatPath(path)] = big.NewInt(out)
+}
+
+func (n *routeNode) Call(args callArgs, block string) (hexutil.Bytes, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch *args.To {
+	case testFactory:
+		inputs, err := pancakeFactoryABI.Methods["getPair"].Inputs.Unpack(args.Input[4:])
+		if err != nil {
+			return nil, err
+		}
+		pair := n.pairs[newV2PairKey(inputs[0].(common.Address), inputs[1].(common.Address))]
+		return pancakeFactoryABI.Methods["getPair"].Outputs.Pack(pair)
+
+	case testRouter:
+		method := getAmountsOutTestABI.Methods["getAmountsOut"]
+		inputs, err := method.Inputs.Unpack(args.Input[4:])
+		if err != nil {
+			return nil, err
+		}
+		path := inputs[1].([]common.Address)
+		out, ok := n.quotes[formatPath(path)]
+		if !ok {
+			return nil, errors.New("execution reverted: INSUFFICIENT_LIQUIDITY")
+		}
+		amounts := make([]*big.Int, len(path))
+		amounts[0] = inputs[0].(*big.Int)
+		for i := 1; i < len(path); i++ {
+			amounts[i] = new(big.Int)
+		}
+		amounts[len(path)-1] = out
+		return method.Outputs.Pack(amounts)
+	}
+
+	reserves, ok := n.reserves[*args.To]
+	if !ok {
+		return nil, errors.New("execution reverted")
+	}
+	return pancakePairABI.Methods["getReserves"].Outputs.Pack(reserves[0], reserves[1], uint32(0))
+}
+
+func TestRouteFinderBest(t *testing.T) {
+	via := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+
+	tests := []struct {
+		name   string
+		setup  func(n *routeNode)
+		via    common.Address
+		change func(n *routeNode)
+		want   []common.Address
+		// wantAfter is the path chosen once change has been applied.
+		wantAfter []common.Address
+		wantErr   bool
+	}{
+		{
+			name: "direct pair",
+			setup: func(n *routeNode) {
+				n.addPair(WBNB, testToken)
+				n.quote(100, WBNB, testToken)
+			},
+			want: []common.Address{WBNB, testToken},
+		},
+		{
+			name: "one hop through a base beats the direct pair",
+			setup: func(n *routeNode) {
+				n.addPair(WBNB, testToken)
+				n.addPair(WBNB, USDT)
+				n.addPair(USDT, testToken)
+				n.quote(100, WBNB, testToken)
+				n.quote(150, WBNB, USDT, testToken)
+			},
+			want: []common.Address{WBNB, USDT, testToken},
+		},
+		{
+			name: "one hop through the launch quote",
+			setup: func(n *routeNode) {
+				n.addPair(WBNB, via)
+				n.addPair(via, testToken)
+				n.quote(100, WBNB, via, testToken)
+			},
+			via:  via,
+			want: []common.Address{WBNB, via, testToken},
+		},
+		{
+			name: "hop without a pair is not tried",
+			setup: func(n *routeNode) {
+				n.addPair(WBNB, testToken)
+				n.addPair(WBNB, USDT)
+				n.quote(100, WBNB, testToken)
+				n.quote(150, WBNB, USDT, testToken)
+			},
+			want: []common.Address{WBNB, testToken},
+		},
+		{
+			name:    "no pair at all",
+			setup:   func(n *routeNode) {},
+			wantErr: true,
+		},
+		{
+			name: "cached route kept while reserves stay within the threshold",
+			setup: func(n *routeNode) {
+				n.addPair(WBNB, testToken)
+				n.addPair(WBNB, USDT)
+				n.addPair(USDT, testToken)
+				n.quote(150, WBNB, testToken)
+				n.quote(100, WBNB, USDT, testToken)
+			},
+			change: func(n *routeNode) {
+				pair := n.pairs[newV2PairKey(WBNB, testToken)]
+				n.reserves[pair] = [2]*big.Int{ether(1040), ether(1000)}
+				n.quote(200, WBNB, USDT, testToken)
+			},
+			want:      []common.Address{WBNB, testToken},
+			wantAfter: []common.Address{WBNB, testToken},
+		},
+		{
+			name: "reserves moving past the threshold reprice the route",
+			setup: func(n *routeNode) {
+				n.addPair(WBNB, testToken)
+				n.addPair(WBNB, USDT)
+				n.addPair(USDT, testToken)
+				n.quote(150, WBNB, testToken)
+				n.quote(100, WBNB, USDT, testToken)
+			},
+			change: func(n *routeNode) {
+				pair := n.pairs[newV2PairKey(WBNB, testToken)]
+				n.reserves[pair] = [2]*big.Int{ether(1000), ether(900)}
+				n.quote(200, WBNB, USDT, testToken)
+			},
+			want:      []common.Address{WBNB, testToken},
+			wantAfter: []common.Address{WBNB, USDT, testToken},
+		},
+		{
+			name: "cached route that stops quoting is replaced",
+			setup: func(n *routeNode) {
+				n.addPair(WBNB, testToken)
+				n.addPair(WBNB, USDT)
+				n.addPair(USDT, testToken)
+				n.quote(150, WBNB, testToken)
+				n.quote(100, WBNB, USDT, testToken)
+			},
+			change: func(n *routeNode) {
+				delete(n.quotes, formatPath([]common.Address{WBNB, testToken}))
+			},
+			want:      []common.Address{WBNB, testToken},
+			wantAfter: []common.Address{WBNB, USDT, testToken},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newRouteNode()
+			tt.setup(node)
+			finder := NewRouteFinder(newTestClient(t, node), testRouter, testFactory, nil, 5)
+			ctx := context.Background()
+
+			path, amounts, err := finder.Best(ctx, testToken, WBNB, testToken, ether(1), tt.via)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Best() = %s, want an error", formatPath(path))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(path, tt.want) {
+				t.Fatalf("Best() = %s, want %s", formatPath(path), formatPath(tt.want))
+			}
+			if want := node.quotes[formatPath(path)]; amounts[len(amounts)-1].Cmp(want) != 0 {
+				t.Errorf("quoted %s, want %s", amounts[len(amounts)-1], want)
+			}
+
+			if tt.change == nil {
+				return
+			}
+			node.mu.Lock()
+			tt.change(node)
+			node.mu.Unlock()
+
+			path, _, err = finder.Best(ctx, testToken, WBNB, testToken, ether(1), tt.via)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(path, tt.wantAfter) {
+				t.Errorf("after the change Best() = %s, want %s", formatPath(path), formatPath(tt.wantAfter))
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxManager is the transaction lifecycle side of a swapper: every hash it
+// returns can be waited on, sped up or cancelled.
+type TxManager interface {
+	WaitForTx(ctx context.Context, txHash string) (*TxResult, error)
+	OnTxDone(txHash string, cb func(TxResult)) error
+	SpeedUp(txHash string) (string, error)
+	CancelTx(txHash string) (string, error)
+	Escalate(ctx context.Context, txHash string, everyBlocks uint64)
+}
+
+// Swapper is one wallet trading on one venue. QuoteBuy returns the tokens a
+// buy would receive before tax. Prices are quoted in wei of
+// BNB (GetTokenPrice) or in USDT base units (GetTokenPriceInUSDT).
+// Amounts in and out are always BNB; SetTokenQuote routes a token paired
+// against another asset through it. Prices are before the token's own tax;
+// GetTokenTax returns the tax set with SetTokenTax, or the default.
+// ApproveToken returns "" when the allowance already covers amount, or the
+// hash of the approval that will; sells must wait for it to be mined.
+// BackrunBuy buys a token whose pool a pending trigger transaction is about
+// to create, pricing the buy from the expected pool; venues the launch does
+// not list on return ErrBackrunUnsupported.
+type Swapper interface {
+	TxManager
+	GetAddress() common.Address
+	QuoteBuy(tokenAddress common.Address, amountBNB *big.Int) (*big.Int, error)
+	BuyToken(tokenAddress common.Address, amountBNB *big.Int) (string, error)
+	BackrunBuy(trigger *types.Transaction, tokenAddress common.Address, amountBNB *big.Int, pool LaunchPool) (string, error)
+	SellToken(tokenAddress common.Address, amount *big.Int) (string, error)
+	ApproveToken(tokenAddress common.Address, amount *big.Int) (string, error)
+	GetTokenBalance(tokenAddress common.Address) (*big.Int, error)
+	GetTokenPrice(tokenAddress common.Address, amount *big.Int) (*big.Int, error)
+	GetTokenPriceInUSDT(tokenAddress common.Address, amount *big.Int) (*big.Int, error)
+	SetTokenQuote(tokenAddress, quote common.Address)
+	SetTokenTax(tokenAddress common.Address, tax TokenTax)
+	GetTokenTax(tokenAddress common.Address) TokenTax
+}
+
+var (
+	_ Swapper = (*PancakeSwapper)(nil)
+	_ Swapper = (*PancakeV3Swapper)(nil)
+	_ Swapper = (*VenueSwapper)(nil)
+	_ Swapper = (*CurveSwapper)(nil)
+)
+
+type Venue struct {
+	Name    string
+	Swapper Swapper
+}
+
+// VenueSwapper buys wherever the quote is best and remembers that venue per
+// token, so prices, approvals and sells for the position go to the same pool.
+// All venues must belong to the same wallet; transaction management is
+// delegated to the first one since they share the nonce manager and tracker.
+type VenueSwapper struct {
+	venues []Venue
+	chosen map[common.Address]int
+	mu     sync.RWMutex
+}
+
+func NewVenueSwapper(venues ...Venue) (*VenueSwapper, error) {
+	if len(venues) == 0 {
+		return nil, fmt.Errorf("no venues configured")
+	}
+	for _, v := range venues[1:] {
+		if v.Swapper.GetAddress() != venues[0].Swapper.GetAddress() {
+			return nil, fmt.Errorf("venue %s uses wallet %s, expected %s", v.Name, v.Swapper.GetAddress().Hex(), venues[0].Swapper.GetAddress().Hex())
+		}
+	}
+	return &VenueSwapper{
+		venues: venues,
+		chosen: make(map[common.Address]int),
+	}, nil
+}
+
+func (v *VenueSwapper) venueFor(tokenAddress common.Address) Venue {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if idx, ok := v.chosen[tokenAddress]; ok {
+		return v.venues[idx]
+	}
+	return v.venues[0]
+}
+
+func (v *VenueSwapper) bestBuy(tokenAddress common.Address, amountBNB *big.Int) (int, *big.Int, error) {
+	quotes := make([]*big.Int, len(v.venues))
+	errs := make([]error, len(v.venues))
+	var wg sync.WaitGroup
+	for i, venue := range v.venues {
+		wg.Add(1)
+		go func(idx int, venue Venue) {
+			defer wg.Done()
+			quotes[idx], errs[idx] = venue.Swapper.QuoteBuy(tokenAddress, amountBNB)
+		}(i, venue)
+	}
+	wg.Wait()
+
+	best := -1
+	for i, quote := range quotes {
+		if errs[i] != nil || quote == nil || quote.Sign() <= 0 {
+			continue
+		}
+		if best < 0 || quote.Cmp(quotes[best]) > 0 {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, nil, fmt.Errorf("no venue can quote %s: %w", tokenAddress.Hex(), errors.Join(errs...))
+	}
+	return best, quotes[best], nil
+}
+
+func (v *VenueSwapper) QuoteBuy(tokenAddress common.Address, amountBNB *big.Int) (*big.Int, error) {
+	_, quote, err := v.bestBuy(tokenAddress, amountBNB)
+	return quote, err
+}
+
+func (v *VenueSwapper) BuyToken(tokenAddress common.Address, amountBNB *big.Int) (string, error) {
+	idx, quote, err := v.bestBuy(tokenAddress, amountBNB)
+	if err != nil {
+		return "", err
+	}
+	venue := v.venues[idx]
+	log.Printf("[%s] Buying %s on %s (quote: %s tokens)", venue.Swapper.GetAddress().Hex(), tokenAddress.Hex(), venue.Name, quote.String())
+
+	v.mu.Lock()
+	v.chosen[tokenAddress] = idx
+	v.mu.Unlock()
+
+	return venue.Swapper.BuyToken(tokenAddress, amountBNB)
+}
+
+// BackrunBuy uses the first venue that can buy ahead of the pool, since
+// there is nothing to compare quotes on yet.
+func (v *VenueSwapper) BackrunBuy(trigger *types.Transaction, tokenAddress common.Address, amountBNB *big.Int, pool LaunchPool) (string, error) {
+	for idx, venue := range v.venues {
+		txHash, err := venue.Swapper.BackrunBuy(trigger, tokenAddress, amountBNB, pool)
+		if errors.Is(err, ErrBackrunUnsupported) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		log.Printf("[%s] Buying %s on %s behind trigger %s", venue.Swapper.GetAddress().Hex(), tokenAddress.Hex(), venue.Name, trigger.Hash().Hex())
+
+		v.mu.Lock()
+		v.chosen[tokenAddress] = idx
+		v.mu.Unlock()
+		return txHash, nil
+	}
+	return "", ErrBackrunUnsupported
+}
+
+func (v *VenueSwapper) SellToken(tokenAddress common.Address, amount *big.Int) (string, error) {
+	return v.venueFor(tokenAddress).Swapper.SellToken(tokenAddress, amount)
+}
+
+func (v *VenueSwapper) ApproveToken(tokenAddress common.Address, amount *big.Int) (string, error) {
+	return v.venueFor(tokenAddress).Swapper.ApproveToken(tokenAddress, amount)
+}
+
+func (v *VenueSwapper) GetTokenPrice(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+	return v.venueFor(tokenAddress).Swapper.GetTokenPrice(tokenAddress, amount)
+}
+
+func (v *VenueSwapper) GetTokenPriceInUSDT(tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+	return v.venueFor(tokenAddress).Swapper.GetTokenPriceInUSDT(tokenAddress, amount)
+}
+
+func (v *VenueSwapper) SetTokenQuote(tokenAddress, quote common.Address) {
+	for _, venue := range v.venues {
+		venue.Swapper.SetTokenQuote(tokenAddress, quote)
+	}
+}
+
+func (v *VenueSwapper) SetTokenTax(tokenAddress common.Address, tax TokenTax) {
+	for _, venue := range v.venues {
+		venue.Swapper.SetTokenTax(tokenAddress, tax)
+	}
+}
+
+func (v *VenueSwapper) GetTokenTax(tokenAddress common.Address) TokenTax {
+	return v.venueFor(tokenAddress).Swapper.GetTokenTax(tokenAddress)
+}
+
+func (v *VenueSwapper) GetAddress() common.Address {
+	return v.venues[0].Swapper.GetAddress()
+}
+
+func (v *VenueSwapper) GetTokenBalance(tokenAddress common.Address) (*big.Int, error) {
+	return v.venues[0].Swapper.GetTokenBalance(tokenAddress)
+}
+
+func (v *VenueSwapper) WaitForTx(ctx context.Context, txHash string) (*TxResult, error) {
+	return v.venues[0].Swapper.WaitForTx(ctx, txHash)
+}
+
+func (v *VenueSwapper) OnTxDone(txHash string, cb func(TxResult)) error {
+	return v.venues[0].Swapper.OnTxDone(txHash, cb)
+}
+
+func (v *VenueSwapper) SpeedUp(txHash string) (string, error) {
+	return v.venues[0].Swapper.SpeedUp(txHash)
+}
+
+func (v *VenueSwapper) CancelTx(txHash string) (string, error) {
+	return v.venues[0].Swapper.CancelTx(txHash)
+}
+
+func (v *VenueSwapper) Escalate(ctx context.Context, txHash string, everyBlocks uint64) {
+	v.venues[0].Swapper.Escalate(ctx, txHash, everyBlocks)
+}
@@ -0,0 +1,236 @@
+package contracts
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// venueStub is a Swapper that answers quotes and records which trades it was
+// asked to make. Methods the tests do not use are left to the nil interface.
+type venueStub struct {
+	Swapper
+	name       string
+	wallet     common.Address
+	quote      *big.Int
+	quoteErr   error
+	backrunErr error
+	trades     []string
+}
+
+func (s *venueStub) GetAddress() common.Address {
+	return s.wallet
+}
+
+func (s *venueStub) QuoteBuy(tokenAddress common.Address, amountBNB *big.Int) (*big.Int, error) {
+	return s.quote, s.quoteErr
+}
+
+func (s *venueStub) BuyToken(tokenAddress common.Address, amountBNB *big.Int) (string, error) {
+	s.trades = append(s.trades, "buy")
+	return s.name + "-buy", nil
+}
+
+func (s *venueStub) BackrunBuy(trigger *types.Transaction, tokenAddress common.Address, amountBNB *big.Int, pool LaunchPool) (string, error) {
+	if s.backrunErr != nil {
+		return "", s.backrunErr
+	}
+	s.trades = append(s.trades, "backrun")
+	return s.name + "-backrun", nil
+}
+
+func (s *venueStub) SellToken(tokenAddress common.Address, amount *big.Int) (string, error) {
+	s.trades = append(s.trades, "sell")
+	return s.name + "-sell", nil
+}
+
+func newVenues(t *testing.T, stubs ...*venueStub) *VenueSwapper {
+	t.Helper()
+	var venues []Venue
+	for _, s := range stubs {
+		venues = append(venues, Venue{Name: s.name, Swapper: s})
+	}
+	v, err := NewVenueSwapper(venues...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestNewVenueSwapper(t *testing.T) {
+	if _, err := NewVenueSwapper(); err == nil {
+		t.Error("no venues: expected error")
+	}
+	_, err := NewVenueSwapper(
+		Venue{Name: "v2", Swapper: &venueStub{wallet: testWallet}},
+		Venue{Name: "v3", Swapper: &venueStub{wallet: testToken}},
+	)
+	if err == nil {
+		t.Error("venues on different wallets: expected error")
+	}
+}
+
+func TestVenueSwapperBuyToken(t *testing.T) {
+	failed := errors.New("no pool")
+
+	tests := []struct {
+		name      string
+		v2, v3    *venueStub
+		wantHash  string
+		wantErr   bool
+		wantSell  string
+		wantQuote int64
+	}{
+		{
+			name:      "better quote wins",
+			v2:        &venueStub{quote: big.NewInt(100)},
+			v3:        &venueStub{quote: big.NewInt(120)},
+			wantHash:  "v3-buy",
+			wantSell:  "v3-sell",
+			wantQuote: 120,
+		},
+		{
+			name:      "tie keeps the first venue",
+			v2:        &venueStub{quote: big.NewInt(100)},
+			v3:        &venueStub{quote: big.NewInt(100)},
+			wantHash:  "v2-buy",
+			wantSell:  "v2-sell",
+			wantQuote: 100,
+		},
+		{
+			name:      "failed quote is skipped",
+			v2:        &venueStub{quoteErr: failed},
+			v3:        &venueStub{quote: big.NewInt(1)},
+			wantHash:  "v3-buy",
+			wantSell:  "v3-sell",
+			wantQuote: 1,
+		},
+		{
+			name:      "zero quote is skipped",
+			v2:        &venueStub{quote: big.NewInt(50)},
+			v3:        &venueStub{quote: new(big.Int)},
+			wantHash:  "v2-buy",
+			wantSell:  "v2-sell",
+			wantQuote: 50,
+		},
+		{
+			name:    "no venue can quote",
+			v2:      &venueStub{quoteErr: failed},
+			v3:      &venueStub{quoteErr: failed},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.v2.name, tt.v2.wallet = "v2", testWallet
+			tt.v3.name, tt.v3.wallet = "v3", testWallet
+			v := newVenues(t, tt.v2, tt.v3)
+
+			quote, err := v.QuoteBuy(testToken, big.NewInt(1))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("QuoteBuy: expected error")
+				}
+				if _, err := v.BuyToken(testToken, big.NewInt(1)); err == nil {
+					t.Fatal("BuyToken: expected error")
+				}
+				if len(tt.v2.trades)+len(tt.v3.trades) != 0 {
+					t.Errorf("trades sent without a quote: v2 %v, v3 %v", tt.v2.trades, tt.v3.trades)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if quote.Int64() != tt.wantQuote {
+				t.Errorf("QuoteBuy = %s, want %d", quote, tt.wantQuote)
+			}
+
+			hash, err := v.BuyToken(testToken, big.NewInt(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hash != tt.wantHash {
+				t.Errorf("BuyToken went to %s, want %s", hash, tt.wantHash)
+			}
+			if hash, _ := v.SellToken(testToken, big.NewInt(1)); hash != tt.wantSell {
+				t.Errorf("SellToken went to %s, want %s", hash, tt.wantSell)
+			}
+			if hash, _ := v.SellToken(testQuote, big.NewInt(1)); hash != "v2-sell" {
+				t.Errorf("SellToken of an unbought token went to %s, want the first venue", hash)
+			}
+		})
+	}
+}
+
+func TestVenueSwapperBackrunBuy(t *testing.T) {
+	failed := errors.New("send failed")
+	trigger := types.NewTx(&types.LegacyTx{})
+
+	tests := []struct {
+		name     string
+		v2, v3   *venueStub
+		wantHash string
+		wantErr  error
+		wantSell string
+	}{
+		{
+			name:     "first venue that supports it",
+			v2:       &venueStub{},
+			v3:       &venueStub{},
+			wantHash: "v2-backrun",
+			wantSell: "v2-sell",
+		},
+		{
+			name:     "unsupported venue falls through",
+			v2:       &venueStub{backrunErr: ErrBackrunUnsupported},
+			v3:       &venueStub{},
+			wantHash: "v3-backrun",
+			wantSell: "v3-sell",
+		},
+		{
+			name:    "no venue supports it",
+			v2:      &venueStub{backrunErr: ErrBackrunUnsupported},
+			v3:      &venueStub{backrunErr: ErrBackrunUnsupported},
+			wantErr: ErrBackrunUnsupported,
+		},
+		{
+			name:    "other errors stop the search",
+			v2:      &venueStub{backrunErr: failed},
+			v3:      &venueStub{},
+			wantErr: failed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.v2.name, tt.v2.wallet = "v2", testWallet
+			tt.v3.name, tt.v3.wallet = "v3", testWallet
+			v := newVenues(t, tt.v2, tt.v3)
+
+			hash, err := v.BackrunBuy(trigger, testToken, big.NewInt(1), LaunchPool{})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if len(tt.v3.trades) != 0 {
+					t.Errorf("later venue traded after an error: %v", tt.v3.trades)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hash != tt.wantHash {
+				t.Errorf("BackrunBuy went to %s, want %s", hash, tt.wantHash)
+			}
+			if hash, _ := v.SellToken(testToken, big.NewInt(1)); hash != tt.wantSell {
+				t.Errorf("SellToken went to %s, want %s", hash, tt.wantSell)
+			}
+		})
+	}
+}
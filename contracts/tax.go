@@ -0,0 +1,187 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// FlapTaxTokenABI covers the tax getters of Flap tax tokens. Rates are in
+// basis points. Older tokens only have taxRate, applied to both sides; newer
+// ones split it into buyTaxRate and sellTaxRate. The tax is paid to the
+// taxProcessor, which forwards the marketing share to marketAddress.
+const FlapTaxTokenABI = `[{"inputs":[],"name":"taxRate","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"buyTaxRate","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"sellTaxRate","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"taxProcessor","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"marketAddress","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"}]`
+
+var flapTaxTokenABI = mustParseABI(FlapTaxTokenABI)
+
+// ErrNoTaxGetters means the token answers none of the tax getters, so it is
+// not a Flap tax token and its tax is unknown rather than zero.
+var ErrNoTaxGetters = errors.New("token has no tax getters")
+
+// TaxInfo is a token's on-chain tax.
+type TaxInfo struct {
+	Token      common.Address
+	BuyBps     int64
+	SellBps    int64
+	Recipients []common.Address
+}
+
+func (t *TaxInfo) Tax() TokenTax {
+	return TokenTax{BuyBps: t.BuyBps, SellBps: t.SellBps}
+}
+
+func (t *TaxInfo) String() string {
+	recipients := make([]string, len(t.Recipients))
+	for i, r := range t.Recipients {
+		recipients[i] = r.Hex()
+	}
+	return fmt.Sprintf("buy tax %.2f%%, sell tax %.2f%%, recipients [%s]",
+		bpsToPercent(t.BuyBps), bpsToPercent(t.SellBps), strings.Join(recipients, ", "))
+}
+
+// AfterTax is what is left of amount once taxBps is taken.
+func AfterTax(amount *big.Int, taxBps int64) *big.Int {
+	keepBps := bpsDenominator - taxBps
+	if keepBps < 0 {
+		keepBps = 0
+	}
+	result := new(big.Int).Mul(amount, big.NewInt(keepBps))
+	return result.Div(result, big.NewInt(bpsDenominator))
+}
+
+// TaxReader reads and caches token taxes. A token's tax only changes with a
+// FlapTokenTaxSet event, which Update applies to the cache.
+type TaxReader struct {
+	client *ethclient.Client
+	taxes  map[common.Address]*TaxInfo
+	mu     sync.RWMutex
+}
+
+func NewTaxReader(client *ethclient.Client) *TaxReader {
+	return &TaxReader{
+		client: client,
+		taxes:  make(map[common.Address]*TaxInfo),
+	}
+}
+
+func (r *TaxReader) Get(ctx context.Context, tokenAddress common.Address) (*TaxInfo, error) {
+	r.mu.RLock()
+	info, ok := r.taxes[tokenAddress]
+	r.mu.RUnlock()
+	if ok {
+		return info, nil
+	}
+
+	info, err := ReadTokenTax(ctx, r.client, tokenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.taxes[tokenAddress] = info
+	r.mu.Unlock()
+	return info, nil
+}
+
+// Set caches a tax read elsewhere, such as the one GetTokenInfo reads for
+// tax tokens, so later lookups of the token skip the getters.
+func (r *TaxReader) Set(info *TaxInfo) {
+	r.mu.Lock()
+	r.taxes[info.Token] = info
+	r.mu.Unlock()
+}
+
+// Update applies a FlapTokenTaxSet rate, in basis points, to both sides,
+// keeping the known recipients, and returns the new record.
+func (r *TaxReader) Update(tokenAddress common.Address, tax *big.Int) *TaxInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info := &TaxInfo{Token: tokenAddress, BuyBps: rateBps(tax), SellBps: rateBps(tax)}
+	if old, ok := r.taxes[tokenAddress]; ok {
+		info.Recipients = old.Recipients
+	}
+	r.taxes[tokenAddress] = info
+	return info
+}
+
+// ReadTokenTax reads a token's tax from its own getters. Getters a token
+// does not have are skipped; if it has none, ErrNoTaxGetters is returned.
+func ReadTokenTax(ctx context.Context, client *ethclient.Client, tokenAddress common.Address) (*TaxInfo, error) {
+	info := &TaxInfo{Token: tokenAddress}
+
+	words := make(map[string]interface{})
+	for _, method := range []string{"taxRate", "buyTaxRate", "sellTaxRate", "taxProcessor", "marketAddress"} {
+		output, ok, err := readTaxWord(ctx, client, tokenAddress, method)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			words[method] = output
+		}
+	}
+
+	rate, hasRate := words["taxRate"]
+	buy, hasBuy := words["buyTaxRate"]
+	sell, hasSell := words["sellTaxRate"]
+	if !hasRate && !hasBuy && !hasSell {
+		return nil, fmt.Errorf("%w: %s", ErrNoTaxGetters, tokenAddress.Hex())
+	}
+	if hasRate {
+		info.BuyBps, info.SellBps = rateBps(rate), rateBps(rate)
+	}
+	if hasBuy {
+		info.BuyBps = rateBps(buy)
+	}
+	if hasSell {
+		info.SellBps = rateBps(sell)
+	}
+
+	for _, method := range []string{"taxProcessor", "marketAddress"} {
+		if recipient, ok := words[method].(common.Address); ok && recipient != (common.Address{}) {
+			info.Recipients = append(info.Recipients, recipient)
+		}
+	}
+	return info, nil
+}
+
+// readTaxWord calls one getter and reports whether the token answered it.
+// A revert or an empty answer means the getter is missing; any other error
+// is returned so an RPC failure is not mistaken for an untaxed token.
+func readTaxWord(ctx context.Context, client *ethclient.Client, tokenAddress common.Address, method string) (interface{}, bool, error) {
+	data, err := flapTaxTokenABI.Pack(method)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to pack %s: %w", method, err)
+	}
+	result, err := client.CallContract(ctx, ethereum.CallMsg{To: &tokenAddress, Data: data}, nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "revert") {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	outputs, err := flapTaxTokenABI.Unpack(method, result)
+	if err != nil {
+		return nil, false, nil
+	}
+	return outputs[0], true, nil
+}
+
+// rateBps clamps a getter's rate to a valid basis point value.
+func rateBps(output interface{}) int64 {
+	rate, ok := output.(*big.Int)
+	if !ok || rate.Sign() < 0 {
+		return 0
+	}
+	if rate.Cmp(big.NewInt(bpsDenominator)) > 0 {
+		return bpsDenominator
+	}
+	return rate.Int64()
+}
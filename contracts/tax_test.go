@@ -0,0 +1,116 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// taxNode answers the tax getters a token has. A getter it lacks reverts,
+// one mapped to nil answers with no data, and failRPC fails every call the
+// way an unreachable node would.
+type taxNode struct {
+	getters map[string]interface{}
+	failRPC bool
+}
+
+func (n *taxNode) Call(args callArgs, block string) (hexutil.Bytes, error) {
+	if n.failRPC {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	method, err := flapTaxTokenABI.MethodById(args.Input)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := n.getters[method.Name]
+	if !ok {
+		return nil, errors.New("execution reverted")
+	}
+	if value == nil {
+		return hexutil.Bytes{}, nil
+	}
+	return method.Outputs.Pack(value)
+}
+
+func TestReadTokenTax(t *testing.T) {
+	processor := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	market := common.HexToAddress("0x00000000000000000000000000000000000000e2")
+
+	tests := []struct {
+		name    string
+		node    *taxNode
+		want    *TaxInfo
+		wantErr error
+		anyErr  bool
+	}{
+		{
+			name: "single rate applies to both sides",
+			node: &taxNode{getters: map[string]interface{}{"taxRate": big.NewInt(300), "taxProcessor": processor}},
+			want: &TaxInfo{Token: testToken, BuyBps: 300, SellBps: 300, Recipients: []common.Address{processor}},
+		},
+		{
+			name: "split rates override the single rate",
+			node: &taxNode{getters: map[string]interface{}{
+				"taxRate":       big.NewInt(300),
+				"buyTaxRate":    big.NewInt(100),
+				"sellTaxRate":   big.NewInt(500),
+				"taxProcessor":  processor,
+				"marketAddress": market,
+			}},
+			want: &TaxInfo{Token: testToken, BuyBps: 100, SellBps: 500, Recipients: []common.Address{processor, market}},
+		},
+		{
+			name: "rate past 100% is clamped",
+			node: &taxNode{getters: map[string]interface{}{"sellTaxRate": big.NewInt(20_000)}},
+			want: &TaxInfo{Token: testToken, SellBps: bpsDenominator},
+		},
+		{
+			name: "zero recipient is left out",
+			node: &taxNode{getters: map[string]interface{}{"taxRate": big.NewInt(0), "marketAddress": common.Address{}}},
+			want: &TaxInfo{Token: testToken},
+		},
+		{
+			name: "empty answer counts as a missing getter",
+			node: &taxNode{getters: map[string]interface{}{"taxRate": nil, "buyTaxRate": big.NewInt(200)}},
+			want: &TaxInfo{Token: testToken, BuyBps: 200},
+		},
+		{
+			name:    "every getter reverts",
+			node:    &taxNode{},
+			wantErr: ErrNoTaxGetters,
+		},
+		{
+			name:   "RPC failure is not a missing getter",
+			node:   &taxNode{failRPC: true},
+			anyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ReadTokenTax(context.Background(), newTestClient(t, tt.node), testToken)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.anyErr:
+				if err == nil || errors.Is(err, ErrNoTaxGetters) {
+					t.Fatalf("err = %v, want an RPC error", err)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(info, tt.want) {
+				t.Errorf("ReadTokenTax() = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
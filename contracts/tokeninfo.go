@@ -0,0 +1,147 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var TokenManager = common.HexToAddress("0x5c952063c7fc8610FFDB798152D69F0B9550762b")
+
+const TokenTypeTax = 5
+
+// TokenInfoABI is the _tokenInfos getter of the deployed TokenManager,
+// returning the whole TokenInfo struct.
+const TokenInfoABI = `[{"inputs":[{"internalType":"address","name":"","type":"address"}],"name":"_tokenInfos","outputs":[{"internalType":"address","name":"base","type":"address"},{"internalType":"address","name":"quote","type":"address"},{"internalType":"uint256","name":"template","type":"uint256"},{"internalType":"uint256","name":"totalSupply","type":"uint256"},{"internalType":"uint256","name":"maxOffers","type":"uint256"},{"internalType":"uint256","name":"maxRaising","type":"uint256"},{"internalType":"uint256","name":"launchTime","type":"uint256"},{"internalType":"uint256","name":"offers","type":"uint256"},{"internalType":"uint256","name":"funds","type":"uint256"},{"internalType":"uint256","name":"lastPrice","type":"uint256"},{"internalType":"uint256","name":"K","type":"uint256"},{"internalType":"uint256","name":"T","type":"uint256"},{"internalType":"uint256","name":"status","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+var tokenInfoABI = mustParseABI(TokenInfoABI)
+
+// _tokenInfos returns the TokenInfo struct as thirteen static words, in the
+// order of TokenInfoABI, which is the getter of the TokenManager deployed at
+// TokenManager. Implementations that append fields return more words; only
+// the first thirteen are read.
+const tokenInfoWords = 13
+
+// Template bit layout, least significant bit first:
+//
+//	bits  0-9   template ID, the index of the token implementation the
+//	            manager deployed; opaque to us
+//	bits 10-15  creator type, which token flavour the creator picked
+//	            (TokenTypeTax == 5 is the taxed template)
+//	bits 16+    not interpreted; kept in Template for logging
+const (
+	templateIDBits     = 10
+	templateIDMask     = 1<<templateIDBits - 1
+	creatorTypeShift   = templateIDBits
+	creatorTypeBits    = 6
+	creatorTypeMask    = 1<<creatorTypeBits - 1
+	templateUpperShift = creatorTypeShift + creatorTypeBits
+)
+
+// TokenInfo is the TokenManager's record for a launched token. Offers and
+// Funds are what the curve has sold and raised so far, in token and quote
+// units, out of MaxOffers and MaxRaising; a zero Base means the manager does
+// not know the token. Each field is one _tokenInfos word, numbered below;
+// Tax is not part of the record and is read from the token itself.
+type TokenInfo struct {
+	Base        common.Address // word 0, the token
+	Quote       common.Address // word 1, zero for BNB
+	Template    *big.Int       // word 2, see the template bit layout
+	TotalSupply *big.Int       // word 3
+	MaxOffers   *big.Int       // word 4, tokens the curve sells before launch
+	MaxRaising  *big.Int       // word 5, quote the curve raises before launch
+	LaunchTime  *big.Int       // word 6, unix seconds
+	Offers      *big.Int       // word 7
+	Funds       *big.Int       // word 8
+	LastPrice   *big.Int       // word 9
+	K           *big.Int       // word 10, curve constant
+	T           *big.Int       // word 11, curve constant
+	Status      *big.Int       // word 12, non-zero once launched to the DEX
+
+	// Tax is the token's own tax getters, read for tax tokens only. It is
+	// nil for other types and for tax tokens that declare no rate.
+	Tax *TaxInfo
+}
+
+func (i *TokenInfo) TemplateID() int64 {
+	return new(big.Int).And(i.Template, big.NewInt(templateIDMask)).Int64()
+}
+
+func (i *TokenInfo) CreatorType() int64 {
+	creatorType := new(big.Int).Rsh(i.Template, creatorTypeShift)
+	return creatorType.And(creatorType, big.NewInt(creatorTypeMask)).Int64()
+}
+
+// TemplateFlags returns the template bits above the creator type.
+func (i *TokenInfo) TemplateFlags() *big.Int {
+	return new(big.Int).Rsh(i.Template, templateUpperShift)
+}
+
+func (i *TokenInfo) Known() bool {
+	return i.Base != (common.Address{})
+}
+
+func (i *TokenInfo) IsTaxToken() bool {
+	return i.CreatorType() == TokenTypeTax
+}
+
+func (i *TokenInfo) String() string {
+	return fmt.Sprintf("base %s quote %s template %d/type %d, supply %s, offers %s/%s, funds %s/%s, status %s",
+		i.Base.Hex(), i.Quote.Hex(), i.TemplateID(), i.CreatorType(), i.TotalSupply.String(),
+		i.Offers.String(), i.MaxOffers.String(), i.Funds.String(), i.MaxRaising.String(), i.Status.String())
+}
+
+// GetTokenInfo reads a token's _tokenInfos record and, for tax tokens, its
+// tax. A record whose Base is neither zero nor the token means the getter's
+// layout is not the one TokenInfoABI describes, and is an error rather than a
+// misread creator type.
+func GetTokenInfo(ctx context.Context, client *ethclient.Client, tokenAddress common.Address) (*TokenInfo, error) {
+	data, err := tokenInfoABI.Pack("_tokenInfos", tokenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack _tokenInfos: %w", err)
+	}
+
+	result, err := client.CallContract(ctx, ethereum.CallMsg{
+		To:   &TokenManager,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call _tokenInfos: %w", err)
+	}
+
+	info, err := DecodeTokenInfo(result)
+	if err != nil {
+		return nil, err
+	}
+	if info.Known() && info.Base != tokenAddress {
+		return nil, fmt.Errorf("_tokenInfos of %s returned base %s, layout mismatch", tokenAddress.Hex(), info.Base.Hex())
+	}
+
+	if info.IsTaxToken() {
+		tax, err := ReadTokenTax(ctx, client, tokenAddress)
+		if err != nil && !errors.Is(err, ErrNoTaxGetters) {
+			return nil, err
+		}
+		info.Tax = tax
+	}
+	return info, nil
+}
+
+// DecodeTokenInfo decodes a raw _tokenInfos return value. Words past the
+// thirteenth are ignored.
+func DecodeTokenInfo(data []byte) (*TokenInfo, error) {
+	if len(data) < tokenInfoWords*32 {
+		return nil, fmt.Errorf("invalid _tokenInfos length: %d, want at least %d", len(data), tokenInfoWords*32)
+	}
+
+	info := new(TokenInfo)
+	if err := tokenInfoABI.UnpackIntoInterface(info, "_tokenInfos", data[:tokenInfoWords*32]); err != nil {
+		return nil, fmt.Errorf("failed to unpack _tokenInfos: %w", err)
+	}
+	return info, nil
+}
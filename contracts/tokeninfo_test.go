@@ -0,0 +1,296 @@
+package contracts
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// baselineCreatorType is how the bot classified tokens before TokenInfo:
+// template >> 10 & 0x3F. It is applied to word 2 of the raw response, so the
+// decoder is checked against the raw layout rather than against itself.
+func baselineCreatorType(data []byte) int64 {
+	template := new(big.Int).SetBytes(data[2*32 : 3*32])
+	return template.Rsh(template, 10).And(template, big.NewInt(0x3F)).Int64()
+}
+
+// tokenInfoResponse joins 32-byte words into a _tokenInfos return value.
+func tokenInfoResponse(t *testing.T, words ...string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(strings.Join(words, ""))
+	if err != nil {
+		t.Fatalf("bad fixture: %v", err)
+	}
+	return data
+}
+
+func TestDecodeTokenInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		words       []string
+		wantErr     bool
+		known       bool
+		base        common.Address
+		quote       common.Address
+		templateID  int64
+		creatorType int64
+		flags       int64
+		isTax       bool
+		funds       string
+		status      int64
+	}{
+		{
+			name: "tax token on BNB curve",
+			words: []string{
+				"0000000000000000000000004a1f5b3c2d0e9f8a7b6c5d4e3f2a1b0c9d8e7f60", // base
+				"0000000000000000000000000000000000000000000000000000000000000000", // quote
+				"0000000000000000000000000000000000000000000000000000000000001403", // template
+				"0000000000000000000000000000000000000000033b2e3c9fd0803ce8000000", // totalSupply
+				"00000000000000000000000000000000000000000295be96e640669720000000", // maxOffers
+				"0000000000000000000000000000000000000000000000014d1120d7b1600000", // maxRaising
+				"0000000000000000000000000000000000000000000000000000000066669980", // launchTime
+				"000000000000000000000000000000000000000000a56fa5b99019a5c8000000", // offers
+				"00000000000000000000000000000000000000000000000053444835ec580000", // funds
+				"00000000000000000000000000000000000000000000000000000006fc23ac00", // lastPrice
+				"000000000000000000000000000000000039c7245855e8868296afb800000000", // K
+				"00000000000000000000000000000000000000000cecb8f27f4200f3a0000000", // T
+				"0000000000000000000000000000000000000000000000000000000000000000", // status
+			},
+			known:       true,
+			base:        common.HexToAddress("0x4a1f5b3c2d0e9f8a7b6c5d4e3f2a1b0c9d8e7f60"),
+			templateID:  3,
+			creatorType: TokenTypeTax,
+			isTax:       true,
+			funds:       "6000000000000000000",
+		},
+		{
+			name: "plain token on USDT curve, launched",
+			words: []string{
+				"0000000000000000000000001111111111111111111111111111111111111111", // base
+				"00000000000000000000000055d398326f99059ff775485246999027b3197955", // quote
+				"0000000000000000000000000000000000000000000000000000000000000001", // template
+				"0000000000000000000000000000000000000000033b2e3c9fd0803ce8000000", // totalSupply
+				"00000000000000000000000000000000000000000295be96e640669720000000", // maxOffers
+				"00000000000000000000000000000000000000000000028a857425466f800000", // maxRaising
+				"0000000000000000000000000000000000000000000000000000000066669b74", // launchTime
+				"00000000000000000000000000000000000000000295be96e640669720000000", // offers
+				"00000000000000000000000000000000000000000000028a857425466f800000", // funds
+				"0000000000000000000000000000000000000000000000000000000000e4e1c0", // lastPrice
+				"0000000000000000000000000000000000000000000000000000000000000001", // K
+				"0000000000000000000000000000000000000000000000000000000000000002", // T
+				"0000000000000000000000000000000000000000000000000000000000000001", // status
+			},
+			known:       true,
+			base:        common.HexToAddress("0x1111111111111111111111111111111111111111"),
+			quote:       USDT,
+			templateID:  1,
+			creatorType: 0,
+			funds:       "12000000000000000000000",
+			status:      1,
+		},
+		{
+			name: "flag bits above creator type",
+			words: []string{
+				"0000000000000000000000002222222222222222222222222222222222222222", // base
+				"0000000000000000000000000000000000000000000000000000000000000000", // quote
+				"0000000000000000000000000000000000000000000000000000000000010807", // template
+				"0000000000000000000000000000000000000000033b2e3c9fd0803ce8000000", // totalSupply
+				"00000000000000000000000000000000000000000295be96e640669720000000", // maxOffers
+				"0000000000000000000000000000000000000000000000014d1120d7b1600000", // maxRaising
+				"0000000000000000000000000000000000000000000000000000000066669d68", // launchTime
+				"0000000000000000000000000000000000000000000000000000000000000000", // offers
+				"0000000000000000000000000000000000000000000000000000000000000000", // funds
+				"0000000000000000000000000000000000000000000000000000000000000000", // lastPrice
+				"0000000000000000000000000000000000000000000000000000000000000000", // K
+				"0000000000000000000000000000000000000000000000000000000000000000", // T
+				"0000000000000000000000000000000000000000000000000000000000000000", // status
+			},
+			known:       true,
+			base:        common.HexToAddress("0x2222222222222222222222222222222222222222"),
+			templateID:  7,
+			creatorType: 2,
+			flags:       1,
+			funds:       "0",
+		},
+		{
+			name: "unknown token",
+			words: []string{
+				"0000000000000000000000000000000000000000000000000000000000000000", // base
+				"0000000000000000000000000000000000000000000000000000000000000000", // quote
+				"0000000000000000000000000000000000000000000000000000000000000000", // template
+				"0000000000000000000000000000000000000000000000000000000000000000", // totalSupply
+				"0000000000000000000000000000000000000000000000000000000000000000", // maxOffers
+				"0000000000000000000000000000000000000000000000000000000000000000", // maxRaising
+				"0000000000000000000000000000000000000000000000000000000000000000", // launchTime
+				"0000000000000000000000000000000000000000000000000000000000000000", // offers
+				"0000000000000000000000000000000000000000000000000000000000000000", // funds
+				"0000000000000000000000000000000000000000000000000000000000000000", // lastPrice
+				"0000000000000000000000000000000000000000000000000000000000000000", // K
+				"0000000000000000000000000000000000000000000000000000000000000000", // T
+				"0000000000000000000000000000000000000000000000000000000000000000", // status
+			},
+			funds: "0",
+		},
+		{
+			name: "extra trailing word ignored",
+			words: []string{
+				"0000000000000000000000004a1f5b3c2d0e9f8a7b6c5d4e3f2a1b0c9d8e7f60", // base
+				"0000000000000000000000000000000000000000000000000000000000000000", // quote
+				"0000000000000000000000000000000000000000000000000000000000001403", // template
+				"0000000000000000000000000000000000000000033b2e3c9fd0803ce8000000", // totalSupply
+				"00000000000000000000000000000000000000000295be96e640669720000000", // maxOffers
+				"0000000000000000000000000000000000000000000000014d1120d7b1600000", // maxRaising
+				"0000000000000000000000000000000000000000000000000000000066669980", // launchTime
+				"000000000000000000000000000000000000000000a56fa5b99019a5c8000000", // offers
+				"00000000000000000000000000000000000000000000000053444835ec580000", // funds
+				"00000000000000000000000000000000000000000000000000000006fc23ac00", // lastPrice
+				"000000000000000000000000000000000039c7245855e8868296afb800000000", // K
+				"00000000000000000000000000000000000000000cecb8f27f4200f3a0000000", // T
+				"0000000000000000000000000000000000000000000000000000000000000000", // status
+				"00000000000000000000000000000000000000000000000000000000deadbeef", // appended field
+			},
+			known:       true,
+			base:        common.HexToAddress("0x4a1f5b3c2d0e9f8a7b6c5d4e3f2a1b0c9d8e7f60"),
+			templateID:  3,
+			creatorType: TokenTypeTax,
+			isTax:       true,
+			funds:       "6000000000000000000",
+		},
+		{
+			name: "truncated response",
+			words: []string{
+				"0000000000000000000000004a1f5b3c2d0e9f8a7b6c5d4e3f2a1b0c9d8e7f60",
+				"0000000000000000000000000000000000000000000000000000000000000000",
+				"0000000000000000000000000000000000000000000000000000000000001403",
+				"0000000000000000000000000000000000000000033b2e3c9fd0803ce8000000",
+				"00000000000000000000000000000000000000000295be96e640669720000000",
+				"0000000000000000000000000000000000000000000000014d1120d7b1600000",
+				"0000000000000000000000000000000000000000000000000000000066669980",
+				"000000000000000000000000000000000000000000a56fa5b99019a5c8000000",
+				"00000000000000000000000000000000000000000000000053444835ec580000",
+				"00000000000000000000000000000000000000000000000000000006fc23ac00",
+				"000000000000000000000000000000000039c7245855e8868296afb800000000",
+				"00000000000000000000000000000000000000000cecb8f27f4200f3a0000000",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tokenInfoResponse(t, tt.words...)
+			info, err := DecodeTokenInfo(data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeTokenInfo: %v", err)
+			}
+
+			if info.Known() != tt.known {
+				t.Errorf("Known() = %v, want %v", info.Known(), tt.known)
+			}
+			if info.Base != tt.base {
+				t.Errorf("Base = %s, want %s", info.Base.Hex(), tt.base.Hex())
+			}
+			if info.Quote != tt.quote {
+				t.Errorf("Quote = %s, want %s", info.Quote.Hex(), tt.quote.Hex())
+			}
+			if got := info.TemplateID(); got != tt.templateID {
+				t.Errorf("TemplateID() = %d, want %d", got, tt.templateID)
+			}
+			if got := info.CreatorType(); got != tt.creatorType {
+				t.Errorf("CreatorType() = %d, want %d", got, tt.creatorType)
+			}
+			if got, want := info.CreatorType(), baselineCreatorType(data); got != want {
+				t.Errorf("CreatorType() = %d, baseline formula gives %d", got, want)
+			}
+			if got := info.TemplateFlags().Int64(); got != tt.flags {
+				t.Errorf("TemplateFlags() = %d, want %d", got, tt.flags)
+			}
+			if info.IsTaxToken() != tt.isTax {
+				t.Errorf("IsTaxToken() = %v, want %v", info.IsTaxToken(), tt.isTax)
+			}
+			if info.Funds.String() != tt.funds {
+				t.Errorf("Funds = %s, want %s", info.Funds.String(), tt.funds)
+			}
+			if info.Status.Cmp(big.NewInt(tt.status)) != 0 {
+				t.Errorf("Status = %s, want %d", info.Status.String(), tt.status)
+			}
+		})
+	}
+}
+
+// TestGetTokenInfoLive checks the decoder against real _tokenInfos responses.
+// It needs a BSC RPC endpoint in TOKENINFO_RPC_URL and known tokens in
+// TOKENINFO_TOKENS as address:creatorType pairs, comma separated, and logs
+// each raw response as fixture words.
+func TestGetTokenInfoLive(t *testing.T) {
+	url, list := os.Getenv("TOKENINFO_RPC_URL"), os.Getenv("TOKENINFO_TOKENS")
+	if url == "" || list == "" {
+		t.Skip("TOKENINFO_RPC_URL and TOKENINFO_TOKENS not set")
+	}
+	client, err := ethclient.Dial(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, entry := range strings.Split(list, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 2 || !common.IsHexAddress(parts[0]) {
+			t.Fatalf("bad TOKENINFO_TOKENS entry %q, want address:creatorType", entry)
+		}
+		token := common.HexToAddress(parts[0])
+		wantType, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			t.Fatalf("bad creator type in %q: %v", entry, err)
+		}
+
+		t.Run(token.Hex(), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			call, err := tokenInfoABI.Pack("_tokenInfos", token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := client.CallContract(ctx, ethereum.CallMsg{To: &TokenManager, Data: call}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i+32 <= len(data); i += 32 {
+				t.Logf("%q,", hex.EncodeToString(data[i:i+32]))
+			}
+
+			info, err := GetTokenInfo(ctx, client, token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Base != token {
+				t.Errorf("Base = %s, want the token", info.Base.Hex())
+			}
+			if got := info.CreatorType(); got != wantType {
+				t.Errorf("CreatorType() = %d, want %d", got, wantType)
+			}
+			if got, want := info.CreatorType(), baselineCreatorType(data); got != want {
+				t.Errorf("CreatorType() = %d, baseline formula gives %d", got, want)
+			}
+			if info.IsTaxToken() {
+				t.Logf("tax: %v", info.Tax)
+			}
+		})
+	}
+}
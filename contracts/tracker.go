@@ -0,0 +1,365 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	trackerPollInterval = time.Second
+	trackerRetention    = 10 * time.Minute
+)
+
+type TxStatus int
+
+const (
+	TxPending TxStatus = iota
+	TxMined
+	TxReverted
+	TxDropped
+)
+
+func (s TxStatus) String() string {
+	switch s {
+	case TxPending:
+		return "pending"
+	case TxMined:
+		return "mined"
+	case TxReverted:
+		return "reverted"
+	case TxDropped:
+		return "dropped"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+type TxResult struct {
+	Hash          common.Hash
+	Status        TxStatus
+	Receipt       *types.Receipt
+	Confirmations uint64
+	Replacements  int
+	Cancelled     bool
+}
+
+func (r TxResult) Success() bool {
+	return r.Status == TxMined
+}
+
+// trackedTx follows one nonce of one wallet. Speed-ups and cancels add hashes
+// to it; whichever of them is mined decides the result.
+type trackedTx struct {
+	hashes         []common.Hash
+	cancelHash     common.Hash
+	latest         *types.Transaction
+	from           common.Address
+	nonce          uint64
+	submitted      time.Time
+	submittedBlock uint64
+	finished       time.Time
+	result         TxResult
+	done           chan struct{}
+	callbacks      []func(TxResult)
+}
+
+type TxTracker struct {
+	client        *ethclient.Client
+	confirmations uint64
+	dropTimeout   time.Duration
+	txs           map[common.Hash]*trackedTx
+	head          uint64
+	mu            sync.Mutex
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+func NewTxTracker(client *ethclient.Client, confirmations uint64, dropTimeout time.Duration) *TxTracker {
+	if confirmations == 0 {
+		confirmations = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TxTracker{
+		client:        client,
+		confirmations: confirmations,
+		dropTimeout:   dropTimeout,
+		txs:           make(map[common.Hash]*trackedTx),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+func (t *TxTracker) Start() {
+	ticker := time.NewTicker(trackerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			t.poll()
+		}
+	}
+}
+
+func (t *TxTracker) Stop() {
+	t.cancel()
+}
+
+func (t *TxTracker) Track(tx *types.Transaction, from common.Address) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.txs[tx.Hash()]; ok {
+		return
+	}
+	t.txs[tx.Hash()] = &trackedTx{
+		hashes:         []common.Hash{tx.Hash()},
+		latest:         tx,
+		from:           from,
+		nonce:          tx.Nonce(),
+		submitted:      time.Now(),
+		submittedBlock: t.head,
+		result:         TxResult{Hash: tx.Hash(), Status: TxPending},
+		done:           make(chan struct{}),
+	}
+}
+
+// Replace registers a re-signed transaction with the same nonce as oldHash.
+// Waiters and callbacks on any hash of the group see the final outcome.
+func (t *TxTracker) Replace(oldHash common.Hash, tx *types.Transaction, cancel bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked, ok := t.txs[oldHash]
+	if !ok {
+		return fmt.Errorf("transaction %s is not tracked", oldHash.Hex())
+	}
+	if tx.Nonce() != tracked.nonce {
+		return fmt.Errorf("replacement nonce %d does not match %d", tx.Nonce(), tracked.nonce)
+	}
+
+	tracked.hashes = append(tracked.hashes, tx.Hash())
+	tracked.latest = tx
+	tracked.submittedBlock = t.head
+	tracked.result.Replacements++
+	if cancel {
+		tracked.cancelHash = tx.Hash()
+	}
+	t.txs[tx.Hash()] = tracked
+	return nil
+}
+
+func (t *TxTracker) Latest(hash common.Hash) (*types.Transaction, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked, ok := t.txs[hash]
+	if !ok {
+		return nil, false
+	}
+	return tracked.latest, true
+}
+
+// BlocksPending reports how many blocks have passed since the latest
+// transaction of the group was submitted.
+func (t *TxTracker) BlocksPending(hash common.Hash) (uint64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked, ok := t.txs[hash]
+	if !ok || t.head < tracked.submittedBlock {
+		return 0, ok
+	}
+	return t.head - tracked.submittedBlock, true
+}
+
+func (t *TxTracker) Done(hash common.Hash) bool {
+	t.mu.Lock()
+	tracked, ok := t.txs[hash]
+	t.mu.Unlock()
+	if !ok {
+		return true
+	}
+
+	select {
+	case <-tracked.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *TxTracker) Status(hash common.Hash) (TxResult, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked, ok := t.txs[hash]
+	if !ok {
+		return TxResult{}, false
+	}
+	return tracked.result, true
+}
+
+func (t *TxTracker) Wait(ctx context.Context, hash common.Hash) (*TxResult, error) {
+	t.mu.Lock()
+	tracked, ok := t.txs[hash]
+	t.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("transaction %s is not tracked", hash.Hex())
+	}
+
+	select {
+	case <-tracked.done:
+		t.mu.Lock()
+		result := tracked.result
+		t.mu.Unlock()
+		return &result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// OnDone runs cb on its own goroutine once the transaction reaches a final
+// state, so callbacks are free to take their own locks or send transactions.
+func (t *TxTracker) OnDone(hash common.Hash, cb func(TxResult)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked, ok := t.txs[hash]
+	if !ok {
+		return fmt.Errorf("transaction %s is not tracked", hash.Hex())
+	}
+
+	select {
+	case <-tracked.done:
+		go cb(tracked.result)
+	default:
+		tracked.callbacks = append(tracked.callbacks, cb)
+	}
+	return nil
+}
+
+func (t *TxTracker) poll() {
+	ctx, cancel := context.WithTimeout(t.ctx, 10*time.Second)
+	defer cancel()
+
+	head, err := t.client.BlockNumber(ctx)
+	if err != nil {
+		log.Printf("Tx tracker: failed to get block number: %v", err)
+		return
+	}
+
+	t.mu.Lock()
+	t.head = head
+	seen := make(map[*trackedTx]bool)
+	var pending []*trackedTx
+	for hash, tracked := range t.txs {
+		select {
+		case <-tracked.done:
+			if time.Since(tracked.finished) > trackerRetention {
+				delete(t.txs, hash)
+			}
+		default:
+			if !seen[tracked] {
+				seen[tracked] = true
+				pending = append(pending, tracked)
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	for _, tracked := range pending {
+		t.check(ctx, tracked, head)
+	}
+}
+
+func (t *TxTracker) check(ctx context.Context, tracked *trackedTx, head uint64) {
+	t.mu.Lock()
+	hashes := append([]common.Hash(nil), tracked.hashes...)
+	t.mu.Unlock()
+
+	for _, hash := range hashes {
+		receipt, err := t.client.TransactionReceipt(ctx, hash)
+		if err != nil {
+			continue
+		}
+
+		confirmations := uint64(0)
+		if mined := receipt.BlockNumber.Uint64(); head >= mined {
+			confirmations = head - mined + 1
+		}
+
+		status := TxMined
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			status = TxReverted
+		}
+
+		t.mu.Lock()
+		tracked.result.Hash = hash
+		tracked.result.Receipt = receipt
+		tracked.result.Confirmations = confirmations
+		tracked.result.Cancelled = hash == tracked.cancelHash
+		if tracked.result.Cancelled {
+			status = TxDropped
+		}
+		t.mu.Unlock()
+
+		if confirmations >= t.confirmations {
+			t.finish(tracked, status)
+		}
+		return
+	}
+
+	// No receipt for any hash: either still waiting, reorged out, or the
+	// nonce was taken by a different transaction and ours will never be mined.
+	t.mu.Lock()
+	tracked.result.Receipt = nil
+	tracked.result.Confirmations = 0
+	t.mu.Unlock()
+
+	nonce, err := t.client.NonceAt(ctx, tracked.from, nil)
+	if err == nil && nonce > tracked.nonce {
+		for _, hash := range hashes {
+			if _, err := t.client.TransactionReceipt(ctx, hash); err == nil {
+				return
+			}
+		}
+		t.finish(tracked, TxDropped)
+		return
+	}
+
+	if t.dropTimeout > 0 && time.Since(tracked.submitted) > t.dropTimeout {
+		for _, hash := range hashes {
+			if _, _, err := t.client.TransactionByHash(ctx, hash); err == nil {
+				return
+			}
+		}
+		t.finish(tracked, TxDropped)
+	}
+}
+
+func (t *TxTracker) finish(tracked *trackedTx, status TxStatus) {
+	t.mu.Lock()
+	tracked.result.Status = status
+	tracked.finished = time.Now()
+	result := tracked.result
+	callbacks := tracked.callbacks
+	tracked.callbacks = nil
+	close(tracked.done)
+	t.mu.Unlock()
+
+	log.Printf("Tx %s %s (confirmations: %d, replacements: %d)", result.Hash.Hex(), status, result.Confirmations, result.Replacements)
+
+	for _, cb := range callbacks {
+		go cb(result)
+	}
+}
@@ -0,0 +1,240 @@
+package contracts
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// chainNode answers the calls the tracker polls with.
+type chainNode struct {
+	head     uint64
+	nonce    uint64
+	receipts map[common.Hash]*types.Receipt
+	mempool  map[common.Hash]*types.Transaction
+}
+
+func (n *chainNode) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(n.head)
+}
+
+func (n *chainNode) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	return hexutil.Uint64(n.nonce)
+}
+
+func (n *chainNode) GetTransactionReceipt(hash common.Hash) *types.Receipt {
+	return n.receipts[hash]
+}
+
+func (n *chainNode) GetTransactionByHash(hash common.Hash) *types.Transaction {
+	return n.mempool[hash]
+}
+
+func (n *chainNode) mine(tx *types.Transaction, block uint64, status uint64) {
+	n.receipts[tx.Hash()] = &types.Receipt{
+		Status:      status,
+		TxHash:      tx.Hash(),
+		BlockNumber: new(big.Int).SetUint64(block),
+		Logs:        []*types.Log{},
+	}
+}
+
+func newTestTracker(t *testing.T, node *chainNode, confirmations uint64, dropTimeout time.Duration) *TxTracker {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", node); err != nil {
+		t.Fatal(err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	tracker := NewTxTracker(client, confirmations, dropTimeout)
+	t.Cleanup(func() {
+		tracker.Stop()
+		client.Close()
+		server.Stop()
+	})
+	return tracker
+}
+
+func signedTestTx(t *testing.T, nonce uint64, gasPrice int64) *types.Transaction {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: big.NewInt(gasPrice),
+		Gas:      21000,
+		To:       &testWallet,
+		Value:    new(big.Int),
+	}), types.HomesteadSigner{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestTxTracker(t *testing.T) {
+	const nonce = 5
+
+	tests := []struct {
+		name          string
+		confirmations uint64
+		dropTimeout   time.Duration
+		replace       bool
+		cancel        bool
+		chain         func(n *chainNode, original, replacement *types.Transaction)
+		wantDone      bool
+		wantStatus    TxStatus
+		wantReplaced  bool
+		wantCancelled bool
+	}{
+		{
+			name:  "no receipt yet",
+			chain: func(n *chainNode, original, replacement *types.Transaction) {},
+		},
+		{
+			name: "mined",
+			chain: func(n *chainNode, original, replacement *types.Transaction) {
+				n.mine(original, 100, types.ReceiptStatusSuccessful)
+			},
+			wantDone:   true,
+			wantStatus: TxMined,
+		},
+		{
+			name: "reverted",
+			chain: func(n *chainNode, original, replacement *types.Transaction) {
+				n.mine(original, 100, types.ReceiptStatusFailed)
+			},
+			wantDone:   true,
+			wantStatus: TxReverted,
+		},
+		{
+			name:          "short of the confirmation depth",
+			confirmations: 3,
+			chain: func(n *chainNode, original, replacement *types.Transaction) {
+				n.mine(original, 99, types.ReceiptStatusSuccessful)
+			},
+		},
+		{
+			name:          "at the confirmation depth",
+			confirmations: 3,
+			chain: func(n *chainNode, original, replacement *types.Transaction) {
+				n.mine(original, 98, types.ReceiptStatusSuccessful)
+			},
+			wantDone:   true,
+			wantStatus: TxMined,
+		},
+		{
+			name:    "speed-up mined",
+			replace: true,
+			chain: func(n *chainNode, original, replacement *types.Transaction) {
+				n.mine(replacement, 100, types.ReceiptStatusSuccessful)
+			},
+			wantDone:     true,
+			wantStatus:   TxMined,
+			wantReplaced: true,
+		},
+		{
+			name:    "cancel mined",
+			replace: true,
+			cancel:  true,
+			chain: func(n *chainNode, original, replacement *types.Transaction) {
+				n.mine(replacement, 100, types.ReceiptStatusSuccessful)
+			},
+			wantDone:      true,
+			wantStatus:    TxDropped,
+			wantReplaced:  true,
+			wantCancelled: true,
+		},
+		{
+			name: "nonce taken by another transaction",
+			chain: func(n *chainNode, original, replacement *types.Transaction) {
+				n.nonce = nonce + 1
+			},
+			wantDone:   true,
+			wantStatus: TxDropped,
+		},
+		{
+			name:        "gone from the mempool after the drop timeout",
+			dropTimeout: time.Nanosecond,
+			chain:       func(n *chainNode, original, replacement *types.Transaction) {},
+			wantDone:    true,
+			wantStatus:  TxDropped,
+		},
+		{
+			name:        "still in the mempool after the drop timeout",
+			dropTimeout: time.Nanosecond,
+			chain: func(n *chainNode, original, replacement *types.Transaction) {
+				n.mempool[original.Hash()] = original
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &chainNode{
+				head:     100,
+				nonce:    nonce,
+				receipts: make(map[common.Hash]*types.Receipt),
+				mempool:  make(map[common.Hash]*types.Transaction),
+			}
+			tracker := newTestTracker(t, node, tt.confirmations, tt.dropTimeout)
+
+			original := signedTestTx(t, nonce, 1e9)
+			tracker.Track(original, testWallet)
+			var replacement *types.Transaction
+			if tt.replace {
+				replacement = signedTestTx(t, nonce, 2e9)
+				if err := tracker.Replace(original.Hash(), replacement, tt.cancel); err != nil {
+					t.Fatal(err)
+				}
+			}
+			results := make(chan TxResult, 1)
+			if err := tracker.OnDone(original.Hash(), func(r TxResult) { results <- r }); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.chain(node, original, replacement)
+			time.Sleep(time.Millisecond)
+			tracker.poll()
+
+			if done := tracker.Done(original.Hash()); done != tt.wantDone {
+				t.Fatalf("Done() = %v, want %v", done, tt.wantDone)
+			}
+			if !tt.wantDone {
+				return
+			}
+
+			var result TxResult
+			select {
+			case result = <-results:
+			case <-time.After(time.Second):
+				t.Fatal("OnDone callback did not run")
+			}
+			if result.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", result.Status, tt.wantStatus)
+			}
+			if result.Cancelled != tt.wantCancelled {
+				t.Errorf("Cancelled = %v, want %v", result.Cancelled, tt.wantCancelled)
+			}
+			wantHash := original.Hash()
+			if tt.wantReplaced {
+				wantHash = replacement.Hash()
+				if result.Replacements != 1 {
+					t.Errorf("Replacements = %d, want 1", result.Replacements)
+				}
+			}
+			if result.Receipt != nil && result.Hash != wantHash {
+				t.Errorf("Hash = %s, want %s", result.Hash.Hex(), wantHash.Hex())
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"flap/contracts"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	honeypotCheckTimeout = 5 * time.Second
+	taxReadTimeout       = 5 * time.Second
+	decimalsReadTimeout  = 5 * time.Second
+)
+
+// Candidate is a launch the listener is about to buy. Info is nil for tokens
+// the TokenManager does not know (e.g. Flap portal tokens), and Creator is
+// zero when the event does not carry one. Pending launches are still in the
+// mempool; their Offers and Funds are the expected pool.
+type Candidate struct {
+	Event   contracts.Event
+	Token   common.Address
+	Quote   common.Address
+	Offers  *big.Int
+	Funds   *big.Int
+	Creator common.Address
+	Info    *contracts.TokenInfo
+	Curve   bool
+	Pending bool
+	Client  *ethclient.Client
+}
+
+type Decision struct {
+	Filter string `json:"filter"`
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+func allow(name, format string, args ...interface{}) Decision {
+	return Decision{Filter: name, Allow: true, Reason: fmt.Sprintf(format, args...)}
+}
+
+func deny(name, format string, args ...interface{}) Decision {
+	return Decision{Filter: name, Allow: false, Reason: fmt.Sprintf(format, args...)}
+}
+
+type Filter interface {
+	Name() string
+	Check(ctx context.Context, c *Candidate) Decision
+}
+
+// MinedOnly is implemented by filters that can only judge a launch once it is
+// mined. A pipeline holding one cannot clear a pending launch.
+type MinedOnly interface {
+	MinedOnly() bool
+}
+
+// Pipeline runs filters in order and stops at the first deny. Every run is
+// written to the journal, allowed or not.
+type Pipeline struct {
+	filters []Filter
+	journal *Journal
+}
+
+func NewPipeline(journal *Journal, filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters, journal: journal}
+}
+
+func (p *Pipeline) Names() []string {
+	var names []string
+	for _, f := range p.filters {
+		names = append(names, f.Name())
+	}
+	return names
+}
+
+func (p *Pipeline) Run(ctx context.Context, c *Candidate) (bool, []Decision) {
+	allowed := true
+	var decisions []Decision
+	for _, f := range p.filters {
+		decision := f.Check(ctx, c)
+		decisions = append(decisions, decision)
+		if !decision.Allow {
+			allowed = false
+			break
+		}
+	}
+
+	if p.journal != nil {
+		if err := p.journal.Record(c, allowed, decisions); err != nil {
+			log.Printf("Failed to record filter decision for %s: %v", c.Token.Hex(), err)
+		}
+	}
+	return allowed, decisions
+}
+
+// MinedOnly names the filters that deny every pending launch.
+func (p *Pipeline) MinedOnly() []string {
+	var names []string
+	for _, f := range p.filters {
+		if m, ok := f.(MinedOnly); ok && m.MinedOnly() {
+			names = append(names, f.Name())
+		}
+	}
+	return names
+}
+
+// Reject records a denial made outside the filters, e.g. a launch that was
+// seen too late to trade.
+func (p *Pipeline) Reject(c *Candidate, decision Decision) {
+	if p.journal == nil {
+		return
+	}
+	if err := p.journal.Record(c, false, []Decision{decision}); err != nil {
+		log.Printf("Failed to record filter decision for %s: %v", c.Token.Hex(), err)
+	}
+}
+
+// Settings holds what the built-in filters need; zero bounds are unbounded
+// and empty lists disable the corresponding check. Funds bounds are in whole
+// quote tokens and offers bounds in whole launched tokens; Metadata supplies
+// the decimals to scale them by, and without it 18 are assumed.
+type Settings struct {
+	MinFunds         *big.Float
+	MaxFunds         *big.Float
+	MinOffers        *big.Float
+	MaxOffers        *big.Float
+	Metadata         *contracts.MetadataService
+	QuoteTokens      []common.Address
+	CreatorBlacklist []common.Address
+	TokenTypes       []int64
+	Honeypot         *contracts.HoneypotChecker
+	Taxes            *contracts.TaxReader
+	MaxBuyTaxBps     int64
+	MaxSellTaxBps    int64
+}
+
+// Build creates the pipeline from filter names in the order given.
+func Build(names []string, s Settings, journal *Journal) (*Pipeline, error) {
+	var filters []Filter
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+			continue
+		case "token_type":
+			filters = append(filters, NewTokenTypeFilter(s.TokenTypes))
+		case "funds":
+			filters = append(filters, &RangeFilter{
+				name:     "funds",
+				min:      s.MinFunds,
+				max:      s.MaxFunds,
+				value:    func(c *Candidate) *big.Int { return c.Funds },
+				token:    func(c *Candidate) common.Address { return c.Quote },
+				metadata: s.Metadata,
+			})
+		case "offers":
+			filters = append(filters, &RangeFilter{
+				name:     "offers",
+				min:      s.MinOffers,
+				max:      s.MaxOffers,
+				value:    func(c *Candidate) *big.Int { return c.Offers },
+				token:    func(c *Candidate) common.Address { return c.Token },
+				metadata: s.Metadata,
+			})
+		case "quote":
+			filters = append(filters, &QuoteFilter{allowed: addressSet(s.QuoteTokens)})
+		case "creator":
+			filters = append(filters, &CreatorBlacklistFilter{blacklist: addressSet(s.CreatorBlacklist)})
+		case "tax":
+			if s.Taxes != nil {
+				filters = append(filters, &TaxFilter{taxes: s.Taxes, maxBuyBps: s.MaxBuyTaxBps, maxSellBps: s.MaxSellTaxBps})
+			}
+		case "honeypot":
+			if s.Honeypot != nil {
+				filters = append(filters, &HoneypotFilter{checker: s.Honeypot})
+			}
+		default:
+			return nil, fmt.Errorf("unknown filter %q", name)
+		}
+	}
+	return NewPipeline(journal, filters...), nil
+}
+
+func addressSet(addresses []common.Address) map[common.Address]bool {
+	set := make(map[common.Address]bool)
+	for _, addr := range addresses {
+		set[addr] = true
+	}
+	return set
+}
+
+// RangeFilter bounds one amount of the event. Bounds are whole tokens of the
+// token the amount is denominated in, scaled by its decimals when checked;
+// nil or zero bounds are open.
+type RangeFilter struct {
+	name     string
+	min      *big.Float
+	max      *big.Float
+	value    func(c *Candidate) *big.Int
+	token    func(c *Candidate) common.Address
+	metadata *contracts.MetadataService
+}
+
+func (f *RangeFilter) Name() string {
+	return f.name
+}
+
+func (f *RangeFilter) Check(ctx context.Context, c *Candidate) Decision {
+	value := f.value(c)
+	if value == nil {
+		return allow(f.name, "not reported by %s", eventName(c))
+	}
+	decimals, err := f.decimals(ctx, f.token(c))
+	if err != nil {
+		return deny(f.name, "failed to read decimals: %v", err)
+	}
+
+	amount := contracts.NewAmount(value, decimals)
+	if min := bound(f.min, decimals); min != nil && value.Cmp(min) < 0 {
+		return deny(f.name, "%s below minimum %s", amount, contracts.NewAmount(min, decimals))
+	}
+	if max := bound(f.max, decimals); max != nil && value.Cmp(max) > 0 {
+		return deny(f.name, "%s above maximum %s", amount, contracts.NewAmount(max, decimals))
+	}
+	return allow(f.name, "%s within bounds", amount)
+}
+
+// decimals is the precision of token; BNB, WBNB and an unknown quote are 18.
+func (f *RangeFilter) decimals(ctx context.Context, token common.Address) (uint8, error) {
+	if f.metadata == nil || token == (common.Address{}) || token == contracts.WBNB {
+		return contracts.BNBDecimals, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, decimalsReadTimeout)
+	defer cancel()
+	return f.metadata.Decimals(ctx, token)
+}
+
+// bound scales a whole-token bound to base units; nil means open.
+func bound(value *big.Float, decimals uint8) *big.Int {
+	if value == nil || value.Sign() <= 0 {
+		return nil
+	}
+	amount, err := contracts.FloatAmount(value, decimals)
+	if err != nil {
+		return nil
+	}
+	return amount.Raw
+}
+
+type QuoteFilter struct {
+	allowed map[common.Address]bool
+}
+
+func (f *QuoteFilter) Name() string {
+	return "quote"
+}
+
+func (f *QuoteFilter) Check(ctx context.Context, c *Candidate) Decision {
+	if len(f.allowed) == 0 {
+		return allow(f.Name(), "any quote token allowed")
+	}
+	if !f.allowed[c.Quote] {
+		return deny(f.Name(), "quote token %s not allowed", c.Quote.Hex())
+	}
+	return allow(f.Name(), "quote token %s allowed", c.Quote.Hex())
+}
+
+type CreatorBlacklistFilter struct {
+	blacklist map[common.Address]bool
+}
+
+func (f *CreatorBlacklistFilter) Name() string {
+	return "creator"
+}
+
+func (f *CreatorBlacklistFilter) Check(ctx context.Context, c *Candidate) Decision {
+	if c.Creator == (common.Address{}) {
+		return allow(f.Name(), "creator unknown for %s", eventName(c))
+	}
+	if f.blacklist[c.Creator] {
+		return deny(f.Name(), "creator %s is blacklisted", c.Creator.Hex())
+	}
+	return allow(f.Name(), "creator %s not blacklisted", c.Creator.Hex())
+}
+
+// TokenTypeFilter admits TokenManager tokens whose creator type has a rule.
+type TokenTypeFilter struct {
+	allowed map[int64]bool
+	list    string
+}
+
+func NewTokenTypeFilter(tokenTypes []int64) *TokenTypeFilter {
+	allowed := make(map[int64]bool)
+	var parts []string
+	for _, t := range tokenTypes {
+		allowed[t] = true
+		parts = append(parts, strconv.FormatInt(t, 10))
+	}
+	list := strings.Join(parts, ", ")
+	if list == "" {
+		list = "none"
+	}
+	return &TokenTypeFilter{allowed: allowed, list: list}
+}
+
+func (f *TokenTypeFilter) Name() string {
+	return "token_type"
+}
+
+func (f *TokenTypeFilter) Check(ctx context.Context, c *Candidate) Decision {
+	if c.Curve {
+		return allow(f.Name(), "curve token, no TokenManager type")
+	}
+	if c.Info == nil || !c.Info.Known() {
+		return deny(f.Name(), "token is not managed by the TokenManager")
+	}
+	creatorType := c.Info.CreatorType()
+	if !f.allowed[creatorType] {
+		return deny(f.Name(), "creator type %d matches no rule (allowed types: %s)", creatorType, f.list)
+	}
+	return allow(f.Name(), "creator type %d allowed", creatorType)
+}
+
+// TaxFilter reads the token's on-chain tax and bounds each side. Tokens
+// without tax getters pass, since their tax is simply not declared; a failed
+// read denies. Zero bounds are open.
+type TaxFilter struct {
+	taxes      *contracts.TaxReader
+	maxBuyBps  int64
+	maxSellBps int64
+}
+
+func (f *TaxFilter) Name() string {
+	return "tax"
+}
+
+func (f *TaxFilter) Check(ctx context.Context, c *Candidate) Decision {
+	ctx, cancel := context.WithTimeout(ctx, taxReadTimeout)
+	defer cancel()
+
+	tax, err := f.taxes.Get(ctx, c.Token)
+	if errors.Is(err, contracts.ErrNoTaxGetters) {
+		return allow(f.Name(), "no on-chain tax declared")
+	}
+	if err != nil {
+		return deny(f.Name(), "failed to read tax: %v", err)
+	}
+	if f.maxBuyBps > 0 && tax.BuyBps > f.maxBuyBps {
+		return deny(f.Name(), "%s exceeds max buy tax %.2f%%", tax.String(), float64(f.maxBuyBps)/100)
+	}
+	if f.maxSellBps > 0 && tax.SellBps > f.maxSellBps {
+		return deny(f.Name(), "%s exceeds max sell tax %.2f%%", tax.String(), float64(f.maxSellBps)/100)
+	}
+	return allow(f.Name(), "%s", tax.String())
+}
+
+// HoneypotFilter simulates a round trip through the DEX router, so it only
+// applies to tokens that already trade there. A pending launch has no pool
+// to simulate against yet and is denied.
+type HoneypotFilter struct {
+	checker *contracts.HoneypotChecker
+}
+
+func (f *HoneypotFilter) Name() string {
+	return "honeypot"
+}
+
+func (f *HoneypotFilter) MinedOnly() bool {
+	return true
+}
+
+func (f *HoneypotFilter) Check(ctx context.Context, c *Candidate) Decision {
+	if c.Curve {
+		return allow(f.Name(), "curve token, not on DEX yet")
+	}
+	if c.Pending {
+		return deny(f.Name(), "launch still pending, no pool to simulate")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, honeypotCheckTimeout)
+	defer cancel()
+
+	result, safe, err := f.checker.CheckQuote(ctx, c.Token, c.Quote)
+	if err != nil {
+		return deny(f.Name(), "check failed: %v", err)
+	}
+	if !safe {
+		return deny(f.Name(), "%s (max loss %.2f%%)", result.String(), f.checker.MaxLossPercent())
+	}
+	return allow(f.Name(), "%s", result.String())
+}
+
+func eventName(c *Candidate) string {
+	if c.Event == nil {
+		return "event"
+	}
+	return c.Event.EventName()
+}
@@ -0,0 +1,289 @@
+package filter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"flap/contracts"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// whole is n tokens of the given decimals in base units.
+func whole(n int64, decimals uint8) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), contracts.Unit(decimals))
+}
+
+func TestPipeline(t *testing.T) {
+	token := common.HexToAddress("0x000000000000000000000000000000000000cafe")
+	creator := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	settings := Settings{
+		MinFunds:         big.NewFloat(10),
+		MaxFunds:         big.NewFloat(100),
+		QuoteTokens:      []common.Address{contracts.WBNB, contracts.USDT},
+		CreatorBlacklist: []common.Address{creator},
+		Honeypot:         new(contracts.HoneypotChecker),
+	}
+	launch := func(change func(c *Candidate)) *Candidate {
+		c := &Candidate{Token: token, Quote: contracts.USDT, Funds: whole(50, 18)}
+		change(c)
+		return c
+	}
+
+	tests := []struct {
+		name          string
+		filters       []string
+		candidate     *Candidate
+		wantAllowed   bool
+		wantDecisions []string
+	}{
+		{
+			name:          "every filter passes",
+			filters:       []string{"quote", "funds", "creator"},
+			candidate:     launch(func(c *Candidate) {}),
+			wantAllowed:   true,
+			wantDecisions: []string{"quote", "funds", "creator"},
+		},
+		{
+			name:          "first deny stops the pipeline",
+			filters:       []string{"quote", "funds", "creator"},
+			candidate:     launch(func(c *Candidate) { c.Quote = contracts.USDC }),
+			wantDecisions: []string{"quote"},
+		},
+		{
+			name:          "funds below the minimum",
+			filters:       []string{"quote", "funds", "creator"},
+			candidate:     launch(func(c *Candidate) { c.Funds = whole(9, 18) }),
+			wantDecisions: []string{"quote", "funds"},
+		},
+		{
+			name:          "funds above the maximum",
+			filters:       []string{"funds"},
+			candidate:     launch(func(c *Candidate) { c.Funds = whole(101, 18) }),
+			wantDecisions: []string{"funds"},
+		},
+		{
+			name:          "blacklisted creator",
+			filters:       []string{"quote", "creator"},
+			candidate:     launch(func(c *Candidate) { c.Creator = creator }),
+			wantDecisions: []string{"quote", "creator"},
+		},
+		{
+			name:          "honeypot cannot clear a pending launch",
+			filters:       []string{"quote", "honeypot"},
+			candidate:     launch(func(c *Candidate) { c.Pending = true }),
+			wantDecisions: []string{"quote", "honeypot"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "decisions.jsonl")
+			journal, err := OpenJournal(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer journal.Close()
+			pipeline, err := Build(tt.filters, settings, journal)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			allowed, decisions := pipeline.Run(context.Background(), tt.candidate)
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			var names []string
+			for _, d := range decisions {
+				names = append(names, d.Filter)
+			}
+			if !reflect.DeepEqual(names, tt.wantDecisions) {
+				t.Fatalf("decisions from %v, want %v", names, tt.wantDecisions)
+			}
+			if last := decisions[len(decisions)-1]; last.Allow != tt.wantAllowed {
+				t.Errorf("last decision %+v, want allow %v", last, tt.wantAllowed)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			var entries []journalEntry
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				var entry journalEntry
+				if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+					t.Fatalf("journal line %q: %v", scanner.Text(), err)
+				}
+				entries = append(entries, entry)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("journal has %d lines, want 1", len(entries))
+			}
+			entry := entries[0]
+			if entry.Token != token.Hex() || entry.Event != "event" || entry.Allowed != allowed {
+				t.Errorf("journaled %+v, want token %s allowed %v", entry, token.Hex(), allowed)
+			}
+			if !reflect.DeepEqual(entry.Decisions, decisions) {
+				t.Errorf("journaled decisions %+v, want %+v", entry.Decisions, decisions)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name          string
+		filters       []string
+		settings      Settings
+		wantErr       bool
+		wantNames     []string
+		wantMinedOnly []string
+	}{
+		{
+			name:      "blank names are skipped, case is ignored",
+			filters:   []string{" Quote", "", "FUNDS "},
+			wantNames: []string{"quote", "funds"},
+		},
+		{
+			name:      "checks without their reader are left out",
+			filters:   []string{"tax", "honeypot", "creator"},
+			wantNames: []string{"creator"},
+		},
+		{
+			name:          "honeypot only judges mined launches",
+			filters:       []string{"quote", "honeypot"},
+			settings:      Settings{Honeypot: new(contracts.HoneypotChecker)},
+			wantNames:     []string{"quote", "honeypot"},
+			wantMinedOnly: []string{"honeypot"},
+		},
+		{
+			name:    "unknown filter",
+			filters: []string{"quote", "liquidity"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline, err := Build(tt.filters, tt.settings, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Build(%v) built %v, want an error", tt.filters, pipeline.Names())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := pipeline.Names(); !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("Names() = %v, want %v", names, tt.wantNames)
+			}
+			if minedOnly := pipeline.MinedOnly(); !reflect.DeepEqual(minedOnly, tt.wantMinedOnly) {
+				t.Errorf("MinedOnly() = %v, want %v", minedOnly, tt.wantMinedOnly)
+			}
+		})
+	}
+}
+
+type callArgs struct {
+	To    *common.Address `json:"to"`
+	Input hexutil.Bytes   `json:"input"`
+}
+
+// decimalsNode answers decimals() for the tokens it knows and reverts
+// otherwise.
+type decimalsNode struct {
+	decimals map[common.Address]uint8
+}
+
+func (n *decimalsNode) Call(args callArgs, block string) (hexutil.Bytes, error) {
+	decimals, ok := n.decimals[*args.To]
+	if !ok {
+		return nil, errors.New("execution reverted")
+	}
+	return common.LeftPadBytes([]byte{decimals}, 32), nil
+}
+
+func TestRangeFilterDecimals(t *testing.T) {
+	sixDecimals := common.HexToAddress("0x00000000000000000000000000000000000000d6")
+	token := common.HexToAddress("0x000000000000000000000000000000000000cafe")
+	unknown := common.HexToAddress("0x00000000000000000000000000000000000000ee")
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &decimalsNode{decimals: map[common.Address]uint8{sixDecimals: 6, token: 9}}); err != nil {
+		t.Fatal(err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	defer server.Stop()
+	defer client.Close()
+
+	pipeline, err := Build([]string{"funds", "offers"}, Settings{
+		MinFunds:  big.NewFloat(10),
+		MaxFunds:  big.NewFloat(100),
+		MaxOffers: big.NewFloat(1_000_000.5),
+		Metadata:  contracts.NewMetadataService(client),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		candidate   *Candidate
+		wantAllowed bool
+		wantReason  string
+	}{
+		{
+			name:        "funds scaled by the quote's decimals",
+			candidate:   &Candidate{Token: token, Quote: sixDecimals, Funds: whole(50, 6), Offers: whole(1_000_000, 9)},
+			wantAllowed: true,
+			wantReason:  "1000000 within bounds",
+		},
+		{
+			name:       "funds below the minimum in quote units",
+			candidate:  &Candidate{Token: token, Quote: sixDecimals, Funds: whole(9, 6)},
+			wantReason: "9 below minimum 10",
+		},
+		{
+			name:        "BNB funds are 18 decimals without a lookup",
+			candidate:   &Candidate{Token: token, Quote: contracts.WBNB, Funds: whole(100, 18)},
+			wantAllowed: true,
+			wantReason:  "not reported by event",
+		},
+		{
+			name:       "offers scaled by the token's decimals",
+			candidate:  &Candidate{Token: token, Quote: contracts.WBNB, Funds: whole(50, 18), Offers: whole(1_000_001, 9)},
+			wantReason: "1000001 above maximum 1000000.5",
+		},
+		{
+			name:       "unreadable decimals deny",
+			candidate:  &Candidate{Token: token, Quote: unknown, Funds: whole(50, 18)},
+			wantReason: "failed to read decimals",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, decisions := pipeline.Run(context.Background(), tt.candidate)
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v (%+v)", allowed, tt.wantAllowed, decisions)
+			}
+			if last := decisions[len(decisions)-1]; !strings.HasPrefix(last.Reason, tt.wantReason) {
+				t.Errorf("last reason %q, want %q", last.Reason, tt.wantReason)
+			}
+		})
+	}
+}
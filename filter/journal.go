@@ -0,0 +1,60 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type journalEntry struct {
+	Time      time.Time  `json:"time"`
+	Event     string     `json:"event"`
+	Token     string     `json:"token"`
+	TxHash    string     `json:"txHash,omitempty"`
+	Allowed   bool       `json:"allowed"`
+	Decisions []Decision `json:"decisions"`
+}
+
+// Journal appends one JSON line per pipeline run, so skipped launches can be
+// explained after the fact.
+type Journal struct {
+	file *os.File
+	mu   sync.Mutex
+}
+
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open decision journal: %w", err)
+	}
+	return &Journal{file: file}, nil
+}
+
+func (j *Journal) Record(c *Candidate, allowed bool, decisions []Decision) error {
+	entry := journalEntry{
+		Time:      time.Now().UTC(),
+		Event:     eventName(c),
+		Token:     c.Token.Hex(),
+		Allowed:   allowed,
+		Decisions: decisions,
+	}
+	if c.Event != nil {
+		entry.TxHash = c.Event.Log().TxHash.Hex()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(line, '\n'))
+	return err
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
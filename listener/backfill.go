@@ -0,0 +1,103 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const backfillTimeout = 30 * time.Second
+
+// logCursor is the position of the last processed log. whole means every log
+// of block is done, which is how the cursor starts: a subscription only
+// delivers logs from blocks after the head it was opened at.
+type logCursor struct {
+	block uint64
+	index uint
+	whole bool
+	set   bool
+}
+
+func (c logCursor) before(vLog types.Log) bool {
+	if !c.set || vLog.BlockNumber > c.block {
+		return true
+	}
+	return vLog.BlockNumber == c.block && !c.whole && vLog.Index > c.index
+}
+
+// advance moves the cursor forward to vLog; older logs leave it alone.
+func (l *EventListener) advance(vLog types.Log) {
+	l.cursorMu.Lock()
+	defer l.cursorMu.Unlock()
+
+	if l.cursor.before(vLog) {
+		l.cursor = logCursor{block: vLog.BlockNumber, index: vLog.Index, set: true}
+	}
+}
+
+// backfill replays the logs emitted since the cursor, which are the ones a
+// dropped subscription missed. It runs after the new subscription is open so
+// nothing falls between the two; the first call only anchors the cursor. It
+// returns the head it backfilled up to.
+func (l *EventListener) backfill(ctx context.Context) (uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, backfillTimeout)
+	defer cancel()
+
+	head, err := l.httpClient.BlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get head block: %w", err)
+	}
+
+	l.cursorMu.Lock()
+	cursor := l.cursor
+	if !cursor.set {
+		l.cursor = logCursor{block: head, whole: true, set: true}
+	}
+	l.cursorMu.Unlock()
+	if !cursor.set || head < cursor.block {
+		return head, nil
+	}
+
+	from := cursor.block
+	if l.maxBackfillBlocks > 0 && head-from > l.maxBackfillBlocks {
+		from = head - l.maxBackfillBlocks
+		log.Printf("Backfill gap %d-%d exceeds %d blocks, starting at %d", cursor.block, head, l.maxBackfillBlocks, from)
+	}
+
+	query := l.query()
+	query.FromBlock = new(big.Int).SetUint64(from)
+	query.ToBlock = new(big.Int).SetUint64(head)
+	logs, err := l.httpClient.FilterLogs(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to filter logs %d-%d: %w", from, head, err)
+	}
+
+	replayed := 0
+	for _, vLog := range logs {
+		if !cursor.before(vLog) {
+			continue
+		}
+		replayed++
+		select {
+		case l.deliveries <- delivery{vLog: vLog, backfillHead: head, at: time.Now()}:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	log.Printf("Backfilled blocks %d-%d: %d missed events", from, head, replayed)
+	return head, nil
+}
+
+// stale reports whether a backfilled log is too old to trade on. Live logs
+// have a zero backfillHead and are never stale.
+func (l *EventListener) stale(vLog types.Log, backfillHead uint64) (bool, uint64) {
+	if backfillHead == 0 || backfillHead < vLog.BlockNumber {
+		return false, 0
+	}
+	behind := backfillHead - vLog.BlockNumber
+	return behind > l.freshBlocks, behind
+}
@@ -0,0 +1,94 @@
+package listener
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func at(block uint64, index uint) types.Log {
+	return types.Log{BlockNumber: block, Index: index}
+}
+
+func TestLogCursorBefore(t *testing.T) {
+	partial := logCursor{block: 10, index: 3, set: true}
+	whole := logCursor{block: 10, whole: true, set: true}
+
+	tests := []struct {
+		name   string
+		cursor logCursor
+		log    types.Log
+		want   bool
+	}{
+		{name: "unset cursor takes everything", cursor: logCursor{}, log: at(1, 0), want: true},
+		{name: "the cursor log itself", cursor: partial, log: at(10, 3), want: false},
+		{name: "later log in the cursor block", cursor: partial, log: at(10, 4), want: true},
+		{name: "earlier log in the cursor block", cursor: partial, log: at(10, 2), want: false},
+		{name: "later block", cursor: partial, log: at(11, 0), want: true},
+		{name: "earlier block", cursor: partial, log: at(9, 9), want: false},
+		{name: "any log of a whole block", cursor: whole, log: at(10, 99), want: false},
+		{name: "block after a whole block", cursor: whole, log: at(11, 0), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cursor.before(tt.log); got != tt.want {
+				t.Errorf("%+v.before(%d:%d) = %v, want %v", tt.cursor, tt.log.BlockNumber, tt.log.Index, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	tests := []struct {
+		name  string
+		start logCursor
+		logs  []types.Log
+		want  logCursor
+	}{
+		{
+			name: "first log sets the cursor",
+			logs: []types.Log{at(10, 3)},
+			want: logCursor{block: 10, index: 3, set: true},
+		},
+		{
+			name: "moves through a block",
+			logs: []types.Log{at(10, 1), at(10, 3)},
+			want: logCursor{block: 10, index: 3, set: true},
+		},
+		{
+			name: "late logs never move it back",
+			logs: []types.Log{at(10, 2), at(10, 1), at(9, 7)},
+			want: logCursor{block: 10, index: 2, set: true},
+		},
+		{
+			name: "out of order delivery ends at the newest",
+			logs: []types.Log{at(10, 2), at(12, 0), at(11, 5)},
+			want: logCursor{block: 12, index: 0, set: true},
+		},
+		{
+			name:  "logs of a whole block leave it whole",
+			start: logCursor{block: 10, whole: true, set: true},
+			logs:  []types.Log{at(10, 4)},
+			want:  logCursor{block: 10, whole: true, set: true},
+		},
+		{
+			name:  "next block after a whole block",
+			start: logCursor{block: 10, whole: true, set: true},
+			logs:  []types.Log{at(11, 0)},
+			want:  logCursor{block: 11, index: 0, set: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &EventListener{cursor: tt.start}
+			for _, vLog := range tt.logs {
+				l.advance(vLog)
+			}
+			if l.cursor != tt.want {
+				t.Errorf("cursor = %+v, want %+v", l.cursor, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"flap/contracts"
+	"flap/filter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// seenRetainBlocks is how long a log identity is remembered, about a day of
+// BSC blocks; anything older can no longer arrive through backfill or reorg.
+const seenRetainBlocks = 28800
+
+// seenFlushInterval is how often changes to the de-dup cache are written.
+const seenFlushInterval = time.Second
+
+// logKey identifies a log across subscriptions, backfill and restarts.
+func logKey(vLog types.Log) string {
+	return fmt.Sprintf("%s:%d", vLog.TxHash.Hex(), vLog.Index)
+}
+
+// seenEntry is what is known about one log. busy is set while the log is
+// queued or being handled, so a reorg in that window cannot free it for a
+// second run before its buys are recorded.
+type seenEntry struct {
+	Block   uint64   `json:"block"`
+	Trades  []string `json:"trades,omitempty"`
+	Reorged bool     `json:"reorged,omitempty"`
+	busy    bool
+}
+
+// seenLogs is the persisted de-dup cache. Changes only mark it dirty; run
+// writes it out once per seenFlushInterval and on shutdown, so a burst of
+// logs costs one write instead of one per log. A crash loses at most the
+// last interval.
+type seenLogs struct {
+	path    string
+	entries map[string]*seenEntry
+	dirty   bool
+	mu      sync.Mutex
+}
+
+func loadSeenLogs(path string) (*seenLogs, error) {
+	s := &seenLogs{path: path, entries: make(map[string]*seenEntry)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// add marks the log as seen and busy, and reports whether it should be
+// handled. A log a reorg removed before anything was traded on it is handled
+// again when it is re-included, unless its first run is still going.
+func (s *seenLogs) add(vLog types.Log) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := logKey(vLog)
+	if entry, ok := s.entries[key]; ok {
+		if !entry.Reorged || len(entry.Trades) > 0 || entry.busy {
+			return false
+		}
+	}
+	s.entries[key] = &seenEntry{Block: vLog.BlockNumber, busy: true}
+	s.dirty = true
+	return true
+}
+
+// finish clears busy once the log has been handled or dropped.
+func (s *seenLogs) finish(vLog types.Log) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[logKey(vLog)]; ok {
+		entry.busy = false
+	}
+}
+
+// recordTrades ties buys to the log they were made on and reports whether a
+// reorg already removed it.
+func (s *seenLogs) recordTrades(vLog types.Log, trades []string) bool {
+	if len(trades) == 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[logKey(vLog)]
+	if !ok {
+		entry = &seenEntry{Block: vLog.BlockNumber}
+		s.entries[logKey(vLog)] = entry
+	}
+	entry.Trades = append(entry.Trades, trades...)
+	s.dirty = true
+	return entry.Reorged
+}
+
+// removed handles a log dropped by a reorg. The entry stays, flagged, so
+// buys recorded on it later are still flagged and its re-inclusion cannot
+// buy a second time; add only hands it out again if nothing was traded.
+func (s *seenLogs) removed(vLog types.Log) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[logKey(vLog)]
+	if !ok {
+		return nil
+	}
+	entry.Reorged = true
+	s.dirty = true
+	return entry.Trades
+}
+
+// run flushes the cache every seenFlushInterval until ctx ends, then once
+// more.
+func (s *seenLogs) run(ctx context.Context) {
+	ticker := time.NewTicker(seenFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-ctx.Done():
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush writes the cache if anything changed since the last write.
+func (s *seenLogs) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return
+	}
+	s.dirty = false
+	s.save()
+}
+
+// save writes the cache through a temp file so a crash never leaves it
+// truncated. Callers hold mu.
+func (s *seenLogs) save() {
+	if s.path == "" {
+		return
+	}
+
+	var newest uint64
+	for _, entry := range s.entries {
+		if entry.Block > newest {
+			newest = entry.Block
+		}
+	}
+	for key, entry := range s.entries {
+		if newest > seenRetainBlocks && entry.Block < newest-seenRetainBlocks {
+			delete(s.entries, key)
+		}
+	}
+
+	data, err := json.Marshal(s.entries)
+	if err != nil {
+		log.Printf("Failed to encode seen logs: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Failed to write seen logs: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("Failed to write seen logs: %v", err)
+	}
+}
+
+// pendingLog is a log waiting for confirmDepth blocks before it is acted on.
+type pendingLog struct {
+	vLog         types.Log
+	backfillHead uint64
+}
+
+func (l *EventListener) hold(vLog types.Log, backfillHead uint64) {
+	l.pendingMu.Lock()
+	defer l.pendingMu.Unlock()
+	l.pending[logKey(vLog)] = pendingLog{vLog: vLog, backfillHead: backfillHead}
+	log.Printf("Holding %s (block %d) for %d confirmations", logKey(vLog), vLog.BlockNumber, l.confirmDepth)
+}
+
+// releaseHeld checks held logs every finalCheckInterval until ctx ends. It
+// runs apart from the Start loop so the head and header RPCs never delay
+// log delivery.
+func (l *EventListener) releaseHeld(ctx context.Context) {
+	ticker := time.NewTicker(finalCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.releaseFinal(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// releaseFinal processes held logs that are confirmDepth blocks deep and
+// still in the canonical chain. Logs whose block was replaced are dropped.
+func (l *EventListener) releaseFinal(ctx context.Context) {
+	l.pendingMu.Lock()
+	if len(l.pending) == 0 {
+		l.pendingMu.Unlock()
+		return
+	}
+	l.pendingMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	head, err := l.httpClient.BlockNumber(ctx)
+	if err != nil {
+		log.Printf("Failed to get head for held events: %v", err)
+		return
+	}
+
+	var ready []pendingLog
+	l.pendingMu.Lock()
+	for key, p := range l.pending {
+		if p.vLog.BlockNumber+l.confirmDepth <= head {
+			ready = append(ready, p)
+			delete(l.pending, key)
+		}
+	}
+	l.pendingMu.Unlock()
+
+	canonical := make(map[uint64]common.Hash)
+	for _, p := range ready {
+		hash, ok := canonical[p.vLog.BlockNumber]
+		if !ok {
+			header, err := l.httpClient.HeaderByNumber(ctx, new(big.Int).SetUint64(p.vLog.BlockNumber))
+			if err != nil {
+				log.Printf("Failed to verify block %d, holding %s: %v", p.vLog.BlockNumber, logKey(p.vLog), err)
+				l.pendingMu.Lock()
+				l.pending[logKey(p.vLog)] = p
+				l.pendingMu.Unlock()
+				continue
+			}
+			hash = header.Hash()
+			canonical[p.vLog.BlockNumber] = hash
+		}
+		if hash != p.vLog.BlockHash {
+			log.Printf("Block %d was reorged, dropping held %s", p.vLog.BlockNumber, logKey(p.vLog))
+			l.seen.removed(p.vLog)
+			l.seen.finish(p.vLog)
+			continue
+		}
+		l.enqueue(p.vLog, p.backfillHead)
+	}
+}
+
+// handleRemoved deals with a log the node retracted in a reorg.
+func (l *EventListener) handleRemoved(vLog types.Log) {
+	l.pendingMu.Lock()
+	_, held := l.pending[logKey(vLog)]
+	delete(l.pending, logKey(vLog))
+	l.pendingMu.Unlock()
+
+	trades := l.seen.removed(vLog)
+	if held {
+		l.seen.finish(vLog)
+		log.Printf("Reorg removed held %s (block %d) before it was final", logKey(vLog), vLog.BlockNumber)
+		return
+	}
+	if len(trades) == 0 {
+		log.Printf("Reorg removed %s (block %d), nothing was traded on it", logKey(vLog), vLog.BlockNumber)
+		return
+	}
+
+	log.Printf("REORG: %s (block %d) was removed after we traded on it, check buys %v", logKey(vLog), vLog.BlockNumber, trades)
+	l.reject(vLog, "reorg", fmt.Sprintf("event removed by reorg after buys %v", trades))
+}
+
+// recordTrades ties sent buys to their log. Buys that went out while a reorg
+// removed the log are flagged the way handleRemoved flags earlier ones.
+func (l *EventListener) recordTrades(vLog types.Log, trades []string) {
+	if !l.seen.recordTrades(vLog, trades) {
+		return
+	}
+	log.Printf("REORG: %s (block %d) was removed while buys %v were sent, check them", logKey(vLog), vLog.BlockNumber, trades)
+	l.reject(vLog, "reorg", fmt.Sprintf("event removed by reorg during buys %v", trades))
+}
+
+// reject journals a skip decided outside the filter pipeline for a raw log.
+func (l *EventListener) reject(vLog types.Log, name, reason string) {
+	if l.filters == nil {
+		return
+	}
+	decoded, err := contracts.DecodeEvent(vLog)
+	if err != nil {
+		return
+	}
+	l.filters.Reject(&filter.Candidate{Event: decoded, Token: eventToken(decoded)}, filter.Decision{
+		Filter: name,
+		Reason: reason,
+	})
+}
+
+func eventToken(event contracts.Event) common.Address {
+	switch e := event.(type) {
+	case *contracts.LiquidityAddedEvent:
+		return e.Base
+	case *contracts.TokenCreatedEvent:
+		return e.Token
+	case *contracts.TokenBoughtEvent:
+		return e.Token
+	case *contracts.TokenSoldEvent:
+		return e.Token
+	case *contracts.LaunchedToDEXEvent:
+		return e.Token
+	case *contracts.TokenTaxSetEvent:
+		return e.Token
+	}
+	return common.Address{}
+}
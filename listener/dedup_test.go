@@ -0,0 +1,80 @@
+package listener
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestSeenLogsReorg(t *testing.T) {
+	vLog := types.Log{TxHash: common.HexToHash("0x01"), Index: 2, BlockNumber: 100}
+	trades := []string{"0xbuy"}
+
+	tests := []struct {
+		name        string
+		steps       func(s *seenLogs) bool
+		wantReorged bool
+		wantReAdd   bool
+	}{
+		{
+			name: "delivered twice",
+			steps: func(s *seenLogs) bool {
+				return false
+			},
+		},
+		{
+			name: "removed after its buys were recorded",
+			steps: func(s *seenLogs) bool {
+				s.recordTrades(vLog, trades)
+				s.finish(vLog)
+				s.removed(vLog)
+				return false
+			},
+		},
+		{
+			name: "removed while its buys were going out",
+			steps: func(s *seenLogs) bool {
+				s.removed(vLog)
+				reorged := s.recordTrades(vLog, trades)
+				s.finish(vLog)
+				return reorged
+			},
+			wantReorged: true,
+		},
+		{
+			name: "removed with nothing traded, first run still going",
+			steps: func(s *seenLogs) bool {
+				s.removed(vLog)
+				return false
+			},
+		},
+		{
+			name: "removed with nothing traded, first run done",
+			steps: func(s *seenLogs) bool {
+				s.finish(vLog)
+				s.removed(vLog)
+				return false
+			},
+			wantReAdd: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := loadSeenLogs("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !s.add(vLog) {
+				t.Fatal("first add reported the log as seen")
+			}
+			if reorged := tt.steps(s); reorged != tt.wantReorged {
+				t.Errorf("recordTrades reported reorged = %v, want %v", reorged, tt.wantReorged)
+			}
+			if got := s.add(vLog); got != tt.wantReAdd {
+				t.Errorf("re-delivered add() = %v, want %v", got, tt.wantReAdd)
+			}
+		})
+	}
+}
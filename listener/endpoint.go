@@ -0,0 +1,299 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	latencyStatsInterval = 5 * time.Minute
+	arrivalWindow        = time.Minute
+	wsRetryInterval      = 30 * time.Second
+	// minLatencySamples is how many events an endpoint must have delivered
+	// before its average lag is trusted enough to drop it.
+	minLatencySamples = 20
+)
+
+// delivery is a log as it reached the listener. source is nil for logs
+// fetched by backfill or polling, which take no part in the latency race.
+type delivery struct {
+	vLog         types.Log
+	backfillHead uint64
+	source       *endpoint
+	at           time.Time
+}
+
+type endpointHealth struct {
+	source *endpoint
+	up     bool
+}
+
+type arrival struct {
+	at     time.Time
+	source *endpoint
+}
+
+// endpoint is one WebSocket provider. Every endpoint runs its own
+// subscription; the listener acts on whichever delivers a log first.
+type endpoint struct {
+	url     string
+	name    string
+	client  *ethclient.Client
+	mu      sync.RWMutex
+	cancel  context.CancelFunc
+	dropped bool
+
+	events   int
+	wins     int
+	lagTotal time.Duration
+}
+
+func newEndpoint(rawURL string) *endpoint {
+	e := &endpoint{url: rawURL, name: redactURL(rawURL)}
+	client, err := ethclient.Dial(rawURL)
+	if err != nil {
+		log.Printf("[%s] Failed to connect: %v", e.name, err)
+		return e
+	}
+	e.client = client
+	return e
+}
+
+// redactURL keeps scheme and host so API keys in paths or queries stay out
+// of the logs.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "endpoint"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func (e *endpoint) getClient() *ethclient.Client {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.client
+}
+
+// reconnect replaces the client, backing off between attempts. The lock is
+// only held to swap clients, never across a dial or a backoff, so getClient
+// and close are not stuck behind a dead provider.
+func (e *endpoint) reconnect(ctx context.Context) error {
+	e.mu.Lock()
+	if e.client != nil {
+		e.client.Close()
+		e.client = nil
+	}
+	e.mu.Unlock()
+
+	for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("[%s] Reconnecting... attempt %d/%d", e.name, attempt, maxReconnectAttempts)
+		backoff := reconnectDelay * time.Duration(attempt)
+
+		client, err := ethclient.Dial(e.url)
+		if err != nil {
+			log.Printf("[%s] Reconnect failed: %v", e.name, err)
+			if err := sleepCtx(ctx, backoff); err != nil {
+				return err
+			}
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		_, err = client.BlockNumber(checkCtx)
+		cancel()
+
+		if err != nil {
+			log.Printf("[%s] Connection test failed: %v", e.name, err)
+			client.Close()
+			if err := sleepCtx(ctx, backoff); err != nil {
+				return err
+			}
+			continue
+		}
+
+		e.mu.Lock()
+		e.client = client
+		e.mu.Unlock()
+		log.Printf("[%s] Reconnected successfully", e.name)
+		return nil
+	}
+
+	return fmt.Errorf("failed to reconnect after %d attempts", maxReconnectAttempts)
+}
+
+// sleepCtx waits for d, or returns early with the context's error.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *endpoint) healthCheck(ctx context.Context) bool {
+	checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := e.getClient().BlockNumber(checkCtx)
+	if err != nil {
+		log.Printf("[%s] Health check failed: %v", e.name, err)
+		return false
+	}
+	return true
+}
+
+// run keeps the endpoint subscribed until ctx ends. An endpoint that cannot
+// reconnect reports itself down and tries again after wsRetryInterval, so a
+// recovered provider takes over from polling without a restart.
+func (e *endpoint) run(ctx context.Context, l *EventListener) {
+	for {
+		if e.getClient() != nil {
+			err := e.subscribe(ctx, l)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[%s] Subscription ended: %v", e.name, err)
+		}
+		l.setHealth(ctx, e, false)
+		if err := e.reconnect(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[%s] Endpoint down, retrying in %s: %v", e.name, wsRetryInterval, err)
+			select {
+			case <-time.After(wsRetryInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (e *endpoint) subscribe(ctx context.Context, l *EventListener) error {
+	logs := make(chan types.Log)
+	sub, err := e.getClient().SubscribeFilterLogs(ctx, l.query(), logs)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	log.Printf("[%s] Subscription active, listening for events...", e.name)
+	l.setHealth(ctx, e, true)
+
+	if _, err := l.backfill(ctx); err != nil {
+		log.Printf("[%s] Backfill failed: %v", e.name, err)
+	}
+
+	healthTicker := time.NewTicker(healthCheckInterval)
+	defer healthTicker.Stop()
+
+	for {
+		select {
+		case err := <-sub.Err():
+			return fmt.Errorf("subscription error: %w", err)
+		case vLog := <-logs:
+			select {
+			case l.deliveries <- delivery{vLog: vLog, source: e, at: time.Now()}:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-healthTicker.C:
+			if !e.healthCheck(ctx) {
+				return fmt.Errorf("health check failed")
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (e *endpoint) avgLag() time.Duration {
+	if e.events == 0 {
+		return 0
+	}
+	return e.lagTotal / time.Duration(e.events)
+}
+
+func (e *endpoint) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client != nil {
+		e.client.Close()
+	}
+}
+
+// race records which endpoint delivered a log first and how far behind the
+// others were, and reports whether this delivery is the first.
+func (l *EventListener) race(d delivery) bool {
+	key := logKey(d.vLog)
+	if d.vLog.Removed {
+		key = "removed:" + key
+	}
+
+	first, seen := l.arrivals[key]
+	if !seen {
+		l.arrivals[key] = arrival{at: d.at, source: d.source}
+	}
+	if d.source == nil {
+		return !seen
+	}
+
+	d.source.events++
+	if !seen {
+		d.source.wins++
+	} else if first.source != nil {
+		d.source.lagTotal += d.at.Sub(first.at)
+	}
+	return !seen
+}
+
+// reviewEndpoints logs per-endpoint latency and drops endpoints whose average
+// lag exceeds maxLag, returning the dropped ones. The fastest live endpoint is
+// never dropped.
+func (l *EventListener) reviewEndpoints() []*endpoint {
+	cutoff := time.Now().Add(-arrivalWindow)
+	for key, a := range l.arrivals {
+		if a.at.Before(cutoff) {
+			delete(l.arrivals, key)
+		}
+	}
+
+	var live []*endpoint
+	for _, e := range l.endpoints {
+		if !e.dropped {
+			live = append(live, e)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].avgLag() < live[j].avgLag() })
+
+	for _, e := range live {
+		log.Printf("[%s] %d events, first on %d, average lag %s", e.name, e.events, e.wins, e.avgLag())
+	}
+
+	if l.maxLag <= 0 {
+		return nil
+	}
+	var dropped []*endpoint
+	for _, e := range live[min(1, len(live)):] {
+		if e.events >= minLatencySamples && e.avgLag() > l.maxLag {
+			log.Printf("[%s] Dropping endpoint: average lag %s exceeds %s", e.name, e.avgLag(), l.maxLag)
+			e.dropped = true
+			e.cancel()
+			dropped = append(dropped, e)
+		}
+	}
+	return dropped
+}
@@ -2,13 +2,17 @@ package listener
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"flap/contracts"
+	"flap/filter"
 	"flap/stoploss"
 
 	"github.com/ethereum/go-ethereum"
@@ -21,166 +25,384 @@ const (
 	healthCheckInterval  = 30 * time.Second
 	reconnectDelay       = 5 * time.Second
 	maxReconnectAttempts = 10
+	tokenInfoTimeout     = 5 * time.Second
+	finalCheckInterval   = time.Second
+	deliveryBuffer       = 256
+	defaultPollInterval  = time.Second
+)
+
+const (
+	ModeDEX   = "dex"
+	ModeCurve = "curve"
 )
 
 type WalletInfo struct {
-	Swapper      *contracts.PancakeSwapper
+	Swapper      contracts.Swapper
 	BuyAmountWei *big.Int
 }
 
-type EventListener struct {
-	wsURL           string
-	client          *ethclient.Client
-	httpClient      *ethclient.Client
-	contractAddress common.Address
-	wallets         []WalletInfo
-	stopLossMonitor *stoploss.StopLossMonitor
-	mu              sync.RWMutex
+// TokenRule is what to do with one TokenManager creator type. BuyAmountsWei
+// is indexed by wallet; a missing or nil entry uses the wallet's default.
+type TokenRule struct {
+	TokenType     int64
+	BuyAmountsWei []*big.Int
+	Exit          stoploss.ExitRule
 }
 
-func NewEventListener(wsURL string, contractAddr string, wallets []WalletInfo, stopLossMonitor *stoploss.StopLossMonitor, httpClient *ethclient.Client) (*EventListener, error) {
-	client, err := ethclient.Dial(wsURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to BSC: %w", err)
+func (r *TokenRule) buyAmount(walletIndex int, wallet WalletInfo) *big.Int {
+	if r != nil && walletIndex < len(r.BuyAmountsWei) && r.BuyAmountsWei[walletIndex] != nil {
+		return r.BuyAmountsWei[walletIndex]
 	}
-
-	return &EventListener{
-		wsURL:           wsURL,
-		client:          client,
-		httpClient:      httpClient,
-		contractAddress: common.HexToAddress(contractAddr),
-		wallets:         wallets,
-		stopLossMonitor: stopLossMonitor,
-	}, nil
+	return wallet.BuyAmountWei
 }
 
-func (l *EventListener) reconnect() error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	if l.client != nil {
-		l.client.Close()
+func (r *TokenRule) exit() stoploss.ExitRule {
+	if r == nil {
+		return stoploss.ExitRule{}
 	}
+	return r.Exit
+}
 
-	for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
-		log.Printf("Reconnecting... attempt %d/%d", attempt, maxReconnectAttempts)
+// Config selects what the listener subscribes to. Events defaults to
+// LiquidityAdded in dex mode and TokenCreated in curve mode.
+type Config struct {
+	WSURLs          []string
+	ContractAddress string
+	PortalAddress   string
+	Mode            string
+	Events          []string
+	Rules           []TokenRule
+	// FreshBlocks is how many blocks old a backfilled event may be and still
+	// be traded; older ones are only journaled.
+	FreshBlocks       uint64
+	MaxBackfillBlocks uint64
+	// ConfirmDepth holds events until they are this many blocks deep; zero
+	// acts on them as soon as they arrive.
+	ConfirmDepth uint64
+	SeenLogsFile string
+	// MaxLag drops a WebSocket endpoint whose events trail the fastest one by
+	// more than this on average; zero keeps every endpoint.
+	MaxLag time.Duration
+	// PollInterval is how often blocks are polled over HTTP while every
+	// WebSocket endpoint is down.
+	PollInterval time.Duration
+	// MempoolWSURL enables the pending-transaction watcher in dex mode; it
+	// needs a node that serves newPendingTransactions.
+	MempoolWSURL string
+	// Workers, QueueSize and EventDeadline size the event worker pool; zero
+	// values use the defaults.
+	Workers       int
+	QueueSize     int
+	EventDeadline time.Duration
+	// QuoteBuyAmounts sizes buys of tokens launched against a quote other
+	// than BNB, in that quote's base units. Quotes not listed use the BNB
+	// buy amounts; either way the BNB is swapped through the quote.
+	QuoteBuyAmounts map[common.Address]*big.Int
+	// Taxes reads on-chain token taxes so buys and exits use the real rate
+	// instead of the default; nil keeps the default.
+	Taxes *contracts.TaxReader
+	// ApproveAfterBuy approves the bought token for selling as soon as the
+	// buy is mined, so an exit never waits on an approval.
+	ApproveAfterBuy bool
+}
 
-		client, err := ethclient.Dial(l.wsURL)
-		if err != nil {
-			log.Printf("Reconnect failed: %v", err)
-			time.Sleep(reconnectDelay * time.Duration(attempt))
-			continue
-		}
+type EventListener struct {
+	endpoints         []*endpoint
+	deliveries        chan delivery
+	arrivals          map[string]arrival
+	maxLag            time.Duration
+	health            chan endpointHealth
+	pollInterval      time.Duration
+	jobs              chan job
+	workers           int
+	eventDeadline     time.Duration
+	mempoolURL        string
+	httpClient        *ethclient.Client
+	contractAddress   common.Address
+	mode              string
+	portalAddress     common.Address
+	events            []string
+	topics            []common.Hash
+	rules             []TokenRule
+	wallets           []WalletInfo
+	stopLossMonitor   *stoploss.StopLossMonitor
+	filters           *filter.Pipeline
+	freshBlocks       uint64
+	maxBackfillBlocks uint64
+	cursor            logCursor
+	cursorMu          sync.Mutex
+	seen              *seenLogs
+	confirmDepth      uint64
+	pending           map[string]pendingLog
+	pendingMu         sync.Mutex
+	backruns          map[common.Address][]string
+	backrunMu         sync.Mutex
+	quoteBuyAmounts   map[common.Address]*big.Int
+	taxes             *contracts.TaxReader
+	approveAfterBuy   bool
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		_, err = client.BlockNumber(ctx)
-		cancel()
+func NewEventListener(cfg Config, wallets []WalletInfo, stopLossMonitor *stoploss.StopLossMonitor, httpClient *ethclient.Client, filters *filter.Pipeline) (*EventListener, error) {
+	if cfg.Mode != ModeDEX && cfg.Mode != ModeCurve {
+		return nil, fmt.Errorf("unknown trading mode %q", cfg.Mode)
+	}
 
-		if err != nil {
-			log.Printf("Connection test failed: %v", err)
-			client.Close()
-			time.Sleep(reconnectDelay * time.Duration(attempt))
-			continue
+	events := cfg.Events
+	if len(events) == 0 {
+		events = []string{contracts.EventLiquidityAdded}
+		if cfg.Mode == ModeCurve {
+			events = []string{contracts.EventTokenCreated}
 		}
-
-		l.client = client
-		log.Printf("Reconnected successfully")
-		return nil
+	}
+	topics, err := contracts.EventTopics(events)
+	if err != nil {
+		return nil, err
 	}
 
-	return fmt.Errorf("failed to reconnect after %d attempts", maxReconnectAttempts)
-}
+	seen, err := loadSeenLogs(cfg.SeenLogsFile)
+	if err != nil {
+		return nil, err
+	}
 
-func (l *EventListener) healthCheck(ctx context.Context) bool {
-	l.mu.RLock()
-	client := l.client
-	l.mu.RUnlock()
+	pollInterval := cfg.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
 
-	checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	eventDeadline := cfg.EventDeadline
+	if eventDeadline <= 0 {
+		eventDeadline = defaultEventDeadline
+	}
 
-	_, err := client.BlockNumber(checkCtx)
-	if err != nil {
-		log.Printf("Health check failed: %v", err)
-		return false
+	if len(cfg.WSURLs) == 0 {
+		return nil, fmt.Errorf("no WebSocket endpoint configured")
+	}
+	var endpoints []*endpoint
+	for _, wsURL := range cfg.WSURLs {
+		endpoints = append(endpoints, newEndpoint(wsURL))
 	}
-	return true
+
+	return &EventListener{
+		endpoints:         endpoints,
+		deliveries:        make(chan delivery, deliveryBuffer),
+		arrivals:          make(map[string]arrival),
+		maxLag:            cfg.MaxLag,
+		health:            make(chan endpointHealth),
+		pollInterval:      pollInterval,
+		mempoolURL:        cfg.MempoolWSURL,
+		jobs:              make(chan job, queueSize),
+		workers:           workers,
+		eventDeadline:     eventDeadline,
+		httpClient:        httpClient,
+		contractAddress:   common.HexToAddress(cfg.ContractAddress),
+		mode:              cfg.Mode,
+		portalAddress:     common.HexToAddress(cfg.PortalAddress),
+		events:            events,
+		topics:            topics,
+		rules:             cfg.Rules,
+		wallets:           wallets,
+		stopLossMonitor:   stopLossMonitor,
+		filters:           filters,
+		freshBlocks:       cfg.FreshBlocks,
+		maxBackfillBlocks: cfg.MaxBackfillBlocks,
+		seen:              seen,
+		confirmDepth:      cfg.ConfirmDepth,
+		pending:           make(map[string]pendingLog),
+		backruns:          make(map[common.Address][]string),
+		quoteBuyAmounts:   cfg.QuoteBuyAmounts,
+		taxes:             cfg.Taxes,
+		approveAfterBuy:   cfg.ApproveAfterBuy,
+	}, nil
 }
 
+// Start subscribes on every endpoint and processes logs in arrival order,
+// acting on the first copy of each. While no endpoint is up, logs are polled
+// over HTTP instead. It returns once ctx ends.
 func (l *EventListener) Start(ctx context.Context) error {
-	log.Printf("Listening for LiquidityAdded events on contract: %s", l.contractAddress.Hex())
+	log.Printf("Listening for %s events on %s and %s (mode: %s)", strings.Join(l.events, ", "), l.contractAddress.Hex(), l.portalAddress.Hex(), l.mode)
 	for i, w := range l.wallets {
 		log.Printf("Wallet %d: %s (Buy: %s wei)", i+1, w.Swapper.GetAddress().Hex(), w.BuyAmountWei.String())
 	}
 
+	l.startWorkers(ctx)
+	seenDone := make(chan struct{})
+	go func() {
+		l.seen.run(ctx)
+		close(seenDone)
+	}()
+	defer func() { <-seenDone }()
+	if l.confirmDepth > 0 {
+		go l.releaseHeld(ctx)
+	}
+
+	for _, e := range l.endpoints {
+		var epCtx context.Context
+		epCtx, e.cancel = context.WithCancel(ctx)
+		go e.run(epCtx, l)
+	}
+	log.Printf("Racing %d WebSocket endpoint(s)", len(l.endpoints))
+
+	if l.mempoolURL != "" {
+		switch {
+		case l.mode != ModeDEX:
+			log.Printf("Mempool watching only applies to dex mode, disabled")
+		case l.filters != nil && len(l.filters.MinedOnly()) > 0:
+			log.Printf("Mempool watching disabled: filters %s can only check a mined launch", strings.Join(l.filters.MinedOnly(), ", "))
+		default:
+			go newMempoolWatcher(l, l.mempoolURL).run(ctx)
+		}
+	}
+
+	statsTicker := time.NewTicker(latencyStatsInterval)
+	defer statsTicker.Stop()
+
+	up := make(map[*endpoint]bool)
+	var stopPolling context.CancelFunc
+	defer func() {
+		if stopPolling != nil {
+			stopPolling()
+		}
+	}()
+
 	for {
 		select {
+		case d := <-l.deliveries:
+			if l.race(d) {
+				l.handleLog(d.vLog, d.backfillHead)
+			}
+		case h := <-l.health:
+			if h.up && !h.source.dropped {
+				up[h.source] = true
+			} else {
+				delete(up, h.source)
+			}
+			switch {
+			case len(up) == 0 && stopPolling == nil:
+				stopPolling = l.startPolling(ctx)
+			case len(up) > 0 && stopPolling != nil:
+				log.Printf("[%s] WebSocket is back, stopping HTTP polling", h.source.name)
+				stopPolling()
+				stopPolling = nil
+			}
+		case <-statsTicker.C:
+			for _, e := range l.reviewEndpoints() {
+				delete(up, e)
+			}
 		case <-ctx.Done():
 			return nil
-		default:
-			err := l.subscribe(ctx)
-			if err != nil {
-				log.Printf("Subscription ended: %v", err)
-
-				select {
-				case <-ctx.Done():
-					return nil
-				default:
-					if reconnErr := l.reconnect(); reconnErr != nil {
-						return reconnErr
-					}
-				}
-			}
 		}
 	}
 }
 
-func (l *EventListener) subscribe(ctx context.Context) error {
-	l.mu.RLock()
-	client := l.client
-	l.mu.RUnlock()
-
-	query := ethereum.FilterQuery{
-		Addresses: []common.Address{l.contractAddress},
-		Topics:    [][]common.Hash{{contracts.LiquidityAddedEventSig}},
+func (l *EventListener) query() ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{l.contractAddress, l.portalAddress},
+		Topics:    [][]common.Hash{l.topics},
 	}
+}
 
-	logs := make(chan types.Log)
-	sub, err := client.SubscribeFilterLogs(ctx, query, logs)
-	if err != nil {
-		return fmt.Errorf("failed to subscribe: %w", err)
+// matches reports whether a log is one the subscription would deliver.
+func (l *EventListener) matches(vLog types.Log) bool {
+	if vLog.Address != l.contractAddress && vLog.Address != l.portalAddress {
+		return false
+	}
+	if len(vLog.Topics) == 0 {
+		return false
+	}
+	for _, topic := range l.topics {
+		if vLog.Topics[0] == topic {
+			return true
+		}
 	}
-	defer sub.Unsubscribe()
+	return false
+}
 
-	log.Println("Subscription active, listening for events...")
+// handleLog takes one log from the subscription or backfill. backfillHead is
+// the head a backfilled log was fetched at and zero for live logs. Each log
+// is processed once, however many times it is delivered.
+func (l *EventListener) handleLog(vLog types.Log, backfillHead uint64) {
+	if vLog.Removed {
+		l.handleRemoved(vLog)
+		return
+	}
+	if !l.seen.add(vLog) {
+		return
+	}
+	l.advance(vLog)
 
-	healthTicker := time.NewTicker(healthCheckInterval)
-	defer healthTicker.Stop()
+	if l.confirmDepth > 0 {
+		l.hold(vLog, backfillHead)
+		return
+	}
+	l.enqueue(vLog, backfillHead)
+}
 
-	for {
-		select {
-		case err := <-sub.Err():
-			return fmt.Errorf("subscription error: %w", err)
-		case vLog := <-logs:
-			l.handleLog(vLog)
-		case <-healthTicker.C:
-			if !l.healthCheck(ctx) {
-				return fmt.Errorf("health check failed")
-			}
+// process handles one log on a worker; ctx carries the event's deadline.
+func (l *EventListener) process(ctx context.Context, vLog types.Log, backfillHead uint64) {
+	decoded, err := contracts.DecodeEvent(vLog)
+	if err != nil {
+		log.Printf("Failed to parse event: %v", err)
+		return
+	}
 
-		case <-ctx.Done():
-			return nil
+	switch event := decoded.(type) {
+	case *contracts.LiquidityAddedEvent:
+		l.handleLiquidityAdded(ctx, event, backfillHead)
+	case *contracts.TokenCreatedEvent:
+		l.handleTokenCreated(ctx, event, backfillHead)
+	case *contracts.TokenBoughtEvent:
+		log.Printf("TokenBought: %s buyer %s amount %s for %s wei (tx %s)", event.Token.Hex(), event.Buyer.Hex(), event.Amount.String(), event.Eth.String(), vLog.TxHash.Hex())
+	case *contracts.TokenSoldEvent:
+		log.Printf("TokenSold: %s seller %s amount %s for %s wei (tx %s)", event.Token.Hex(), event.Seller.Hex(), event.Amount.String(), event.Eth.String(), vLog.TxHash.Hex())
+	case *contracts.LaunchedToDEXEvent:
+		log.Printf("LaunchedToDEX: %s pool %s (%s tokens, %s wei, tx %s)", event.Token.Hex(), event.Pool.Hex(), event.Amount.String(), event.Eth.String(), vLog.TxHash.Hex())
+	case *contracts.TokenTaxSetEvent:
+		log.Printf("FlapTokenTaxSet: %s tax %s (tx %s)", event.Token.Hex(), event.Tax.String(), vLog.TxHash.Hex())
+		if l.taxes != nil {
+			l.setTax(l.taxes.Update(event.Token, event.Tax))
 		}
 	}
 }
 
-func (l *EventListener) handleLog(vLog types.Log) {
-	event, err := contracts.ParseLiquidityAddedEvent(vLog.Data, vLog.Topics)
-	if err != nil {
-		log.Printf("Failed to parse event: %v", err)
+// handleTokenCreated buys fresh portal tokens on the bonding curve. Portal
+// tokens have no TokenManager type, so they buy with the wallet defaults.
+func (l *EventListener) handleTokenCreated(ctx context.Context, event *contracts.TokenCreatedEvent, backfillHead uint64) {
+	vLog := event.Log()
+	log.Printf("=== TokenCreated Event Detected ===")
+	log.Printf("Token: %s (%s / %s)", event.Token.Hex(), event.Name, event.Symbol)
+	log.Printf("Creator: %s", event.Creator.Hex())
+	log.Printf("TX Hash: %s", vLog.TxHash.Hex())
+
+	if l.mode != ModeCurve {
+		log.Printf("Not in curve mode, not buying %s before DEX listing", event.Token.Hex())
 		return
 	}
 
+	candidate := &filter.Candidate{
+		Event:   event,
+		Token:   event.Token,
+		Creator: event.Creator,
+		Curve:   true,
+		Client:  l.httpClient,
+	}
+	if !l.runFilters(ctx, candidate, backfillHead) {
+		return
+	}
+	l.applyTax(ctx, event.Token)
+	l.recordTrades(vLog, l.buyAll(ctx, event.Token, contracts.WBNB, nil))
+}
+
+func (l *EventListener) handleLiquidityAdded(ctx context.Context, event *contracts.LiquidityAddedEvent, backfillHead uint64) {
+	vLog := event.Log()
+
 	log.Printf("=== LiquidityAdded Event Detected ===")
 	log.Printf("Base (Token): %s", event.Base.Hex())
 	log.Printf("Offers: %s", event.Offers.String())
@@ -188,44 +410,271 @@ func (l *EventListener) handleLog(vLog types.Log) {
 	log.Printf("Funds: %s", event.Funds.String())
 	log.Printf("TX Hash: %s", vLog.TxHash.Hex())
 
-	isTax, err := contracts.IsTaxToken(l.httpClient, event.Base)
+	infoCtx, infoCancel := context.WithTimeout(ctx, tokenInfoTimeout)
+	info, err := contracts.GetTokenInfo(infoCtx, l.httpClient, event.Base)
+	infoCancel()
 	if err != nil {
-		log.Printf("Failed to check TaxToken: %v", err)
+		log.Printf("Failed to get token info: %v", err)
+		return
+	}
+	if info.Known() {
+		log.Printf("Token info: %s", info.String())
+	}
+	l.cacheTax(info)
+
+	candidate := &filter.Candidate{
+		Event:  event,
+		Token:  event.Base,
+		Quote:  event.Quote,
+		Offers: event.Offers,
+		Funds:  event.Funds,
+		Info:   info,
+		Client: l.httpClient,
+	}
+	allowed := l.runFilters(ctx, candidate, backfillHead)
+	if sent, ok := l.backrunSent(event.Base); ok {
+		if !allowed {
+			log.Printf("WARNING: %s was bought behind its trigger but fails the filters now that it trades, exit rules apply to buys %v", event.Base.Hex(), sent)
+		} else {
+			log.Printf("Token %s was already bought behind its trigger: %v", event.Base.Hex(), sent)
+		}
+		l.recordTrades(vLog, sent)
+		return
+	}
+	if !allowed {
 		return
 	}
 
-	if !isTax {
-		log.Printf("Token %s is NOT a TaxToken, skipping...", event.Base.Hex())
+	rule := l.ruleFor(info.CreatorType())
+	if rule == nil {
+		log.Printf("Token %s skipped: creator type %d matches no rule (allowed types: %s)", event.Base.Hex(), info.CreatorType(), l.allowedTypes())
 		return
 	}
+	log.Printf("Token %s matches the rule for creator type %d, proceeding to buy...", event.Base.Hex(), rule.TokenType)
+
+	if event.Quote != contracts.WBNB && event.Quote != (common.Address{}) {
+		log.Printf("Token %s is paired against %s, routing trades through it", event.Base.Hex(), event.Quote.Hex())
+		for _, w := range l.wallets {
+			w.Swapper.SetTokenQuote(event.Base, event.Quote)
+		}
+	}
+
+	l.applyTax(ctx, event.Base)
+	l.recordTrades(vLog, l.buyAll(ctx, event.Base, event.Quote, rule))
+}
+
+// runFilters runs the pre-buy pipeline and logs every decision; a nil
+// pipeline allows everything. Backfilled events past the freshness window are
+// journaled as skipped without running the filters.
+func (l *EventListener) runFilters(ctx context.Context, candidate *filter.Candidate, backfillHead uint64) bool {
+	if stale, behind := l.stale(candidate.Event.Log(), backfillHead); stale {
+		log.Printf("Token %s skipped: backfilled event is %d blocks old (fresh window %d)", candidate.Token.Hex(), behind, l.freshBlocks)
+		if l.filters != nil {
+			l.filters.Reject(candidate, filter.Decision{
+				Filter: "freshness",
+				Reason: fmt.Sprintf("backfilled %d blocks behind head, window is %d", behind, l.freshBlocks),
+			})
+		}
+		return false
+	}
+
+	if l.filters == nil {
+		return true
+	}
+
+	allowed, decisions := l.filters.Run(ctx, candidate)
+	for _, d := range decisions {
+		verdict := "pass"
+		if !d.Allow {
+			verdict = "DENY"
+		}
+		log.Printf("Filter %s [%s]: %s", d.Filter, verdict, d.Reason)
+	}
+	if !allowed {
+		log.Printf("Token %s skipped by filter %s", candidate.Token.Hex(), decisions[len(decisions)-1].Filter)
+	}
+	return allowed
+}
 
-	log.Printf("Token %s is a TaxToken, proceeding to buy...", event.Base.Hex())
+func (l *EventListener) ruleFor(tokenType int64) *TokenRule {
+	for i := range l.rules {
+		if l.rules[i].TokenType == tokenType {
+			return &l.rules[i]
+		}
+	}
+	return nil
+}
+
+func (l *EventListener) allowedTypes() string {
+	var types []string
+	for _, r := range l.rules {
+		types = append(types, strconv.FormatInt(r.TokenType, 10))
+	}
+	if len(types) == 0 {
+		return "none"
+	}
+	return strings.Join(types, ", ")
+}
 
+// buyFunc sends one wallet's buy and returns its hash.
+type buyFunc func(wallet WalletInfo, amountWei *big.Int) (string, error)
+
+// sentBuy is a buy that went out, with the wallet that sent it.
+type sentBuy struct {
+	wallet WalletInfo
+	index  int
+	hash   string
+}
+
+func sentHashes(sent []sentBuy) []string {
+	hashes := make([]string, len(sent))
+	for i, s := range sent {
+		hashes[i] = s.hash
+	}
+	return hashes
+}
+
+// buyAll buys the token from every wallet in parallel and hands confirmed
+// buys to the stop-loss monitor. A nil rule buys with the wallet defaults.
+// It returns the hashes of the buys that were sent; wallets that reach the
+// event deadline before sending skip the buy.
+func (l *EventListener) buyAll(ctx context.Context, tokenAddress, quote common.Address, rule *TokenRule) []string {
+	return sentHashes(l.buyEach(ctx, tokenAddress, quote, rule, func(wallet WalletInfo, amountWei *big.Int) (string, error) {
+		return wallet.Swapper.BuyToken(tokenAddress, amountWei)
+	}))
+}
+
+// buyEach is buyAll with the send left to buy.
+func (l *EventListener) buyEach(ctx context.Context, tokenAddress, quote common.Address, rule *TokenRule, buy buyFunc) []sentBuy {
+	var sent []sentBuy
+	var sentMu sync.Mutex
 	var wg sync.WaitGroup
 	for i, w := range l.wallets {
 		wg.Add(1)
 		go func(idx int, wallet WalletInfo) {
 			defer wg.Done()
-			log.Printf("[Wallet %d] Attempting to buy token %s with %s wei BNB...", idx+1, event.Base.Hex(), wallet.BuyAmountWei.String())
+			if ctx.Err() != nil {
+				log.Printf("[Wallet %d] Buy of %s skipped, event deadline passed", idx+1, tokenAddress.Hex())
+				return
+			}
+			buyAmountWei := l.buyAmount(idx, wallet, quote, rule)
+			if buyAmountWei == nil {
+				return
+			}
+			log.Printf("[Wallet %d] Attempting to buy token %s with %s BNB...", idx+1, tokenAddress.Hex(), contracts.BNB(buyAmountWei).String())
 
-			txHash, err := wallet.Swapper.BuyToken(event.Base, wallet.BuyAmountWei)
+			txHash, err := buy(wallet, buyAmountWei)
+			if errors.Is(err, contracts.ErrWouldFail) {
+				log.Printf("[Wallet %d] Buy skipped, transaction would fail: %v", idx+1, err)
+				return
+			}
 			if err != nil {
 				log.Printf("[Wallet %d] Failed to buy token: %v", idx+1, err)
 				return
 			}
 
 			log.Printf("[Wallet %d] Buy transaction sent! TX Hash: %s", idx+1, txHash)
+			sentMu.Lock()
+			sent = append(sent, sentBuy{wallet: wallet, index: idx, hash: txHash})
+			sentMu.Unlock()
 			log.Printf("[Wallet %d] BSCScan: https://bscscan.com/tx/%s", idx+1, txHash)
 
-			if l.stopLossMonitor != nil {
-				time.Sleep(5 * time.Second)
-				l.stopLossMonitor.AddPosition(idx, wallet.Swapper, event.Base, wallet.BuyAmountWei)
+			err = wallet.Swapper.OnTxDone(txHash, func(result contracts.TxResult) {
+				if !result.Success() {
+					log.Printf("[Wallet %d] Buy %s %s, no position opened", idx+1, txHash, result.Status)
+					return
+				}
+				log.Printf("[Wallet %d] Buy %s confirmed in block %s", idx+1, txHash, result.Receipt.BlockNumber.String())
+				if l.approveAfterBuy {
+					go preApprove(idx, wallet, tokenAddress)
+				}
+				if l.stopLossMonitor != nil {
+					l.stopLossMonitor.AddPosition(idx, wallet.Swapper, tokenAddress, buyAmountWei, rule.exit())
+				}
+			})
+			if err != nil {
+				log.Printf("[Wallet %d] Failed to watch buy %s: %v", idx+1, txHash, err)
 			}
 		}(i, w)
 	}
 	wg.Wait()
+	return sent
+}
+
+// cacheTax keeps the tax GetTokenInfo read for a tax token, so the tax filter
+// and applyTax do not read the getters again.
+func (l *EventListener) cacheTax(info *contracts.TokenInfo) {
+	if info.Tax != nil && l.taxes != nil {
+		l.taxes.Set(info.Tax)
+	}
+}
+
+// applyTax reads the token's on-chain tax and hands it to every wallet's
+// swapper, so minimum outputs and exit valuation use it. Tokens without tax
+// getters keep the default tax.
+func (l *EventListener) applyTax(ctx context.Context, tokenAddress common.Address) {
+	if l.taxes == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, tokenInfoTimeout)
+	defer cancel()
+
+	tax, err := l.taxes.Get(ctx, tokenAddress)
+	if errors.Is(err, contracts.ErrNoTaxGetters) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read tax of %s, using the default: %v", tokenAddress.Hex(), err)
+		return
+	}
+	l.setTax(tax)
+}
+
+func (l *EventListener) setTax(tax *contracts.TaxInfo) {
+	log.Printf("Token %s: %s", tax.Token.Hex(), tax.String())
+	for _, w := range l.wallets {
+		w.Swapper.SetTokenTax(tax.Token, tax.Tax())
+	}
+}
+
+// preApprove approves the wallet's whole balance of a freshly bought token.
+// The swapper skips it when the allowance already covers the balance.
+func preApprove(walletIndex int, wallet WalletInfo, tokenAddress common.Address) {
+	balance, err := wallet.Swapper.GetTokenBalance(tokenAddress)
+	if err != nil || balance.Sign() <= 0 {
+		log.Printf("[Wallet %d] Pre-approval of %s skipped, no balance (%v)", walletIndex+1, tokenAddress.Hex(), err)
+		return
+	}
+	txHash, err := wallet.Swapper.ApproveToken(tokenAddress, balance)
+	if err != nil {
+		log.Printf("[Wallet %d] Pre-approval of %s failed: %v", walletIndex+1, tokenAddress.Hex(), err)
+		return
+	}
+	if txHash != "" {
+		log.Printf("[Wallet %d] Pre-approval of %s sent: %s", walletIndex+1, tokenAddress.Hex(), txHash)
+	}
+}
+
+// buyAmount is the BNB to spend for one wallet. A configured amount for the
+// token's quote asset is converted to BNB at the current pool price; nil means
+// the conversion failed and the wallet should not buy.
+func (l *EventListener) buyAmount(walletIndex int, wallet WalletInfo, quote common.Address, rule *TokenRule) *big.Int {
+	quoteAmount, ok := l.quoteBuyAmounts[quote]
+	if !ok {
+		return rule.buyAmount(walletIndex, wallet)
+	}
+
+	amountWei, err := wallet.Swapper.GetTokenPrice(quote, quoteAmount)
+	if err != nil {
+		log.Printf("[Wallet %d] Failed to price %s of quote %s in BNB: %v", walletIndex+1, quoteAmount.String(), quote.Hex(), err)
+		return nil
+	}
+	log.Printf("[Wallet %d] Buy size for quote %s is %s BNB", walletIndex+1, quote.Hex(), contracts.BNB(amountWei).String())
+	return amountWei
 }
 
 func (l *EventListener) Close() {
-	l.client.Close()
+	for _, e := range l.endpoints {
+		e.close()
+	}
 }
@@ -0,0 +1,76 @@
+package listener
+
+import (
+	"math/big"
+	"testing"
+
+	"flap/stoploss"
+)
+
+func TestRuleFor(t *testing.T) {
+	defaultWei := big.NewInt(1e16)
+	wallets := []WalletInfo{{BuyAmountWei: defaultWei}, {BuyAmountWei: defaultWei}}
+	l := &EventListener{rules: []TokenRule{
+		{
+			TokenType:     5,
+			BuyAmountsWei: []*big.Int{big.NewInt(5e16), nil},
+			Exit:          stoploss.ExitRule{StopLossPercent: 20},
+		},
+		{
+			TokenType: 7,
+			Exit:      stoploss.ExitRule{TakeProfitPriceUSDT: 0.5, TakeProfitSellPercent: 50},
+		},
+	}}
+
+	tests := []struct {
+		name      string
+		tokenType int64
+		wantRule  bool
+		wantBuys  []*big.Int
+		wantExit  stoploss.ExitRule
+	}{
+		{
+			name:      "own amount for one wallet, default for the other",
+			tokenType: 5,
+			wantRule:  true,
+			wantBuys:  []*big.Int{big.NewInt(5e16), defaultWei},
+			wantExit:  stoploss.ExitRule{StopLossPercent: 20},
+		},
+		{
+			name:      "no amounts uses the wallet defaults",
+			tokenType: 7,
+			wantRule:  true,
+			wantBuys:  []*big.Int{defaultWei, defaultWei},
+			wantExit:  stoploss.ExitRule{TakeProfitPriceUSDT: 0.5, TakeProfitSellPercent: 50},
+		},
+		{
+			name:      "type without a rule",
+			tokenType: 6,
+			wantBuys:  []*big.Int{defaultWei, defaultWei},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := l.ruleFor(tt.tokenType)
+			if (rule != nil) != tt.wantRule {
+				t.Fatalf("ruleFor(%d) = %+v, want a rule: %v", tt.tokenType, rule, tt.wantRule)
+			}
+			if rule != nil && rule.TokenType != tt.tokenType {
+				t.Errorf("ruleFor(%d) returned the rule for %d", tt.tokenType, rule.TokenType)
+			}
+			for i, w := range wallets {
+				if got := rule.buyAmount(i, w); got.Cmp(tt.wantBuys[i]) != 0 {
+					t.Errorf("wallet %d buys %s, want %s", i, got, tt.wantBuys[i])
+				}
+			}
+			if exit := rule.exit(); exit != tt.wantExit {
+				t.Errorf("exit() = %+v, want %+v", exit, tt.wantExit)
+			}
+		})
+	}
+
+	if got := l.allowedTypes(); got != "5, 7" {
+		t.Errorf("allowedTypes() = %q, want %q", got, "5, 7")
+	}
+}
@@ -0,0 +1,298 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"flap/contracts"
+	"flap/filter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	receiptPollInterval = 200 * time.Millisecond
+	triggerTimeout      = time.Minute
+)
+
+// mempoolWatcher looks for pending TokenManager buys that will empty a curve
+// and so trigger LiquidityAdded. As soon as one is seen, the launch it will
+// cause goes through the filters and token rules and every wallet buys behind
+// the trigger at the trigger's own fees, priced from the pool the launch is
+// expected to seed, so the buys land in the trigger's block or the next one.
+// The trigger's receipt is then polled only to confirm or cancel: a launch
+// feeds its logs into the normal delivery path, where the buys are recorded
+// against them; a revert, a mined trigger without a launch or a timeout
+// cancels the buys still pending.
+type mempoolWatcher struct {
+	l        *EventListener
+	url      string
+	watching map[common.Hash]bool
+	mu       sync.Mutex
+}
+
+func newMempoolWatcher(l *EventListener, wsURL string) *mempoolWatcher {
+	return &mempoolWatcher{l: l, url: wsURL, watching: make(map[common.Hash]bool)}
+}
+
+func (m *mempoolWatcher) run(ctx context.Context) {
+	name := redactURL(m.url)
+	for {
+		err := m.subscribe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[mempool %s] Pending subscription ended: %v", name, err)
+		select {
+		case <-time.After(reconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// subscribe prefers full pending transactions and falls back to hashes for
+// nodes that only support the plain newPendingTransactions subscription.
+func (m *mempoolWatcher) subscribe(ctx context.Context) error {
+	client, err := ethclient.Dial(m.url)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer client.Close()
+	rpcClient := client.Client()
+
+	txs := make(chan *types.Transaction, 256)
+	sub, err := rpcClient.EthSubscribe(ctx, txs, "newPendingTransactions", true)
+	if err == nil {
+		defer sub.Unsubscribe()
+		log.Printf("[mempool %s] Watching full pending transactions to %s", redactURL(m.url), m.l.contractAddress.Hex())
+		for {
+			select {
+			case err := <-sub.Err():
+				return err
+			case tx := <-txs:
+				m.inspect(ctx, tx)
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+
+	log.Printf("[mempool %s] Full pending transactions unsupported (%v), watching hashes", redactURL(m.url), err)
+	hashes := make(chan common.Hash, 1024)
+	sub, err = rpcClient.EthSubscribe(ctx, hashes, "newPendingTransactions")
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to pending transactions: %w", err)
+	}
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case err := <-sub.Err():
+			return err
+		case hash := <-hashes:
+			tx, _, err := client.TransactionByHash(ctx, hash)
+			if err != nil {
+				continue
+			}
+			m.inspect(ctx, tx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *mempoolWatcher) inspect(ctx context.Context, tx *types.Transaction) {
+	if tx.To() == nil || *tx.To() != m.l.contractAddress {
+		return
+	}
+	buy, err := contracts.DecodeManagerBuy(tx.Data())
+	if err != nil || buy == nil {
+		return
+	}
+
+	m.mu.Lock()
+	if m.watching[tx.Hash()] {
+		m.mu.Unlock()
+		return
+	}
+	m.watching[tx.Hash()] = true
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.watching, tx.Hash())
+			m.mu.Unlock()
+		}()
+
+		infoCtx, cancel := context.WithTimeout(ctx, tokenInfoTimeout)
+		info, err := contracts.GetTokenInfo(infoCtx, m.l.httpClient, buy.Token)
+		cancel()
+		if err != nil || !info.WouldLaunch(buy) {
+			return
+		}
+
+		log.Printf("[mempool] Pending %s %s would launch %s", buy.Method, tx.Hash().Hex(), buy.Token.Hex())
+		sent, ok := m.backrun(ctx, tx, buy, info)
+		if !ok {
+			return
+		}
+		m.awaitTrigger(ctx, tx.Hash(), buy.Token, sent)
+	}()
+}
+
+// backrun runs the pending launch through the same filters and rules as a
+// mined one and buys behind the trigger. It reports false when nothing was
+// sent, leaving the token to the normal path.
+func (m *mempoolWatcher) backrun(ctx context.Context, trigger *types.Transaction, buy *contracts.ManagerBuy, info *contracts.TokenInfo) ([]sentBuy, bool) {
+	l := m.l
+	ctx, cancel := context.WithTimeout(ctx, l.eventDeadline)
+	defer cancel()
+
+	l.cacheTax(info)
+	pool := info.LaunchPool()
+	candidate := &filter.Candidate{
+		Event:   contracts.NewPendingLaunchEvent(trigger, buy, info),
+		Token:   buy.Token,
+		Quote:   info.Quote,
+		Offers:  pool.Tokens,
+		Funds:   pool.Funds,
+		Info:    info,
+		Pending: true,
+		Client:  l.httpClient,
+	}
+	if !l.runFilters(ctx, candidate, 0) {
+		return nil, false
+	}
+	rule := l.ruleFor(info.CreatorType())
+	if rule == nil {
+		log.Printf("[mempool] Token %s skipped: creator type %d matches no rule (allowed types: %s)", buy.Token.Hex(), info.CreatorType(), l.allowedTypes())
+		return nil, false
+	}
+	if !l.claimBackrun(buy.Token) {
+		return nil, false
+	}
+
+	if info.Quote != contracts.WBNB && info.Quote != (common.Address{}) {
+		for _, w := range l.wallets {
+			w.Swapper.SetTokenQuote(buy.Token, info.Quote)
+		}
+	}
+	l.applyTax(ctx, buy.Token)
+
+	log.Printf("[mempool] Buying %s behind trigger %s (expected pool %s tokens / %s funds)", buy.Token.Hex(), trigger.Hash().Hex(), pool.Tokens.String(), pool.Funds.String())
+	sent := l.buyEach(ctx, buy.Token, info.Quote, rule, func(wallet WalletInfo, amountWei *big.Int) (string, error) {
+		return wallet.Swapper.BackrunBuy(trigger, buy.Token, amountWei, pool)
+	})
+	if len(sent) == 0 {
+		l.releaseBackrun(buy.Token)
+		return nil, false
+	}
+	l.recordBackrun(buy.Token, sentHashes(sent))
+	return sent, true
+}
+
+// awaitTrigger polls for the trigger's receipt. A launch is delivered like
+// any other log so the buys are recorded against it; anything else cancels
+// the buys that are still pending and releases the token.
+func (m *mempoolWatcher) awaitTrigger(ctx context.Context, hash common.Hash, tokenAddress common.Address, sent []sentBuy) {
+	ctx, cancel := context.WithTimeout(ctx, triggerTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[mempool] Trigger %s not mined within %s", hash.Hex(), triggerTimeout)
+			m.cancel(tokenAddress, sent)
+			return
+		case <-ticker.C:
+			receipt, err := m.l.httpClient.TransactionReceipt(ctx, hash)
+			if err != nil {
+				continue
+			}
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				log.Printf("[mempool] Trigger %s reverted", hash.Hex())
+				m.cancel(tokenAddress, sent)
+				return
+			}
+
+			var launches []types.Log
+			for _, vLog := range receipt.Logs {
+				if m.l.matches(*vLog) {
+					launches = append(launches, *vLog)
+				}
+			}
+			if len(launches) == 0 {
+				log.Printf("[mempool] Trigger %s mined in block %s without a launch", hash.Hex(), receipt.BlockNumber.String())
+				m.cancel(tokenAddress, sent)
+				return
+			}
+
+			log.Printf("[mempool] Trigger %s launched %s in block %s, %d buy(s) behind it", hash.Hex(), tokenAddress.Hex(), receipt.BlockNumber.String(), len(sent))
+			for _, vLog := range launches {
+				select {
+				case m.l.deliveries <- delivery{vLog: vLog, at: time.Now()}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			return
+		}
+	}
+}
+
+// cancel replaces the buys that have not been mined with cancellations and
+// lets the normal path buy the token should it launch later.
+func (m *mempoolWatcher) cancel(tokenAddress common.Address, sent []sentBuy) {
+	for _, s := range sent {
+		newHash, err := s.wallet.Swapper.CancelTx(s.hash)
+		if err != nil {
+			log.Printf("[Wallet %d] Backrun buy %s not cancelled: %v", s.index+1, s.hash, err)
+			continue
+		}
+		log.Printf("[Wallet %d] Backrun buy %s cancelled by %s", s.index+1, s.hash, newHash)
+	}
+	m.l.releaseBackrun(tokenAddress)
+}
+
+// claimBackrun reserves the token for one trigger, so two pending buys that
+// would both launch it do not buy twice.
+func (l *EventListener) claimBackrun(tokenAddress common.Address) bool {
+	l.backrunMu.Lock()
+	defer l.backrunMu.Unlock()
+	if _, ok := l.backruns[tokenAddress]; ok {
+		return false
+	}
+	l.backruns[tokenAddress] = nil
+	return true
+}
+
+func (l *EventListener) recordBackrun(tokenAddress common.Address, hashes []string) {
+	l.backrunMu.Lock()
+	defer l.backrunMu.Unlock()
+	l.backruns[tokenAddress] = hashes
+}
+
+func (l *EventListener) releaseBackrun(tokenAddress common.Address) {
+	l.backrunMu.Lock()
+	defer l.backrunMu.Unlock()
+	delete(l.backruns, tokenAddress)
+}
+
+// backrunSent returns the buys sent behind the token's trigger, if the
+// mempool watcher has claimed it.
+func (l *EventListener) backrunSent(tokenAddress common.Address) ([]string, bool) {
+	l.backrunMu.Lock()
+	defer l.backrunMu.Unlock()
+	hashes, ok := l.backruns[tokenAddress]
+	return hashes, ok
+}
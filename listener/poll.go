@@ -0,0 +1,94 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+)
+
+// setHealth tells the listener whether an endpoint's subscription is live.
+func (l *EventListener) setHealth(ctx context.Context, e *endpoint, up bool) {
+	select {
+	case l.health <- endpointHealth{source: e, up: up}:
+	case <-ctx.Done():
+	}
+}
+
+func (l *EventListener) startPolling(ctx context.Context) context.CancelFunc {
+	pollCtx, cancel := context.WithCancel(ctx)
+	go l.poll(pollCtx)
+	return cancel
+}
+
+// poll ingests logs over HTTP while no WebSocket endpoint is up. It first
+// backfills the gap since the last processed log, then runs eth_getLogs for
+// every new block and feeds the results through the same delivery path.
+func (l *EventListener) poll(ctx context.Context) {
+	log.Printf("No WebSocket endpoint is up, polling HTTP RPC every %s", l.pollInterval)
+
+	var next uint64
+	for next == 0 {
+		head, err := l.backfill(ctx)
+		if err == nil {
+			next = head + 1
+			break
+		}
+		log.Printf("Polling backfill failed: %v", err)
+		select {
+		case <-time.After(l.pollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	ticker := time.NewTicker(l.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			head, err := l.pollOnce(ctx, next)
+			if err != nil {
+				log.Printf("Polling failed: %v", err)
+				continue
+			}
+			next = head + 1
+		}
+	}
+}
+
+// pollOnce fetches the logs of blocks from..head and returns head. Nothing is
+// fetched until the chain has moved past from.
+func (l *EventListener) pollOnce(ctx context.Context, from uint64) (uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	head, err := l.httpClient.BlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get head block: %w", err)
+	}
+	if head < from {
+		return from - 1, nil
+	}
+
+	query := l.query()
+	query.FromBlock = new(big.Int).SetUint64(from)
+	query.ToBlock = new(big.Int).SetUint64(head)
+	logs, err := l.httpClient.FilterLogs(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to filter logs %d-%d: %w", from, head, err)
+	}
+
+	for _, vLog := range logs {
+		select {
+		case l.deliveries <- delivery{vLog: vLog, at: time.Now()}:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	return head, nil
+}
@@ -0,0 +1,162 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"flap/contracts"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type filterArgs struct {
+	FromBlock *hexutil.Big     `json:"fromBlock"`
+	ToBlock   *hexutil.Big     `json:"toBlock"`
+	Addresses []common.Address `json:"address"`
+	Topics    [][]common.Hash  `json:"topics"`
+}
+
+// pollNode answers eth_blockNumber and eth_getLogs from a fixed set of logs.
+type pollNode struct {
+	head     uint64
+	logs     []types.Log
+	failLogs bool
+	queries  []filterArgs
+}
+
+func (n *pollNode) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(n.head)
+}
+
+func (n *pollNode) GetLogs(args filterArgs) ([]types.Log, error) {
+	n.queries = append(n.queries, args)
+	if n.failLogs {
+		return nil, errors.New("limit exceeded")
+	}
+	from, to := args.FromBlock.ToInt().Uint64(), args.ToBlock.ToInt().Uint64()
+	logs := []types.Log{}
+	for _, vLog := range n.logs {
+		if vLog.BlockNumber >= from && vLog.BlockNumber <= to {
+			logs = append(logs, vLog)
+		}
+	}
+	return logs, nil
+}
+
+func TestPollOnce(t *testing.T) {
+	contract := common.HexToAddress("0x5c952063c7fc8610FFDB798152D69F0B9550762b")
+	portal := common.HexToAddress("0xe2cE6ab80874Fa9Fa2aAE65D277Dd6B8e65C9De0")
+	topics, err := contracts.EventTopics([]string{contracts.EventLiquidityAdded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := []types.Log{at(99, 0), at(100, 1), at(103, 0), at(106, 2)}
+	for i := range logs {
+		logs[i].Address = contract
+		logs[i].Topics = topics
+		logs[i].Data = []byte{}
+	}
+
+	tests := []struct {
+		name      string
+		head      uint64
+		from      uint64
+		failLogs  bool
+		wantHead  uint64
+		wantErr   bool
+		wantQuery bool
+		wantLogs  []types.Log
+	}{
+		{
+			name:     "chain has not moved past from",
+			head:     99,
+			from:     100,
+			wantHead: 99,
+		},
+		{
+			name:      "logs from..head are delivered",
+			head:      105,
+			from:      100,
+			wantHead:  105,
+			wantQuery: true,
+			wantLogs:  []types.Log{logs[1], logs[2]},
+		},
+		{
+			name:      "failed eth_getLogs",
+			head:      105,
+			from:      100,
+			failLogs:  true,
+			wantErr:   true,
+			wantQuery: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &pollNode{head: tt.head, logs: logs, failLogs: tt.failLogs}
+			server := rpc.NewServer()
+			if err := server.RegisterName("eth", node); err != nil {
+				t.Fatal(err)
+			}
+			client := ethclient.NewClient(rpc.DialInProc(server))
+			defer server.Stop()
+			defer client.Close()
+
+			l := &EventListener{
+				httpClient:      client,
+				deliveries:      make(chan delivery, len(logs)),
+				contractAddress: contract,
+				portalAddress:   portal,
+				topics:          topics,
+			}
+			head, err := l.pollOnce(context.Background(), tt.from)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if head != tt.wantHead {
+				t.Errorf("head = %d, want %d", head, tt.wantHead)
+			}
+
+			if !tt.wantQuery {
+				if len(node.queries) != 0 {
+					t.Fatalf("queried logs %+v before the chain moved", node.queries)
+				}
+			} else {
+				if len(node.queries) != 1 {
+					t.Fatalf("%d log queries, want 1", len(node.queries))
+				}
+				q := node.queries[0]
+				if q.FromBlock.ToInt().Cmp(new(big.Int).SetUint64(tt.from)) != 0 || q.ToBlock.ToInt().Cmp(new(big.Int).SetUint64(tt.head)) != 0 {
+					t.Errorf("queried blocks %s-%s, want %d-%d", q.FromBlock, q.ToBlock, tt.from, tt.head)
+				}
+				if !reflect.DeepEqual(q.Addresses, []common.Address{contract, portal}) || !reflect.DeepEqual(q.Topics, [][]common.Hash{topics}) {
+					t.Errorf("queried %v %v, want the subscription's addresses and topics", q.Addresses, q.Topics)
+				}
+			}
+
+			close(l.deliveries)
+			var got []types.Log
+			for d := range l.deliveries {
+				got = append(got, d.vLog)
+			}
+			if len(got) != len(tt.wantLogs) {
+				t.Fatalf("delivered %d logs, want %d", len(got), len(tt.wantLogs))
+			}
+			for i := range got {
+				if got[i].BlockNumber != tt.wantLogs[i].BlockNumber || got[i].Index != tt.wantLogs[i].Index {
+					t.Errorf("delivery %d is %d:%d, want %d:%d", i, got[i].BlockNumber, got[i].Index, tt.wantLogs[i].BlockNumber, tt.wantLogs[i].Index)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	defaultWorkers       = 4
+	defaultQueueSize     = 256
+	defaultEventDeadline = 10 * time.Second
+)
+
+// job is a log waiting for a worker. deadline is fixed when the log is
+// queued, so time spent waiting counts against it.
+type job struct {
+	vLog         types.Log
+	backfillHead uint64
+	deadline     time.Time
+}
+
+// startWorkers runs the pool that handles events off the delivery goroutine,
+// so a slow token lookup or buy never holds up the next launch.
+func (l *EventListener) startWorkers(ctx context.Context) {
+	for i := 0; i < l.workers; i++ {
+		go func() {
+			for {
+				select {
+				case j := <-l.jobs:
+					l.runJob(ctx, j)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	log.Printf("Handling events with %d workers (queue %d, deadline %s)", l.workers, cap(l.jobs), l.eventDeadline)
+}
+
+// enqueue hands a log to the pool. A full queue drops the log rather than
+// blocking delivery; the drop is journaled like any other skip.
+func (l *EventListener) enqueue(vLog types.Log, backfillHead uint64) {
+	select {
+	case l.jobs <- job{vLog: vLog, backfillHead: backfillHead, deadline: time.Now().Add(l.eventDeadline)}:
+		return
+	default:
+	}
+
+	l.seen.finish(vLog)
+	log.Printf("Event queue full (%d), dropping %s", cap(l.jobs), logKey(vLog))
+	l.reject(vLog, "queue", fmt.Sprintf("event queue full (%d)", cap(l.jobs)))
+}
+
+func (l *EventListener) runJob(ctx context.Context, j job) {
+	ctx, cancel := context.WithDeadline(ctx, j.deadline)
+	defer cancel()
+	defer l.seen.finish(j.vLog)
+
+	if ctx.Err() != nil {
+		log.Printf("Event %s expired in the queue, skipping", logKey(j.vLog))
+		l.reject(j.vLog, "deadline", "expired before a worker picked it up")
+		return
+	}
+	l.process(ctx, j.vLog, j.backfillHead)
+}
@@ -0,0 +1,200 @@
+package listener
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"flap/contracts"
+	"flap/filter"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// blockingFilter holds the worker that runs it until release is closed, or
+// until the job's deadline when honorDeadline is set.
+type blockingFilter struct {
+	entered       chan common.Address
+	release       chan struct{}
+	honorDeadline bool
+}
+
+func (f *blockingFilter) Name() string {
+	return "block"
+}
+
+func (f *blockingFilter) Check(ctx context.Context, c *filter.Candidate) filter.Decision {
+	f.entered <- c.Token
+	if !f.honorDeadline {
+		<-f.release
+		return filter.Decision{Filter: f.Name(), Reason: "released"}
+	}
+	select {
+	case <-f.release:
+		return filter.Decision{Filter: f.Name(), Reason: "released"}
+	case <-ctx.Done():
+		return filter.Decision{Filter: f.Name(), Reason: ctx.Err().Error()}
+	}
+}
+
+type journalLine struct {
+	Token     string            `json:"token"`
+	Decisions []filter.Decision `json:"decisions"`
+}
+
+func readJournal(t *testing.T, path string) map[common.Address]filter.Decision {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := make(map[common.Address]filter.Decision)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line journalLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("journal line %q: %v", scanner.Text(), err)
+		}
+		lines[common.HexToAddress(line.Token)] = line.Decisions[len(line.Decisions)-1]
+	}
+	return lines
+}
+
+// tokenCreatedLog builds a TokenCreated log for token, which curve mode takes
+// straight to the filters without any RPC.
+func tokenCreatedLog(t *testing.T, token common.Address) types.Log {
+	t.Helper()
+	parsed, err := abi.JSON(strings.NewReader(contracts.EventsABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := parsed.Events[contracts.EventTokenCreated]
+	data, err := event.Inputs.Pack(big.NewInt(1), common.Address{}, big.NewInt(1), token, "Test", "TEST", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.Log{
+		Topics:      []common.Hash{event.ID},
+		Data:        data,
+		TxHash:      common.BytesToHash(token.Bytes()),
+		BlockNumber: 100,
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	tokens := []common.Address{
+		common.HexToAddress("0x00000000000000000000000000000000000000a1"),
+		common.HexToAddress("0x00000000000000000000000000000000000000a2"),
+		common.HexToAddress("0x00000000000000000000000000000000000000a3"),
+	}
+
+	tests := []struct {
+		name          string
+		deadline      time.Duration
+		logs          int
+		honorDeadline bool
+		release       bool
+		hold          time.Duration
+		want          []filter.Decision
+	}{
+		{
+			name:     "full queue drops the event and journals it",
+			deadline: time.Minute,
+			logs:     3,
+			release:  true,
+			want: []filter.Decision{
+				{Filter: "block", Reason: "released"},
+				{Filter: "block", Reason: "released"},
+				{Filter: "queue", Reason: "event queue full (1)"},
+			},
+		},
+		{
+			name:          "deadline cancels the running job",
+			deadline:      20 * time.Millisecond,
+			logs:          1,
+			honorDeadline: true,
+			want: []filter.Decision{
+				{Filter: "block", Reason: context.DeadlineExceeded.Error()},
+			},
+		},
+		{
+			name:     "deadline passes while the job is queued",
+			deadline: 20 * time.Millisecond,
+			logs:     2,
+			release:  true,
+			hold:     60 * time.Millisecond,
+			want: []filter.Decision{
+				{Filter: "block", Reason: "released"},
+				{Filter: "deadline", Reason: "expired before a worker picked it up"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "decisions.jsonl")
+			journal, err := filter.OpenJournal(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer journal.Close()
+
+			block := &blockingFilter{
+				entered:       make(chan common.Address, len(tokens)),
+				release:       make(chan struct{}),
+				honorDeadline: tt.honorDeadline,
+			}
+			seen, err := loadSeenLogs("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			l := &EventListener{
+				mode:          ModeCurve,
+				jobs:          make(chan job, 1),
+				workers:       1,
+				eventDeadline: tt.deadline,
+				seen:          seen,
+				filters:       filter.NewPipeline(journal, block),
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			l.startWorkers(ctx)
+
+			l.enqueue(tokenCreatedLog(t, tokens[0]), 0)
+			select {
+			case <-block.entered:
+			case <-time.After(time.Second):
+				t.Fatal("worker did not pick up the first event")
+			}
+			for _, token := range tokens[1:tt.logs] {
+				l.enqueue(tokenCreatedLog(t, token), 0)
+			}
+			if tt.release {
+				time.Sleep(tt.hold)
+				close(block.release)
+			}
+
+			var got map[common.Address]filter.Decision
+			for stop := time.Now().Add(time.Second); time.Now().Before(stop); time.Sleep(5 * time.Millisecond) {
+				if got = readJournal(t, path); len(got) == len(tt.want) {
+					break
+				}
+			}
+			for i, want := range tt.want {
+				if d := got[tokens[i]]; d.Filter != want.Filter || d.Reason != want.Reason || d.Allow {
+					t.Errorf("event %d journaled %+v, want %+v", i, d, want)
+				}
+			}
+		})
+	}
+}
@@ -4,24 +4,30 @@ import (
 	"context"
 	"flap/config"
 	"flap/contracts"
+	"flap/filter"
 	"flap/listener"
 	"flap/stoploss"
+	"fmt"
 	"log"
 	"math/big"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	if len(cfg.Wallets) == 0 {
 		log.Fatal("PRIVATE_KEYS is required")
 	}
-	if cfg.ContractAddress == "" {
+	if cfg.TradingMode == listener.ModeDEX && cfg.ContractAddress == "" {
 		log.Fatal("CONTRACT_ADDRESS is required")
 	}
 
@@ -33,23 +39,71 @@ func main() {
 	}
 	defer httpClient.Close()
 
+	routerAddress := common.HexToAddress(cfg.RouterAddress)
+	factoryAddress := common.HexToAddress(cfg.FactoryAddress)
+
+	metadata := contracts.NewMetadataService(httpClient)
+	taxes := contracts.NewTaxReader(httpClient)
+
+	nonceManager := contracts.NewNonceManager(httpClient)
+	txTracker := contracts.NewTxTracker(httpClient, cfg.TxConfirmations, cfg.TxDropTimeout)
+	go txTracker.Start()
+
+	gasCfg := contracts.GasStrategyConfig{
+		GasPriceGwei:         cfg.GasPriceGwei,
+		SuggestMultiplier:    cfg.GasSuggestMultiplier,
+		FeeHistoryBlocks:     cfg.GasFeeHistoryBlocks,
+		FeeHistoryPercentile: cfg.GasFeeHistoryPercentile,
+		TipGwei:              cfg.GasTipGwei,
+		BaseFeeMultiplier:    cfg.GasBaseFeeMultiplier,
+	}
+
+	var routeFinder *contracts.RouteFinder
+	if cfg.RouteFinder {
+		routeFinder = contracts.NewRouteFinder(httpClient, routerAddress, factoryAddress, hexAddresses(cfg.RouteBaseTokens), cfg.RouteRepricePercent)
+		log.Printf("V2 route finder enabled, re-routing after %.0f%% reserve moves", cfg.RouteRepricePercent)
+	}
+
 	var wallets []listener.WalletInfo
 	for i, w := range cfg.Wallets {
-		swapper, err := contracts.NewPancakeSwapper(
-			httpClient,
-			w.PrivateKey,
-			cfg.GasLimit,
-			cfg.GasPriceGwei,
-			cfg.Slippage,
-		)
+		gasStrategies, err := buildGasStrategies(httpClient, w, gasCfg)
+		if err != nil {
+			log.Fatalf("Invalid gas strategy for wallet %d: %v", i+1, err)
+		}
+
+		swapperCfg := contracts.SwapperConfig{
+			Router:  routerAddress,
+			Factory: factoryAddress,
+			Routes:  routeFinder,
+			GasLimits: contracts.GasLimitConfig{
+				Default:    cfg.GasLimit,
+				Multiplier: cfg.GasLimitMultiplier,
+				Floor:      cfg.GasLimitFloor,
+				Ceiling:    cfg.GasLimitCeiling,
+				FastBuy:    cfg.FastBuyGasLimit,
+			},
+			GasStrategies:     gasStrategies,
+			Slippage:          cfg.Slippage,
+			DefaultTaxPercent: cfg.DefaultTaxPercent,
+			GasBumpPercents:   cfg.GasBumpPercents,
+			MaxGasPriceGwei:   cfg.MaxGasPriceGwei,
+		}
+
+		swapper, err := buildSwapper(httpClient, nonceManager, txTracker, w.PrivateKey, swapperCfg, cfg)
 		if err != nil {
 			log.Fatalf("Failed to create swapper for wallet %d: %v", i+1, err)
 		}
+		if cfg.TradingMode == listener.ModeCurve {
+			swapper, err = contracts.NewCurveSwapper(httpClient, nonceManager, txTracker, w.PrivateKey, common.HexToAddress(cfg.FlapPortalAddress), swapper, swapperCfg)
+			if err != nil {
+				log.Fatalf("Failed to create curve swapper for wallet %d: %v", i+1, err)
+			}
+		}
 
-		buyAmountWei := new(big.Int)
-		w.BuyAmountBNB.Mul(w.BuyAmountBNB, big.NewFloat(1e18)).Int(buyAmountWei)
+		buyAmountWei := bnbToWei(w.BuyAmountBNB)
 
-		log.Printf("Wallet %d: %s (Buy: %s BNB)", i+1, swapper.GetAddress().Hex(), w.BuyAmountBNB.String())
+		log.Printf("Wallet %d: %s (Buy: %s BNB, gas buy/sell/approve: %s/%s/%s)", i+1, swapper.GetAddress().Hex(), w.BuyAmountBNB.String(),
+			gasStrategies.Buy.Name(), gasStrategies.Sell.Name(), gasStrategies.Approve.Name())
 		wallets = append(wallets, listener.WalletInfo{
 			Swapper:      swapper,
 			BuyAmountWei: buyAmountWei,
@@ -58,17 +112,112 @@ func main() {
 
 	var stopLossMonitor *stoploss.StopLossMonitor
 	if cfg.EnableStopLoss {
-		stopLossMonitor = stoploss.NewStopLossMonitor(cfg.StopLossPercent)
+		stopLossMonitor = stoploss.NewStopLossMonitor(cfg.StopLossPercent, cfg.SellEscalateBlocks, metadata)
 		go stopLossMonitor.Start()
 		log.Printf("Stop-loss enabled: %d%% threshold", cfg.StopLossPercent)
 	}
 
+	var honeypotChecker *contracts.HoneypotChecker
+	if cfg.HoneypotCheck {
+		simAmountWei := bnbToWei(cfg.HoneypotSimAmountBNB)
+		honeypotChecker = contracts.NewHoneypotChecker(httpClient, routerAddress, simAmountWei, cfg.HoneypotMaxLossPercent)
+		log.Printf("Honeypot check enabled: %s BNB simulated, max loss %.2f%%", cfg.HoneypotSimAmountBNB.String(), cfg.HoneypotMaxLossPercent)
+	}
+
+	var rules []listener.TokenRule
+	var tokenTypes []int64
+	for _, r := range cfg.TokenTypeRules {
+		rule := listener.TokenRule{
+			TokenType: r.TokenType,
+			Exit: stoploss.ExitRule{
+				StopLossPercent:       r.StopLossPercent,
+				TakeProfitPriceUSDT:   r.TakeProfitPriceUSDT,
+				TakeProfitSellPercent: r.TakeProfitSellPercent,
+			},
+		}
+		for i := range wallets {
+			var amountWei *big.Int
+			if len(r.BuyAmountsBNB) > 0 {
+				idx := i
+				if idx >= len(r.BuyAmountsBNB) {
+					idx = len(r.BuyAmountsBNB) - 1
+				}
+				if r.BuyAmountsBNB[idx] != nil {
+					amountWei = bnbToWei(r.BuyAmountsBNB[idx])
+				}
+			}
+			rule.BuyAmountsWei = append(rule.BuyAmountsWei, amountWei)
+		}
+		rules = append(rules, rule)
+		tokenTypes = append(tokenTypes, r.TokenType)
+		log.Printf("Token type %d allowed (buy amounts: %v, exit: %+v)", r.TokenType, r.BuyAmountsBNB, rule.Exit)
+	}
+
+	quoteBuyAmounts := make(map[common.Address]*big.Int)
+	for address, amount := range cfg.QuoteBuyAmounts {
+		if !common.IsHexAddress(address) {
+			log.Printf("Warning: ignoring invalid quote token %q", address)
+			continue
+		}
+		quote, err := metadata.Get(context.Background(), common.HexToAddress(address))
+		if err != nil {
+			log.Fatalf("Failed to read quote token %s: %v", address, err)
+		}
+		quoteBuyAmounts[quote.Address] = toUnits(amount, quote.Decimals)
+		log.Printf("Launches quoted in %s buy %s", address, quote.Format(quoteBuyAmounts[quote.Address]))
+	}
+
+	journal, err := filter.OpenJournal(cfg.DecisionJournalFile)
+	if err != nil {
+		log.Fatalf("Failed to open decision journal: %v", err)
+	}
+	defer journal.Close()
+
+	pipeline, err := filter.Build(cfg.Filters, filter.Settings{
+		MinFunds:         cfg.FilterMinFunds,
+		MaxFunds:         cfg.FilterMaxFunds,
+		MinOffers:        cfg.FilterMinOffers,
+		MaxOffers:        cfg.FilterMaxOffers,
+		Metadata:         metadata,
+		QuoteTokens:      hexAddresses(cfg.FilterQuoteTokens),
+		CreatorBlacklist: hexAddresses(cfg.FilterCreatorBlacklist),
+		TokenTypes:       tokenTypes,
+		Honeypot:         honeypotChecker,
+		Taxes:            taxes,
+		MaxBuyTaxBps:     int64(cfg.FilterMaxBuyTaxPercent * 100),
+		MaxSellTaxBps:    int64(cfg.FilterMaxSellTaxPercent * 100),
+	}, journal)
+	if err != nil {
+		log.Fatalf("Failed to build filter pipeline: %v", err)
+	}
+	log.Printf("Pre-buy filters: %v (decisions recorded to %s)", pipeline.Names(), cfg.DecisionJournalFile)
+
 	eventListener, err := listener.NewEventListener(
-		cfg.BSCRPCURL,
-		cfg.ContractAddress,
+		listener.Config{
+			WSURLs:            cfg.BSCRPCURLs,
+			ContractAddress:   cfg.ContractAddress,
+			PortalAddress:     cfg.FlapPortalAddress,
+			Mode:              cfg.TradingMode,
+			Events:            cfg.ListenEvents,
+			Rules:             rules,
+			FreshBlocks:       cfg.BackfillFreshBlocks,
+			MaxBackfillBlocks: cfg.BackfillMaxBlocks,
+			ConfirmDepth:      cfg.ConfirmDepth,
+			SeenLogsFile:      cfg.SeenLogsFile,
+			MaxLag:            cfg.WSMaxLag,
+			PollInterval:      cfg.PollInterval,
+			MempoolWSURL:      cfg.MempoolWSURL,
+			Workers:           cfg.EventWorkers,
+			QueueSize:         cfg.EventQueueSize,
+			EventDeadline:     cfg.EventDeadline,
+			QuoteBuyAmounts:   quoteBuyAmounts,
+			Taxes:             taxes,
+			ApproveAfterBuy:   cfg.ApproveAfterBuy,
+		},
 		wallets,
 		stopLossMonitor,
 		httpClient,
+		pipeline,
 	)
 	if err != nil {
 		log.Fatalf("Failed to create event listener: %v", err)
@@ -87,6 +236,7 @@ func main() {
 		if stopLossMonitor != nil {
 			stopLossMonitor.Stop()
 		}
+		txTracker.Stop()
 		cancel()
 	}()
 
@@ -97,3 +247,76 @@ func main() {
 
 	log.Println("Goodbye!")
 }
+
+// buildSwapper creates one swapper per configured venue and, when there is
+// more than one, lets VenueSwapper pick between them per token.
+func buildSwapper(client *ethclient.Client, nonces *contracts.NonceManager, tracker *contracts.TxTracker, privateKey string, swapperCfg contracts.SwapperConfig, cfg *config.Config) (contracts.Swapper, error) {
+	var venues []contracts.Venue
+	for _, name := range cfg.DexVenues {
+		var swapper contracts.Swapper
+		var err error
+		switch name {
+		case "v2":
+			swapper, err = contracts.NewPancakeSwapper(client, nonces, tracker, privateKey, swapperCfg)
+		case "v3":
+			v3Cfg := swapperCfg
+			v3Cfg.Router = common.HexToAddress(cfg.V3RouterAddress)
+			v3Cfg.Quoter = common.HexToAddress(cfg.V3QuoterAddress)
+			swapper, err = contracts.NewPancakeV3Swapper(client, nonces, tracker, privateKey, v3Cfg)
+		default:
+			return nil, fmt.Errorf("unknown DEX venue %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		venues = append(venues, contracts.Venue{Name: name, Swapper: swapper})
+	}
+
+	if len(venues) == 1 {
+		return venues[0].Swapper, nil
+	}
+	return contracts.NewVenueSwapper(venues...)
+}
+
+func bnbToWei(amount *big.Float) *big.Int {
+	return toUnits(amount, contracts.BNBDecimals)
+}
+
+// toUnits converts a configured decimal amount to base units; see
+// contracts.FloatAmount.
+func toUnits(amount *big.Float, decimals uint8) *big.Int {
+	units, err := contracts.FloatAmount(amount, decimals)
+	if err != nil {
+		log.Printf("Warning: invalid amount %s, using 0", amount.String())
+		return new(big.Int)
+	}
+	return units.Raw
+}
+
+func hexAddresses(values []string) []common.Address {
+	var addresses []common.Address
+	for _, value := range values {
+		if !common.IsHexAddress(value) {
+			log.Printf("Warning: ignoring invalid address %q", value)
+			continue
+		}
+		addresses = append(addresses, common.HexToAddress(value))
+	}
+	return addresses
+}
+
+func buildGasStrategies(client *ethclient.Client, w config.WalletConfig, gasCfg contracts.GasStrategyConfig) (contracts.GasStrategies, error) {
+	buy, err := contracts.NewGasStrategy(client, w.GasStrategyBuy, gasCfg)
+	if err != nil {
+		return contracts.GasStrategies{}, err
+	}
+	sell, err := contracts.NewGasStrategy(client, w.GasStrategySell, gasCfg)
+	if err != nil {
+		return contracts.GasStrategies{}, err
+	}
+	approve, err := contracts.NewGasStrategy(client, w.GasStrategyApprove, gasCfg)
+	if err != nil {
+		return contracts.GasStrategies{}, err
+	}
+	return contracts.GasStrategies{Buy: buy, Sell: sell, Approve: approve}, nil
+}
@@ -2,6 +2,7 @@ package stoploss
 
 import (
 	"context"
+	"errors"
 	"log"
 	"math/big"
 	"sync"
@@ -17,37 +18,69 @@ const (
 	TakeProfitSellPercent = 70
 )
 
+// ExitRule is how a position is closed. Zero fields fall back to the
+// monitor's stop-loss and the TakeProfit* defaults.
+type ExitRule struct {
+	StopLossPercent       int
+	TakeProfitPriceUSDT   float64
+	TakeProfitSellPercent int
+}
+
 type Position struct {
 	TokenAddress       common.Address
+	Token              *contracts.TokenMetadata
 	BuyPriceWei        *big.Int
 	TokenAmount        *big.Int
 	InitialTokenAmount *big.Int
 	WalletIndex        int
-	Swapper            *contracts.PancakeSwapper
+	Swapper            contracts.Swapper
 	Sold               bool
 	Approved           bool
+	PendingApproveTx   string
 	TakeProfitDone     bool
+	PendingSellTx      string
+	Exit               ExitRule
+
+	// approveWatched is set while a callback will report PendingApproveTx.
+	approveWatched bool
 }
 
 type StopLossMonitor struct {
 	positions       map[string]*Position
 	stopLossPercent int
+	escalateBlocks  uint64
+	metadata        *contracts.MetadataService
 	mu              sync.RWMutex
 	ctx             context.Context
 	cancel          context.CancelFunc
 }
 
-func NewStopLossMonitor(stopLossPercent int) *StopLossMonitor {
+func NewStopLossMonitor(stopLossPercent int, escalateBlocks uint64, metadata *contracts.MetadataService) *StopLossMonitor {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &StopLossMonitor{
 		positions:       make(map[string]*Position),
 		stopLossPercent: stopLossPercent,
+		escalateBlocks:  escalateBlocks,
+		metadata:        metadata,
 		ctx:             ctx,
 		cancel:          cancel,
 	}
 }
 
-func (m *StopLossMonitor) AddPosition(walletIndex int, swapper *contracts.PancakeSwapper, tokenAddress common.Address, buyAmountWei *big.Int) {
+func (m *StopLossMonitor) resolveExit(exit ExitRule) ExitRule {
+	if exit.StopLossPercent <= 0 {
+		exit.StopLossPercent = m.stopLossPercent
+	}
+	if exit.TakeProfitPriceUSDT <= 0 {
+		exit.TakeProfitPriceUSDT = TakeProfitPriceUSDT
+	}
+	if exit.TakeProfitSellPercent <= 0 || exit.TakeProfitSellPercent > 100 {
+		exit.TakeProfitSellPercent = TakeProfitSellPercent
+	}
+	return exit
+}
+
+func (m *StopLossMonitor) AddPosition(walletIndex int, swapper contracts.Swapper, tokenAddress common.Address, buyAmountWei *big.Int, exit ExitRule) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -62,15 +95,20 @@ func (m *StopLossMonitor) AddPosition(walletIndex int, swapper *contracts.Pancak
 		return
 	}
 
-	currentPrice, err := swapper.GetTokenPrice(tokenAddress, balance)
+	currentPrice, err := afterTaxValue(swapper, tokenAddress, balance)
 	if err != nil {
 		log.Printf("[Wallet %d] Failed to get initial price: %v", walletIndex+1, err)
 		currentPrice = buyAmountWei
 	}
 
-	key := positionKey(walletIndex, tokenAddress)
-	m.positions[key] = &Position{
+	token, err := m.metadata.Get(m.ctx, tokenAddress)
+	if err != nil {
+		log.Printf("[Wallet %d] Failed to get token metadata, will retry: %v", walletIndex+1, err)
+	}
+
+	pos := &Position{
 		TokenAddress:       tokenAddress,
+		Token:              token,
 		BuyPriceWei:        currentPrice,
 		TokenAmount:        balance,
 		InitialTokenAmount: new(big.Int).Set(balance),
@@ -79,10 +117,15 @@ func (m *StopLossMonitor) AddPosition(walletIndex int, swapper *contracts.Pancak
 		Sold:               false,
 		Approved:           false,
 		TakeProfitDone:     false,
+		Exit:               m.resolveExit(exit),
 	}
+	m.positions[positionKey(walletIndex, tokenAddress)] = pos
 
 	log.Printf("[Wallet %d] Stop-loss monitoring started for %s", walletIndex+1, tokenAddress.Hex())
-	log.Printf("[Wallet %d] Token balance: %s, Initial value: %s wei", walletIndex+1, balance.String(), currentPrice.String())
+	log.Printf("[Wallet %d] Token balance: %s, Initial value after %.2f%% sell tax: %s BNB", walletIndex+1, m.format(pos, balance),
+		float64(swapper.GetTokenTax(tokenAddress).SellBps)/100, contracts.BNB(currentPrice).String())
+	log.Printf("[Wallet %d] Exit: stop-loss %d%%, take-profit %d%% at %.8f USDT", walletIndex+1,
+		pos.Exit.StopLossPercent, pos.Exit.TakeProfitSellPercent, pos.Exit.TakeProfitPriceUSDT)
 }
 
 func (m *StopLossMonitor) Start() {
@@ -105,8 +148,8 @@ func (m *StopLossMonitor) checkPositions() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for key, pos := range m.positions {
-		if pos.Sold {
+	for _, pos := range m.positions {
+		if pos.Sold || pos.PendingSellTx != "" {
 			continue
 		}
 
@@ -118,25 +161,45 @@ func (m *StopLossMonitor) checkPositions() {
 
 		if !pos.TakeProfitDone {
 			m.checkTakeProfit(pos)
+			if pos.PendingSellTx != "" {
+				continue
+			}
 		}
 
-		currentPrice, err := pos.Swapper.GetTokenPrice(pos.TokenAddress, pos.TokenAmount)
+		currentPrice, err := afterTaxValue(pos.Swapper, pos.TokenAddress, pos.TokenAmount)
 		if err != nil {
 			continue
 		}
 
 		dropPercent := m.calculateDropPercent(pos.BuyPriceWei, currentPrice)
 
-		if dropPercent >= m.stopLossPercent {
+		if dropPercent >= pos.Exit.StopLossPercent {
 			log.Printf("[Wallet %d] STOP-LOSS TRIGGERED! Token: %s, Drop: %d%%", pos.WalletIndex+1, pos.TokenAddress.Hex(), dropPercent)
-			m.executeSell(pos, pos.TokenAmount)
-			delete(m.positions, key)
+			m.executeSell(pos, pos.TokenAmount, func() {
+				pos.Sold = true
+				delete(m.positions, positionKey(pos.WalletIndex, pos.TokenAddress))
+			})
 		}
 	}
 }
 
+// checkTakeProfit prices one whole token in USDT after sell tax, or the whole
+// balance when it is smaller, scaled back to a per-token price with both
+// tokens' decimals.
 func (m *StopLossMonitor) checkTakeProfit(pos *Position) {
-	oneToken := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	if pos.Token == nil {
+		token, err := m.metadata.Get(m.ctx, pos.TokenAddress)
+		if err != nil {
+			return
+		}
+		pos.Token = token
+	}
+	usdt, err := m.metadata.Get(m.ctx, contracts.USDT)
+	if err != nil {
+		return
+	}
+
+	oneToken := contracts.Unit(pos.Token.Decimals)
 	if pos.TokenAmount.Cmp(oneToken) < 0 {
 		oneToken = pos.TokenAmount
 	}
@@ -145,16 +208,15 @@ func (m *StopLossMonitor) checkTakeProfit(pos *Position) {
 	if err != nil {
 		return
 	}
+	usdtAmount = contracts.AfterTax(usdtAmount, pos.Swapper.GetTokenTax(pos.TokenAddress).SellBps)
 
-	usdtFloat := new(big.Float).SetInt(usdtAmount)
-	divisor := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
-	priceUSDT, _ := new(big.Float).Quo(usdtFloat, divisor).Float64()
+	priceUSDT, _ := contracts.UnitPrice(pos.Token.Amount(oneToken), usdt.Amount(usdtAmount)).Float64()
 
-	if priceUSDT >= TakeProfitPriceUSDT {
+	if priceUSDT >= pos.Exit.TakeProfitPriceUSDT {
 		log.Printf("[Wallet %d] TAKE-PROFIT TRIGGERED! Price: %.8f USDT >= %.8f USDT",
-			pos.WalletIndex+1, priceUSDT, TakeProfitPriceUSDT)
+			pos.WalletIndex+1, priceUSDT, pos.Exit.TakeProfitPriceUSDT)
 
-		sellAmount := new(big.Int).Mul(pos.InitialTokenAmount, big.NewInt(TakeProfitSellPercent))
+		sellAmount := new(big.Int).Mul(pos.InitialTokenAmount, big.NewInt(int64(pos.Exit.TakeProfitSellPercent)))
 		sellAmount.Div(sellAmount, big.NewInt(100))
 
 		if sellAmount.Cmp(pos.TokenAmount) > 0 {
@@ -162,12 +224,72 @@ func (m *StopLossMonitor) checkTakeProfit(pos *Position) {
 		}
 
 		if sellAmount.Cmp(big.NewInt(0)) > 0 {
-			m.executeSell(pos, sellAmount)
-			log.Printf("[Wallet %d] Sold %d%% at %.8f USDT", pos.WalletIndex+1, TakeProfitSellPercent, priceUSDT)
+			m.executeSell(pos, sellAmount, func() {
+				pos.TakeProfitDone = true
+				log.Printf("[Wallet %d] Sold %d%% at %.8f USDT", pos.WalletIndex+1, pos.Exit.TakeProfitSellPercent, priceUSDT)
+			})
+		} else {
+			pos.TakeProfitDone = true
 		}
+	}
+}
+
+// approve makes sure the position's tokens may be sold and reports whether
+// the sell can go out now. An allowance that already covers the amount needs
+// nothing; otherwise the sell waits for the approval to be mined and the
+// next tick retries, rather than sleeping or racing the approval. An approval
+// that could not be watched stays pending and is asked about again on the
+// next tick; the swapper hands back the same hash while it is in flight, so
+// no second approve is sent.
+func (m *StopLossMonitor) approve(pos *Position, amount *big.Int) bool {
+	if pos.approveWatched {
+		log.Printf("[Wallet %d] Waiting for approval %s before selling", pos.WalletIndex+1, pos.PendingApproveTx)
+		return false
+	}
 
-		pos.TakeProfitDone = true
+	approveTx, err := pos.Swapper.ApproveToken(pos.TokenAddress, amount)
+	if err != nil {
+		log.Printf("[Wallet %d] Failed to approve: %v", pos.WalletIndex+1, err)
+		return false
 	}
+	if approveTx == "" {
+		pos.PendingApproveTx = ""
+		pos.Approved = true
+		return true
+	}
+
+	if approveTx != pos.PendingApproveTx {
+		log.Printf("[Wallet %d] Approve TX: %s", pos.WalletIndex+1, approveTx)
+	}
+	pos.PendingApproveTx = approveTx
+	err = pos.Swapper.OnTxDone(approveTx, func(result contracts.TxResult) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		pos.PendingApproveTx = ""
+		pos.approveWatched = false
+		if !result.Success() {
+			log.Printf("[Wallet %d] Approval %s %s, will retry", pos.WalletIndex+1, approveTx, result.Status)
+			return
+		}
+		pos.Approved = true
+	})
+	if err != nil {
+		log.Printf("[Wallet %d] Failed to watch approval %s, checking it again next tick: %v", pos.WalletIndex+1, approveTx, err)
+		return false
+	}
+	pos.approveWatched = true
+	return false
+}
+
+// afterTaxValue is what selling amount would pay in BNB once the token's
+// sell tax is taken, which is what stop-loss compares against.
+func afterTaxValue(swapper contracts.Swapper, tokenAddress common.Address, amount *big.Int) (*big.Int, error) {
+	value, err := swapper.GetTokenPrice(tokenAddress, amount)
+	if err != nil {
+		return nil, err
+	}
+	return contracts.AfterTax(value, swapper.GetTokenTax(tokenAddress).SellBps), nil
 }
 
 func (m *StopLossMonitor) calculateDropPercent(buyPrice, currentPrice *big.Int) int {
@@ -186,33 +308,60 @@ func (m *StopLossMonitor) calculateDropPercent(buyPrice, currentPrice *big.Int)
 	return int(percent.Int64())
 }
 
-func (m *StopLossMonitor) executeSell(pos *Position, amount *big.Int) {
-	if !pos.Approved {
-		log.Printf("[Wallet %d] Approving token for sale...", pos.WalletIndex+1)
-
-		maxApprove := new(big.Int)
-		maxApprove.SetString("115792089237316195423570985008687907853269984665640564039457584007913129639935", 10)
-
-		approveTx, err := pos.Swapper.ApproveToken(pos.TokenAddress, maxApprove)
-		if err != nil {
-			log.Printf("[Wallet %d] Failed to approve: %v", pos.WalletIndex+1, err)
-			return
-		}
-		log.Printf("[Wallet %d] Approve TX: %s", pos.WalletIndex+1, approveTx)
-		pos.Approved = true
-
-		time.Sleep(3 * time.Second)
+// executeSell sends the sell and leaves the position marked as pending until
+// the tracker reports the outcome; onSold runs under the monitor lock only if
+// the sell was actually mined, otherwise the next tick retries.
+func (m *StopLossMonitor) executeSell(pos *Position, amount *big.Int, onSold func()) {
+	if !pos.Approved && !m.approve(pos, amount) {
+		return
 	}
 
-	log.Printf("[Wallet %d] Selling %s tokens...", pos.WalletIndex+1, amount.String())
+	log.Printf("[Wallet %d] Selling %s...", pos.WalletIndex+1, m.format(pos, amount))
 	sellTx, err := pos.Swapper.SellToken(pos.TokenAddress, amount)
+	if errors.Is(err, contracts.ErrNotApproved) {
+		log.Printf("[Wallet %d] Token needs a new approval: %v", pos.WalletIndex+1, err)
+		pos.Approved = false
+		return
+	}
 	if err != nil {
 		log.Printf("[Wallet %d] Failed to sell: %v", pos.WalletIndex+1, err)
 		return
 	}
 
-	log.Printf("[Wallet %d] SOLD! TX: %s", pos.WalletIndex+1, sellTx)
+	log.Printf("[Wallet %d] Sell sent! TX: %s", pos.WalletIndex+1, sellTx)
 	log.Printf("[Wallet %d] BSCScan: https://bscscan.com/tx/%s", pos.WalletIndex+1, sellTx)
+
+	pos.PendingSellTx = sellTx
+	err = pos.Swapper.OnTxDone(sellTx, func(result contracts.TxResult) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		pos.PendingSellTx = ""
+		if !result.Success() {
+			log.Printf("[Wallet %d] Sell %s %s, will retry", pos.WalletIndex+1, sellTx, result.Status)
+			return
+		}
+		log.Printf("[Wallet %d] SOLD! TX: %s", pos.WalletIndex+1, sellTx)
+		onSold()
+	})
+	if err != nil {
+		log.Printf("[Wallet %d] Failed to watch sell %s: %v", pos.WalletIndex+1, sellTx, err)
+		pos.PendingSellTx = ""
+		return
+	}
+
+	if m.escalateBlocks > 0 {
+		go pos.Swapper.Escalate(m.ctx, sellTx, m.escalateBlocks)
+	}
+}
+
+// format renders a token amount with the position's decimals and symbol,
+// falling back to base units while the metadata is unknown.
+func (m *StopLossMonitor) format(pos *Position, amount *big.Int) string {
+	if pos.Token == nil {
+		return amount.String() + " base units"
+	}
+	return pos.Token.Format(amount)
 }
 
 func (m *StopLossMonitor) Stop() {
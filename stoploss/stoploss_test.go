@@ -0,0 +1,68 @@
+package stoploss
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"flap/contracts"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// approveSwapper approves the way ensureApproval does: an approval in flight
+// is handed back rather than sent again. Each OnTxDone error is used once.
+type approveSwapper struct {
+	contracts.Swapper
+	pending   string
+	sent      int
+	watchErrs []error
+	callbacks []func(contracts.TxResult)
+}
+
+func (s *approveSwapper) ApproveToken(tokenAddress common.Address, amount *big.Int) (string, error) {
+	if s.pending == "" {
+		s.sent++
+		s.pending = "0xapprove"
+	}
+	return s.pending, nil
+}
+
+func (s *approveSwapper) OnTxDone(txHash string, cb func(contracts.TxResult)) error {
+	if len(s.watchErrs) > 0 {
+		err := s.watchErrs[0]
+		s.watchErrs = s.watchErrs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	s.callbacks = append(s.callbacks, cb)
+	return nil
+}
+
+func TestApproveWatchFailure(t *testing.T) {
+	m := &StopLossMonitor{}
+	swapper := &approveSwapper{watchErrs: []error{errors.New("transaction is not tracked"), nil}}
+	pos := &Position{Swapper: swapper}
+	amount := big.NewInt(1000)
+
+	for tick := 1; tick <= 3; tick++ {
+		if m.approve(pos, amount) {
+			t.Fatalf("tick %d: approve() = true while the approval is in flight", tick)
+		}
+		if pos.PendingApproveTx != "0xapprove" {
+			t.Fatalf("tick %d: PendingApproveTx = %q, want the approval kept", tick, pos.PendingApproveTx)
+		}
+	}
+	if swapper.sent != 1 {
+		t.Errorf("sent %d approvals, want 1", swapper.sent)
+	}
+	if len(swapper.callbacks) != 1 {
+		t.Fatalf("%d callbacks registered, want 1", len(swapper.callbacks))
+	}
+
+	swapper.callbacks[0](contracts.TxResult{Status: contracts.TxMined})
+	if !pos.Approved || pos.PendingApproveTx != "" || pos.approveWatched {
+		t.Errorf("after the approval was mined: %+v", pos)
+	}
+}